@@ -23,15 +23,20 @@ import (
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/coreos/prometheus-operator/pkg/alertmanager"
 	"github.com/coreos/prometheus-operator/pkg/analytics"
 	"github.com/coreos/prometheus-operator/pkg/api"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+	"github.com/coreos/prometheus-operator/pkg/leaderelection"
 	prometheuscontroller "github.com/coreos/prometheus-operator/pkg/prometheus"
 	"github.com/go-kit/kit/log"
 )
@@ -39,8 +44,28 @@ import (
 var (
 	cfg              prometheuscontroller.Config
 	analyticsEnabled bool
+
+	leaderElect  bool
+	leaderConfig leaderelection.Config
 )
 
+// namespaces is a flag.Value collecting a comma separated list of namespaces.
+// The flag may be repeated.
+type namespaces []string
+
+func (n *namespaces) String() string {
+	return strings.Join(*n, ",")
+}
+
+func (n *namespaces) Set(value string) error {
+	for _, ns := range strings.Split(value, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			*n = append(*n, ns)
+		}
+	}
+	return nil
+}
+
 func init() {
 	flagset := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 
@@ -55,12 +80,26 @@ func init() {
 	flagset.StringVar(&cfg.ConfigReloaderImage, "config-reloader-image", "quay.io/coreos/configmap-reload:v0.0.1", "Reload Image")
 	flagset.StringVar(&cfg.AlertmanagerDefaultBaseImage, "alertmanager-default-base-image", "quay.io/prometheus/alertmanager", "Alertmanager default base image")
 	flagset.StringVar(&cfg.PrometheusDefaultBaseImage, "prometheus-default-base-image", "quay.io/prometheus/prometheus", "Prometheus default base image")
+	flagset.Var((*namespaces)(&cfg.Namespaces), "namespaces", "Comma separated list of namespaces to manage. Omit to manage all namespaces. When set, the operator only requires namespaced permissions for the listed namespaces.")
+	flagset.Var((*namespaces)(&cfg.DenyNamespaces), "deny-namespaces", "Comma separated list of namespaces to ignore.")
+	flagset.IntVar(&cfg.Workers, "workers", 1, "Number of objects processed concurrently by each of the Prometheus and Alertmanager controllers.")
+	flagset.StringVar(&cfg.PrometheusSelector, "prometheus-instance-selector", "", "Label selector restricting the Prometheus objects managed by this operator instance.")
+	flagset.StringVar(&cfg.AlertmanagerSelector, "alertmanager-instance-selector", "", "Label selector restricting the Alertmanager objects managed by this operator instance.")
+	flagset.StringVar(&cfg.OperatorInstance, "operator-instance", "", "Name of this operator instance. Generated StatefulSets are labeled with it and StatefulSets of other instances and their objects are neither updated nor deleted. Use together with the instance selectors to run several operators side by side.")
+	flagset.BoolVar(&leaderElect, "leader-elect", false, "Run leader election so that only one of several operator replicas manages Prometheus and Alertmanager objects.")
+	flagset.StringVar(&leaderConfig.Namespace, "leader-elect-namespace", "default", "Namespace of the ConfigMap used as leader election lock.")
+	flagset.StringVar(&leaderConfig.Name, "leader-elect-name", "prometheus-operator", "Name of the ConfigMap used as leader election lock.")
+	flagset.DurationVar(&leaderConfig.LeaseDuration, "leader-elect-lease-duration", 15*time.Second, "Duration non-leader replicas wait before taking over leadership from a leader that stopped renewing the lock.")
+	flagset.DurationVar(&leaderConfig.RenewDeadline, "leader-elect-renew-deadline", 10*time.Second, "Duration the leader retries renewing the lock before giving up leadership.")
+	flagset.DurationVar(&leaderConfig.RetryPeriod, "leader-elect-retry-period", 2*time.Second, "Duration between attempts to acquire or renew the lock.")
+	flagset.BoolVar(&cfg.LabeledConfigObjectsOnly, "labeled-config-objects-only", false, "Only cache ConfigMaps and Secrets labeled with "+prometheuscontroller.WatchedLabel+" to reduce memory usage. Rule ConfigMaps, Secrets referenced for basic auth, Alertmanager config Secrets and Secrets referenced by AlertmanagerConfigs must then carry the label.")
+	flagset.BoolVar(&cfg.ManageTPRs, "manage-tprs", true, "Create the ThirdPartyResources used by the operator. Disable when the operator lacks cluster wide permissions and the ThirdPartyResources are registered out of band.")
 
 	flagset.Parse(os.Args[1:])
 }
 
 func Main() int {
-	logger := log.NewContext(log.NewLogfmtLogger(os.Stdout)).
+	logger := log.NewContext(log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout))).
 		With("ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
 
 	r := prometheus.NewRegistry()
@@ -83,7 +122,7 @@ func Main() int {
 	}
 
 	mux := http.NewServeMux()
-	web, err := api.New(cfg, logger.With("component", "api"))
+	web, err := api.New(cfg, po, logger.With("component", "api"))
 	if err != nil {
 		fmt.Fprint(os.Stderr, err)
 		return 1
@@ -106,11 +145,44 @@ func Main() int {
 	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
 	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
 
+	// run starts both operators until stopc is closed or one of them fails.
+	run := func(stopc <-chan struct{}) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-stopc:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		wg, ctx := errgroup.WithContext(ctx)
+		wg.Go(func() error { return po.Run(ctx.Done()) })
+		wg.Go(func() error { return ao.Run(ctx.Done()) })
+		return wg.Wait()
+	}
+
+	var le *leaderelection.LeaderElector
+	if leaderElect {
+		le, err = newLeaderElector(logger.With("component", "leaderelection"))
+		if err != nil {
+			fmt.Fprint(os.Stderr, err)
+			return 1
+		}
+		le.RegisterMetrics(r)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	wg, ctx := errgroup.WithContext(ctx)
 
-	wg.Go(func() error { return po.Run(ctx.Done()) })
-	wg.Go(func() error { return ao.Run(ctx.Done()) })
+	if le != nil {
+		// Losing leadership terminates the operator, so that it restarts as a
+		// follower with a clean state.
+		wg.Go(func() error { return le.Run(ctx.Done(), run) })
+	} else {
+		wg.Go(func() error { return run(ctx.Done()) })
+	}
 
 	srv := &http.Server{Handler: mux}
 	go srv.Serve(l)
@@ -133,6 +205,22 @@ func Main() int {
 	return 0
 }
 
+func newLeaderElector(logger log.Logger) (*leaderelection.LeaderElector, error) {
+	kcfg, err := k8sutil.NewClusterConfig(cfg.Host, cfg.TLSInsecure, &cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	kclient, err := kubernetes.NewForConfig(kcfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if leaderConfig.Identity, err = os.Hostname(); err != nil {
+		return nil, err
+	}
+	return leaderelection.New(kclient, leaderConfig, logger)
+}
+
 func main() {
 	os.Exit(Main())
 }
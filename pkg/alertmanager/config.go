@@ -0,0 +1,369 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/client-go/pkg/api/v1"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+)
+
+const configFilename = "alertmanager.yaml"
+
+// defaultBaseConfig is used if an Alertmanager has no config Secret. It is
+// written to the config Secret of Alertmanagers not selecting
+// AlertmanagerConfigs, so that their pods can start. Alerts not routed by any
+// AlertmanagerConfig are dropped.
+var defaultBaseConfig = []byte(`route:
+  receiver: "null"
+receivers:
+- name: "null"
+`)
+
+// namespaceConfig holds the routes, receivers and inhibit rules of an
+// AlertmanagerConfig in the format of the Alertmanager configuration.
+type namespaceConfig struct {
+	namespace    string
+	routes       []yaml.MapSlice
+	receivers    []yaml.MapSlice
+	inhibitRules []yaml.MapSlice
+}
+
+// secretLoader returns the value of the key of a Secret in the given
+// namespace.
+type secretLoader func(ns string, sel v1.SecretKeySelector) (string, error)
+
+// convertAlertmanagerConfig converts the AlertmanagerConfig to the format of
+// the Alertmanager configuration. Receivers are prefixed with the namespace
+// and name of the AlertmanagerConfig, so that their names are unique across
+// all AlertmanagerConfigs.
+func convertAlertmanagerConfig(amc *v1alpha1.AlertmanagerConfig, loadSecret secretLoader) (*namespaceConfig, error) {
+	res := &namespaceConfig{namespace: amc.Namespace}
+	names := map[string]string{}
+
+	for _, r := range amc.Spec.Receivers {
+		if r.Name == "" {
+			return nil, fmt.Errorf("receiver without name")
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate receiver %q", r.Name)
+		}
+		names[r.Name] = fmt.Sprintf("%s-%s-%s", amc.Namespace, amc.Name, r.Name)
+
+		rcv, err := convertReceiver(r, names[r.Name], func(sel *v1.SecretKeySelector) (string, error) {
+			if sel == nil {
+				return "", nil
+			}
+			return loadSecret(amc.Namespace, *sel)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "receiver %q", r.Name)
+		}
+		res.receivers = append(res.receivers, rcv)
+	}
+
+	for _, r := range amc.Spec.Routes {
+		route, err := convertRoute(r, names)
+		if err != nil {
+			return nil, err
+		}
+		res.routes = append(res.routes, route)
+	}
+
+	for _, r := range amc.Spec.InhibitRules {
+		res.inhibitRules = append(res.inhibitRules, convertInhibitRule(r, amc.Namespace))
+	}
+	return res, nil
+}
+
+// secretRefs returns the names of the Secrets referenced by the receivers of
+// the AlertmanagerConfig.
+func secretRefs(amc *v1alpha1.AlertmanagerConfig) []string {
+	var refs []string
+	add := func(sel *v1.SecretKeySelector) {
+		if sel != nil {
+			refs = append(refs, sel.Name)
+		}
+	}
+	for _, r := range amc.Spec.Receivers {
+		for _, c := range r.EmailConfigs {
+			add(c.AuthPassword)
+		}
+		for _, c := range r.SlackConfigs {
+			add(c.APIURL)
+		}
+		for _, c := range r.PagerDutyConfigs {
+			add(c.ServiceKey)
+		}
+	}
+	return refs
+}
+
+func convertRoute(r v1alpha1.AlertmanagerRoute, receivers map[string]string) (yaml.MapSlice, error) {
+	res := yaml.MapSlice{}
+	if r.Receiver != "" {
+		name, ok := receivers[r.Receiver]
+		if !ok {
+			return nil, fmt.Errorf("route references unknown receiver %q", r.Receiver)
+		}
+		res = append(res, yaml.MapItem{Key: "receiver", Value: name})
+	}
+	if len(r.GroupBy) > 0 {
+		res = append(res, yaml.MapItem{Key: "group_by", Value: r.GroupBy})
+	}
+	if len(r.Match) > 0 {
+		res = append(res, yaml.MapItem{Key: "match", Value: sortedMapSlice(r.Match)})
+	}
+	if len(r.MatchRE) > 0 {
+		res = append(res, yaml.MapItem{Key: "match_re", Value: sortedMapSlice(r.MatchRE)})
+	}
+	if r.Continue {
+		res = append(res, yaml.MapItem{Key: "continue", Value: true})
+	}
+	if r.GroupWait != "" {
+		res = append(res, yaml.MapItem{Key: "group_wait", Value: r.GroupWait})
+	}
+	if r.GroupInterval != "" {
+		res = append(res, yaml.MapItem{Key: "group_interval", Value: r.GroupInterval})
+	}
+	if r.RepeatInterval != "" {
+		res = append(res, yaml.MapItem{Key: "repeat_interval", Value: r.RepeatInterval})
+	}
+
+	var routes []yaml.MapSlice
+	for _, child := range r.Routes {
+		route, err := convertRoute(child, receivers)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	if len(routes) > 0 {
+		res = append(res, yaml.MapItem{Key: "routes", Value: routes})
+	}
+	return res, nil
+}
+
+func convertReceiver(r v1alpha1.AlertmanagerReceiver, name string, loadSecret func(*v1.SecretKeySelector) (string, error)) (yaml.MapSlice, error) {
+	res := yaml.MapSlice{{Key: "name", Value: name}}
+
+	var webhooks []yaml.MapSlice
+	for _, c := range r.WebhookConfigs {
+		cfg := sendResolved(c.SendResolved)
+		cfg = append(cfg, yaml.MapItem{Key: "url", Value: c.URL})
+		webhooks = append(webhooks, cfg)
+	}
+	if len(webhooks) > 0 {
+		res = append(res, yaml.MapItem{Key: "webhook_configs", Value: webhooks})
+	}
+
+	var emails []yaml.MapSlice
+	for _, c := range r.EmailConfigs {
+		cfg := sendResolved(c.SendResolved)
+		cfg = append(cfg, yaml.MapItem{Key: "to", Value: c.To})
+		cfg = appendString(cfg, "from", c.From)
+		cfg = appendString(cfg, "smarthost", c.Smarthost)
+		cfg = appendString(cfg, "auth_username", c.AuthUsername)
+		password, err := loadSecret(c.AuthPassword)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading email auth password failed")
+		}
+		cfg = appendString(cfg, "auth_password", password)
+		if c.RequireTLS != nil {
+			cfg = append(cfg, yaml.MapItem{Key: "require_tls", Value: *c.RequireTLS})
+		}
+		emails = append(emails, cfg)
+	}
+	if len(emails) > 0 {
+		res = append(res, yaml.MapItem{Key: "email_configs", Value: emails})
+	}
+
+	var slacks []yaml.MapSlice
+	for _, c := range r.SlackConfigs {
+		cfg := sendResolved(c.SendResolved)
+		apiURL, err := loadSecret(c.APIURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading Slack API URL failed")
+		}
+		cfg = appendString(cfg, "api_url", apiURL)
+		cfg = appendString(cfg, "channel", c.Channel)
+		cfg = appendString(cfg, "username", c.Username)
+		cfg = appendString(cfg, "title", c.Title)
+		cfg = appendString(cfg, "text", c.Text)
+		slacks = append(slacks, cfg)
+	}
+	if len(slacks) > 0 {
+		res = append(res, yaml.MapItem{Key: "slack_configs", Value: slacks})
+	}
+
+	var pagerDuties []yaml.MapSlice
+	for _, c := range r.PagerDutyConfigs {
+		cfg := sendResolved(c.SendResolved)
+		if c.ServiceKey == nil {
+			return nil, fmt.Errorf("PagerDuty config without service key")
+		}
+		key, err := loadSecret(c.ServiceKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading PagerDuty service key failed")
+		}
+		cfg = append(cfg, yaml.MapItem{Key: "service_key", Value: key})
+		cfg = appendString(cfg, "url", c.URL)
+		pagerDuties = append(pagerDuties, cfg)
+	}
+	if len(pagerDuties) > 0 {
+		res = append(res, yaml.MapItem{Key: "pagerduty_configs", Value: pagerDuties})
+	}
+
+	return res, nil
+}
+
+// convertInhibitRule converts the inhibit rule, restricting both the source
+// and the target alerts to the namespace.
+func convertInhibitRule(r v1alpha1.AlertmanagerInhibitRule, ns string) yaml.MapSlice {
+	withNamespace := func(m map[string]string) map[string]string {
+		res := map[string]string{"namespace": ns}
+		for k, v := range m {
+			if k != "namespace" {
+				res[k] = v
+			}
+		}
+		return res
+	}
+
+	res := yaml.MapSlice{{Key: "source_match", Value: sortedMapSlice(withNamespace(r.SourceMatch))}}
+	if len(r.SourceMatchRE) > 0 {
+		res = append(res, yaml.MapItem{Key: "source_match_re", Value: sortedMapSlice(r.SourceMatchRE)})
+	}
+	res = append(res, yaml.MapItem{Key: "target_match", Value: sortedMapSlice(withNamespace(r.TargetMatch))})
+	if len(r.TargetMatchRE) > 0 {
+		res = append(res, yaml.MapItem{Key: "target_match_re", Value: sortedMapSlice(r.TargetMatchRE)})
+	}
+	if len(r.Equal) > 0 {
+		res = append(res, yaml.MapItem{Key: "equal", Value: r.Equal})
+	}
+	return res
+}
+
+// generateConfig merges the namespace configs into the base configuration.
+// The routes of each namespace are nested under a route matching the
+// namespace label, which precedes the routes of the base configuration.
+// Namespace configs are expected to be sorted by namespace.
+func generateConfig(base []byte, configs []*namespaceConfig) ([]byte, error) {
+	if len(base) == 0 {
+		base = defaultBaseConfig
+	}
+	var cfg yaml.MapSlice
+	if err := yaml.Unmarshal(base, &cfg); err != nil {
+		return nil, errors.Wrap(err, "parsing base configuration failed")
+	}
+	route, ok := mapSliceValue(cfg, "route").(yaml.MapSlice)
+	if !ok {
+		return nil, fmt.Errorf("base configuration has no route")
+	}
+
+	var (
+		nsRoutes     []interface{}
+		receivers    []interface{}
+		inhibitRules []interface{}
+	)
+	for i := 0; i < len(configs); {
+		ns := configs[i].namespace
+		var routes []yaml.MapSlice
+		for ; i < len(configs) && configs[i].namespace == ns; i++ {
+			routes = append(routes, configs[i].routes...)
+			for _, r := range configs[i].receivers {
+				receivers = append(receivers, r)
+			}
+			for _, r := range configs[i].inhibitRules {
+				inhibitRules = append(inhibitRules, r)
+			}
+		}
+		if len(routes) == 0 {
+			continue
+		}
+		nsRoutes = append(nsRoutes, yaml.MapSlice{
+			{Key: "match", Value: yaml.MapSlice{{Key: "namespace", Value: ns}}},
+			{Key: "routes", Value: routes},
+		})
+	}
+
+	if len(nsRoutes) > 0 {
+		baseRoutes, _ := mapSliceValue(route, "routes").([]interface{})
+		route = setMapSliceValue(route, "routes", append(nsRoutes, baseRoutes...))
+		cfg = setMapSliceValue(cfg, "route", route)
+	}
+
+	baseReceivers, _ := mapSliceValue(cfg, "receivers").([]interface{})
+	cfg = setMapSliceValue(cfg, "receivers", append(baseReceivers, receivers...))
+
+	if len(inhibitRules) > 0 {
+		baseInhibitRules, _ := mapSliceValue(cfg, "inhibit_rules").([]interface{})
+		cfg = setMapSliceValue(cfg, "inhibit_rules", append(baseInhibitRules, inhibitRules...))
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+func sendResolved(v *bool) yaml.MapSlice {
+	if v == nil {
+		return yaml.MapSlice{}
+	}
+	return yaml.MapSlice{{Key: "send_resolved", Value: *v}}
+}
+
+func appendString(s yaml.MapSlice, key, value string) yaml.MapSlice {
+	if value == "" {
+		return s
+	}
+	return append(s, yaml.MapItem{Key: key, Value: value})
+}
+
+func sortedMapSlice(m map[string]string) yaml.MapSlice {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := yaml.MapSlice{}
+	for _, k := range keys {
+		res = append(res, yaml.MapItem{Key: k, Value: m[k]})
+	}
+	return res
+}
+
+func mapSliceValue(s yaml.MapSlice, key string) interface{} {
+	for _, item := range s {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapSliceValue(s yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range s {
+		if item.Key == key {
+			s[i].Value = value
+			return s
+		}
+	}
+	return append(s, yaml.MapItem{Key: key, Value: value})
+}
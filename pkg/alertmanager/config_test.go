@@ -0,0 +1,254 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+)
+
+var testBaseConfig = []byte(`global:
+  resolve_timeout: 5m
+route:
+  receiver: default
+  group_by: [alertname]
+  routes:
+  - match:
+      severity: critical
+    receiver: default
+receivers:
+- name: default
+  webhook_configs:
+  - url: http://default.example.com/
+inhibit_rules:
+- source_match:
+    severity: critical
+  target_match:
+    severity: warning
+`)
+
+func testSecretLoader(ns string, sel v1.SecretKeySelector) (string, error) {
+	if ns == "team-a" && sel.Name == "slack" && sel.Key == "url" {
+		return "https://hooks.slack.com/services/team-a", nil
+	}
+	return "", fmt.Errorf("secret %q not found", sel.Name)
+}
+
+func testAlertmanagerConfigs() []*v1alpha1.AlertmanagerConfig {
+	sendResolved := true
+	return []*v1alpha1.AlertmanagerConfig{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "api"},
+			Spec: v1alpha1.AlertmanagerConfigSpec{
+				Routes: []v1alpha1.AlertmanagerRoute{
+					{
+						Receiver: "slack",
+						Match:    map[string]string{"service": "api"},
+						Routes: []v1alpha1.AlertmanagerRoute{
+							{Receiver: "webhook", MatchRE: map[string]string{"severity": "critical|page"}},
+						},
+					},
+				},
+				Receivers: []v1alpha1.AlertmanagerReceiver{
+					{
+						Name: "slack",
+						SlackConfigs: []v1alpha1.SlackConfig{
+							{APIURL: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "slack"}, Key: "url"}, Channel: "#api"},
+						},
+					},
+					{
+						Name:           "webhook",
+						WebhookConfigs: []v1alpha1.WebhookConfig{{URL: "http://api.example.com/", SendResolved: &sendResolved}},
+					},
+				},
+				InhibitRules: []v1alpha1.AlertmanagerInhibitRule{
+					{
+						SourceMatch: map[string]string{"severity": "critical", "namespace": "team-b"},
+						TargetMatch: map[string]string{"severity": "warning"},
+						Equal:       []string{"service"},
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "db"},
+			Spec: v1alpha1.AlertmanagerConfigSpec{
+				Routes: []v1alpha1.AlertmanagerRoute{
+					{Receiver: "webhook", Match: map[string]string{"service": "db"}},
+				},
+				Receivers: []v1alpha1.AlertmanagerReceiver{
+					{Name: "webhook", WebhookConfigs: []v1alpha1.WebhookConfig{{URL: "http://db.example.com/"}}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "web"},
+			Spec: v1alpha1.AlertmanagerConfigSpec{
+				Routes: []v1alpha1.AlertmanagerRoute{
+					{Receiver: "webhook", GroupBy: []string{"alertname", "pod"}},
+				},
+				Receivers: []v1alpha1.AlertmanagerReceiver{
+					{Name: "webhook", WebhookConfigs: []v1alpha1.WebhookConfig{{URL: "http://web.example.com/"}}},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerateConfig(t *testing.T) {
+	var configs []*namespaceConfig
+	for _, amc := range testAlertmanagerConfigs() {
+		nc, err := convertAlertmanagerConfig(amc, testSecretLoader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs = append(configs, nc)
+	}
+	b, err := generateConfig(testBaseConfig, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expectedGeneratedConfig {
+		t.Fatalf("unexpected config:\n%s\nexpected:\n%s", b, expectedGeneratedConfig)
+	}
+}
+
+func TestGenerateConfigDefaultBase(t *testing.T) {
+	b, err := generateConfig(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(b); err != nil {
+		t.Fatalf("default configuration is invalid: %s", err)
+	}
+
+	if _, err := generateConfig([]byte("receivers: []\n"), nil); err == nil {
+		t.Fatal("expected error for base configuration without route")
+	}
+}
+
+func TestConvertAlertmanagerConfigErrors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		spec v1alpha1.AlertmanagerConfigSpec
+	}{
+		{
+			name: "unknown receiver",
+			spec: v1alpha1.AlertmanagerConfigSpec{
+				Routes: []v1alpha1.AlertmanagerRoute{{Receiver: "missing"}},
+			},
+		},
+		{
+			name: "duplicate receiver",
+			spec: v1alpha1.AlertmanagerConfigSpec{
+				Receivers: []v1alpha1.AlertmanagerReceiver{{Name: "webhook"}, {Name: "webhook"}},
+			},
+		},
+		{
+			name: "missing secret",
+			spec: v1alpha1.AlertmanagerConfigSpec{
+				Receivers: []v1alpha1.AlertmanagerReceiver{
+					{
+						Name: "slack",
+						SlackConfigs: []v1alpha1.SlackConfig{
+							{APIURL: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "missing"}, Key: "url"}},
+						},
+					},
+				},
+			},
+		},
+	} {
+		amc := &v1alpha1.AlertmanagerConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "broken"},
+			Spec:       c.spec,
+		}
+		if _, err := convertAlertmanagerConfig(amc, testSecretLoader); err == nil {
+			t.Fatalf("%s: expected conversion error", c.name)
+		}
+	}
+}
+
+// expectedGeneratedConfig is the configuration generated from testBaseConfig
+// and testAlertmanagerConfigs. The routes of each namespace are nested under a
+// route matching the namespace, receivers are prefixed with the namespace and
+// name of their AlertmanagerConfig, and inhibit rules are restricted to their
+// namespace.
+const expectedGeneratedConfig = `global:
+  resolve_timeout: 5m
+route:
+  receiver: default
+  group_by:
+  - alertname
+  routes:
+  - match:
+      namespace: team-a
+    routes:
+    - receiver: team-a-api-slack
+      match:
+        service: api
+      routes:
+      - receiver: team-a-api-webhook
+        match_re:
+          severity: critical|page
+    - receiver: team-a-db-webhook
+      match:
+        service: db
+  - match:
+      namespace: team-b
+    routes:
+    - receiver: team-b-web-webhook
+      group_by:
+      - alertname
+      - pod
+  - match:
+      severity: critical
+    receiver: default
+receivers:
+- name: default
+  webhook_configs:
+  - url: http://default.example.com/
+- name: team-a-api-slack
+  slack_configs:
+  - api_url: https://hooks.slack.com/services/team-a
+    channel: '#api'
+- name: team-a-api-webhook
+  webhook_configs:
+  - send_resolved: true
+    url: http://api.example.com/
+- name: team-a-db-webhook
+  webhook_configs:
+  - url: http://db.example.com/
+- name: team-b-web-webhook
+  webhook_configs:
+  - url: http://web.example.com/
+inhibit_rules:
+- source_match:
+    severity: critical
+  target_match:
+    severity: warning
+- source_match:
+    namespace: team-a
+    severity: critical
+  target_match:
+    namespace: team-a
+    severity: warning
+  equal:
+  - service
+`
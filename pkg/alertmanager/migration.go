@@ -0,0 +1,196 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+)
+
+// legacyStorageDir is the storage path of the pods created by earlier
+// versions of the operator. It is not on the data volume, so the silences
+// of these pods are lost once they are replaced.
+const legacyStorageDir = "/etc/alertmanager/data"
+
+const silencesFilename = "silences.json"
+
+// maxExportSize is the size limit of the data of a ConfigMap.
+const maxExportSize = 1 << 20
+
+// usesLegacyStorageDir checks whether the pods of the StatefulSet store their
+// data outside of the data volume.
+func usesLegacyStorageDir(sset *v1beta1.StatefulSet) bool {
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		for _, arg := range c.Args {
+			if strings.HasSuffix(arg, "storage.path="+legacyStorageDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func migrationConfigMapName(name string) string {
+	return prefixedName(name) + "-migration"
+}
+
+// exportSilences saves the silences of a running pod of the Alertmanager in
+// a ConfigMap, before the pods using the legacy storage path are replaced.
+// The silences are only exported once, and not at all if no pod is ready.
+// Only silences that didn't expire yet are exported and they have to fit
+// into the 1MiB of a single ConfigMap. The notification log is not migrated.
+func (c *Operator) exportSilences(am *v1alpha1.Alertmanager) error {
+	cclient := c.kclient.CoreV1().ConfigMaps(am.Namespace)
+	_, err := cclient.Get(migrationConfigMapName(am.Name), metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving migration configmap failed")
+	}
+
+	pods, err := c.kclient.CoreV1().Pods(am.Namespace).List(ListOptions(am.Name))
+	if err != nil {
+		return errors.Wrap(err, "retrieving pods failed")
+	}
+	ready := filterReadyPods(pods.Items)
+	if len(ready) == 0 {
+		// Pods that are not ready, e.g. crashlooping ones, hold no silences
+		// that could be saved. The update is not held, as it may be the
+		// fix for them.
+		c.logger.Log("msg", "skipping silence export, no ready pod", "key", am.Namespace+"/"+am.Name, "pods", len(pods.Items))
+		return nil
+	}
+	all, err := getSilences(am, ready[0])
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	sils := []silence{}
+	for _, s := range all {
+		if s.EndsAt.After(now) {
+			sils = append(sils, s)
+		}
+	}
+	b, err := json.Marshal(sils)
+	if err != nil {
+		return err
+	}
+	if len(b) > maxExportSize {
+		return fmt.Errorf("%d silences of %d bytes exceed the %d bytes of the migration configmap", len(sils), len(b), maxExportSize)
+	}
+
+	_, err = cclient.Create(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: migrationConfigMapName(am.Name),
+			Labels: map[string]string{
+				"app":          "alertmanager",
+				"alertmanager": am.Name,
+			},
+		},
+		Data: map[string]string{
+			silencesFilename: string(b),
+		},
+	})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "creating migration configmap failed")
+	}
+	c.logger.Log("msg", "exported silences for storage migration", "key", am.Namespace+"/"+am.Name, "silences", len(sils))
+	return nil
+}
+
+// importSilences creates the silences exported before the pods were replaced
+// that the updated pods don't know through their peers. The ConfigMap holding
+// them is deleted afterwards. Once there is nothing to import, the
+// Alertmanager is remembered as migrated and the ConfigMap isn't looked up
+// again.
+func (c *Operator) importSilences(am *v1alpha1.Alertmanager) error {
+	key := am.Namespace + "/" + am.Name
+	c.migratedMtx.Lock()
+	_, done := c.migrated[key]
+	c.migratedMtx.Unlock()
+	if done {
+		return nil
+	}
+
+	cclient := c.kclient.CoreV1().ConfigMaps(am.Namespace)
+	cm, err := cclient.Get(migrationConfigMapName(am.Name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		c.setMigrated(key)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving migration configmap failed")
+	}
+
+	var exported []silence
+	if err := json.Unmarshal([]byte(cm.Data[silencesFilename]), &exported); err != nil {
+		c.logger.Log("msg", "discarding invalid exported silences", "key", key, "err", err)
+		exported = nil
+	}
+
+	if len(exported) > 0 {
+		pods, err := c.readyPods(am)
+		if err != nil {
+			return err
+		}
+		pod := pods[0]
+		current, err := getSilences(am, pod)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+	outer:
+		for _, s := range exported {
+			if s.EndsAt.Before(now) {
+				continue
+			}
+			for _, cur := range current {
+				if s.sameAs(cur) {
+					continue outer
+				}
+			}
+			if s.StartsAt.Before(now) {
+				s.StartsAt = now
+			}
+			if _, err := postSilence(am, pod, s); err != nil {
+				c.logger.Log("msg", "importing silence failed", "key", key, "comment", s.Comment, "err", err)
+			}
+		}
+	}
+
+	if err := cclient.Delete(cm.Name, nil); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "deleting migration configmap failed")
+	}
+	c.setMigrated(key)
+	c.logger.Log("msg", "imported silences after storage migration", "key", key)
+	return nil
+}
+
+func (c *Operator) setMigrated(key string) {
+	c.migratedMtx.Lock()
+	c.migrated[key] = struct{}{}
+	c.migratedMtx.Unlock()
+}
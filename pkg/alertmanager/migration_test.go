@@ -0,0 +1,88 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+)
+
+func TestExportSilencesWithoutReadyPods(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mtx.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/monitoring/pods" {
+			json.NewEncoder(w).Encode(&v1.PodList{
+				TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"},
+				Items: []v1.Pod{{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "alertmanager-main-0"},
+					Status: v1.PodStatus{
+						Phase: v1.PodRunning,
+						PodIP: "10.1.2.3",
+						Conditions: []v1.PodCondition{
+							{Type: v1.PodReady, Status: v1.ConditionFalse},
+						},
+					},
+				}},
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(&metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Reason:   metav1.StatusReasonNotFound,
+			Code:     http.StatusNotFound,
+		})
+	}))
+	defer srv.Close()
+
+	kclient, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Operator{kclient: kclient, logger: log.NewNopLogger()}
+	am := &v1alpha1.Alertmanager{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "main"},
+	}
+
+	// Crashlooping pods must not hold the update that may fix them.
+	if err := c.exportSilences(am); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, r := range requests {
+		if r[:4] != "GET " {
+			t.Errorf("unexpected request %s", r)
+		}
+	}
+}
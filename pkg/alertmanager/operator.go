@@ -15,14 +15,18 @@
 package alertmanager
 
 import (
+	"bytes"
 	"fmt"
-	"reflect"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/prometheus-operator/pkg/analytics"
 	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
 	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+	"github.com/coreos/prometheus-operator/pkg/listwatch"
 	prometheusoperator "github.com/coreos/prometheus-operator/pkg/prometheus"
 
 	"github.com/coreos/prometheus-operator/third_party/workqueue"
@@ -34,9 +38,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/pkg/api"
 	"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/apis/apps/v1beta1"
 	extensionsobj "k8s.io/client-go/pkg/apis/extensions/v1beta1"
@@ -44,7 +49,8 @@ import (
 )
 
 const (
-	tprAlertmanager = "alertmanager." + v1alpha1.TPRGroup
+	tprAlertmanager       = "alertmanager." + v1alpha1.TPRGroup
+	tprAlertmanagerConfig = "alertmanager-config." + v1alpha1.TPRGroup
 
 	resyncPeriod = 5 * time.Minute
 )
@@ -58,8 +64,35 @@ type Operator struct {
 
 	alrtInf cache.SharedIndexInformer
 	ssetInf cache.SharedIndexInformer
+	amcInf  cache.SharedIndexInformer
+	secrInf cache.SharedIndexInformer
+	silInf  cache.SharedIndexInformer
 
 	queue workqueue.RateLimitingInterface
+	// silQueue holds the keys of Silences, which are synced independently
+	// of the Alertmanager objects.
+	silQueue workqueue.RateLimitingInterface
+
+	// deletedSilences holds the last state of deleted Silences until their
+	// silence is expired.
+	deletedSilences    map[string]*v1alpha1.Silence
+	deletedSilencesMtx sync.Mutex
+	// silenceSyncMtx is held while a Silence is synced, so that silences
+	// are not taken for orphans before their ID is recorded.
+	silenceSyncMtx sync.Mutex
+	// orphansExpired holds the keys of Alertmanagers whose orphaned
+	// silences were expired since the operator started.
+	orphansExpired    map[string]struct{}
+	orphansExpiredMtx sync.Mutex
+
+	// migrated holds the keys of Alertmanagers whose silences don't need to
+	// be imported after a storage migration.
+	migrated    map[string]struct{}
+	migratedMtx sync.Mutex
+
+	// ssetGroupVersion is the StatefulSet API used for rolling updates. If
+	// empty, pods are updated by deleting them.
+	ssetGroupVersion string
 
 	config Config
 }
@@ -68,6 +101,13 @@ type Config struct {
 	Host                         string
 	ConfigReloaderImage          string
 	AlertmanagerDefaultBaseImage string
+	Namespaces                   []string
+	DenyNamespaces               []string
+	ManageTPRs                   bool
+	AlertmanagerSelector         string
+	OperatorInstance             string
+	Workers                      int
+	LabeledConfigObjectsOnly     bool
 }
 
 // New creates a new controller.
@@ -86,25 +126,87 @@ func New(c prometheusoperator.Config, logger log.Logger) (*Operator, error) {
 		return nil, errors.Wrap(err, "instantiating monitoring client failed")
 	}
 
+	if _, err := labels.Parse(c.AlertmanagerSelector); err != nil {
+		return nil, errors.Wrap(err, "parsing Alertmanager instance selector failed")
+	}
+
 	o := &Operator{
-		kclient: client,
-		mclient: mclient,
-		logger:  logger,
-		queue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "alertmanager"),
-		config:  Config{Host: c.Host, ConfigReloaderImage: c.ConfigReloaderImage, AlertmanagerDefaultBaseImage: c.AlertmanagerDefaultBaseImage},
+		kclient:         client,
+		mclient:         mclient,
+		logger:          logger,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "alertmanager"),
+		silQueue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "silence"),
+		deletedSilences: map[string]*v1alpha1.Silence{},
+		migrated:        map[string]struct{}{},
+		orphansExpired:  map[string]struct{}{},
+		config: Config{
+			Host:                         c.Host,
+			ConfigReloaderImage:          c.ConfigReloaderImage,
+			AlertmanagerDefaultBaseImage: c.AlertmanagerDefaultBaseImage,
+			Namespaces:                   c.Namespaces,
+			DenyNamespaces:               c.DenyNamespaces,
+			ManageTPRs:                   c.ManageTPRs,
+			AlertmanagerSelector:         c.AlertmanagerSelector,
+			OperatorInstance:             c.OperatorInstance,
+			Workers:                      c.Workers,
+			LabeledConfigObjectsOnly:     c.LabeledConfigObjectsOnly,
+		},
 	}
 
 	o.alrtInf = cache.NewSharedIndexInformer(
-		&cache.ListWatch{
-			ListFunc:  o.mclient.Alertmanagers(api.NamespaceAll).List,
-			WatchFunc: o.mclient.Alertmanagers(api.NamespaceAll).Watch,
-		},
+		o.listWatch(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					options.LabelSelector = o.config.AlertmanagerSelector
+					return o.mclient.Alertmanagers(ns).List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					options.LabelSelector = o.config.AlertmanagerSelector
+					return o.mclient.Alertmanagers(ns).Watch(options)
+				},
+			}
+		}),
 		&v1alpha1.Alertmanager{}, resyncPeriod, cache.Indexers{},
 	)
 	o.ssetInf = cache.NewSharedIndexInformer(
-		cache.NewListWatchFromClient(o.kclient.Apps().RESTClient(), "statefulsets", api.NamespaceAll, nil),
+		o.listWatch(func(ns string) cache.ListerWatcher {
+			return cache.NewListWatchFromClient(o.kclient.Apps().RESTClient(), "statefulsets", ns, nil)
+		}),
 		&v1beta1.StatefulSet{}, resyncPeriod, cache.Indexers{},
 	)
+	o.amcInf = cache.NewSharedIndexInformer(
+		o.listWatch(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return o.mclient.AlertmanagerConfigs(ns).List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return o.mclient.AlertmanagerConfigs(ns).Watch(options)
+				},
+			}
+		}),
+		&v1alpha1.AlertmanagerConfig{}, resyncPeriod, cache.Indexers{},
+	)
+	o.secrInf = cache.NewSharedIndexInformer(
+		o.listWatch(func(ns string) cache.ListerWatcher {
+			return listwatch.WithLabelSelector(
+				cache.NewListWatchFromClient(o.kclient.Core().RESTClient(), "secrets", ns, nil),
+				o.configObjectsSelector(),
+			)
+		}),
+		&v1.Secret{}, resyncPeriod, cache.Indexers{},
+	)
+	o.silInf = cache.NewSharedIndexInformer(
+		o.listWatch(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc:  o.mclient.Silences(ns).List,
+				WatchFunc: o.mclient.Silences(ns).Watch,
+			}
+		}),
+		&v1alpha1.Silence{}, resyncPeriod, cache.Indexers{
+			silenceAlertmanagerIndex: silenceAlertmanager,
+		},
+	)
 
 	o.alrtInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    o.handleAlertmanagerAdd,
@@ -116,17 +218,57 @@ func New(c prometheusoperator.Config, logger log.Logger) (*Operator, error) {
 		DeleteFunc: o.handleStatefulSetDelete,
 		UpdateFunc: o.handleStatefulSetUpdate,
 	})
+	o.amcInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.handleAlertmanagerConfigAdd,
+		DeleteFunc: o.handleAlertmanagerConfigDelete,
+		UpdateFunc: o.handleAlertmanagerConfigUpdate,
+	})
+	o.secrInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.handleSecretAdd,
+		DeleteFunc: o.handleSecretDelete,
+		UpdateFunc: o.handleSecretUpdate,
+	})
+	o.silInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.handleSilenceAdd,
+		DeleteFunc: o.handleSilenceDelete,
+		UpdateFunc: o.handleSilenceUpdate,
+	})
 
 	return o, nil
 }
 
+// listWatch restricts the ListerWatchers returned by f to the namespaces the
+// operator is configured to manage.
+func (c *Operator) listWatch(f listwatch.ListerWatcherFunc) cache.ListerWatcher {
+	return listwatch.MultiNamespaceListerWatcher(c.config.Namespaces, c.config.DenyNamespaces, f)
+}
+
+// configObjectsSelector returns the label selector for the Secrets cached by
+// the operator.
+func (c *Operator) configObjectsSelector() string {
+	if c.config.LabeledConfigObjectsOnly {
+		return prometheusoperator.WatchedLabel
+	}
+	return ""
+}
+
 func (c *Operator) RegisterMetrics(r prometheus.Registerer) {
-	r.MustRegister(NewAlertmanagerCollector(c.alrtInf.GetStore()))
+	r.MustRegister(
+		NewAlertmanagerCollector(c.alrtInf.GetStore()),
+		prometheusoperator.NewInformerCacheCollector("alertmanager", map[string]cache.Store{
+			"alertmanagers":       c.alrtInf.GetStore(),
+			"statefulsets":        c.ssetInf.GetStore(),
+			"alertmanagerconfigs": c.amcInf.GetStore(),
+			"secrets":             c.secrInf.GetStore(),
+			"silences":            c.silInf.GetStore(),
+		}),
+	)
 }
 
 // Run the controller.
 func (c *Operator) Run(stopc <-chan struct{}) error {
 	defer c.queue.ShutDown()
+	defer c.silQueue.ShutDown()
 
 	errChan := make(chan error)
 	go func() {
@@ -137,10 +279,22 @@ func (c *Operator) Run(stopc <-chan struct{}) error {
 		}
 		c.logger.Log("msg", "connection established", "cluster-version", v)
 
-		if err := c.createTPRs(); err != nil {
-			errChan <- err
+		gv, err := k8sutil.RollingUpdateGroupVersion(c.kclient.Discovery())
+		if err != nil {
+			errChan <- errors.Wrap(err, "discovering StatefulSet API failed")
 			return
 		}
+		if gv != "" {
+			c.logger.Log("msg", "using StatefulSet rolling updates", "api-version", gv)
+		}
+		c.ssetGroupVersion = gv
+
+		if c.config.ManageTPRs {
+			if err := c.createTPRs(); err != nil {
+				errChan <- err
+				return
+			}
+		}
 		errChan <- nil
 	}()
 
@@ -154,10 +308,27 @@ func (c *Operator) Run(stopc <-chan struct{}) error {
 		return nil
 	}
 
-	go c.worker()
+	workers := c.config.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
 	go c.alrtInf.Run(stopc)
 	go c.ssetInf.Run(stopc)
+	go c.amcInf.Run(stopc)
+	go c.secrInf.Run(stopc)
+	go c.silInf.Run(stopc)
+
+	// Configurations are validated against the cached Secrets, so a config
+	// Secret missing from an unsynced cache would be reported as invalid.
+	// Silences are skipped while their Alertmanager is not cached.
+	if !cache.WaitForCacheSync(stopc, c.alrtInf.HasSynced, c.amcInf.HasSynced, c.secrInf.HasSynced, c.silInf.HasSynced) {
+		return nil
+	}
+	for i := 0; i < workers; i++ {
+		go c.worker()
+	}
+	go c.silenceWorker()
 
 	<-stopc
 	return nil
@@ -246,6 +417,12 @@ func (c *Operator) alertmanagerForStatefulSet(sset interface{}) *v1alpha1.Alertm
 		return nil
 	}
 
+	// StatefulSets managed by another operator instance are ignored, even if
+	// this instance knows an Alertmanager object of the same name.
+	if o, ok := c.getObject(sset); !ok || !prometheusoperator.IsOperatorInstance(o, c.config.OperatorInstance) {
+		return nil
+	}
+
 	aKey := statefulSetKeyToAlertmanagerKey(key)
 	a, exists, err := c.alrtInf.GetStore().GetByKey(aKey)
 	if err != nil {
@@ -285,6 +462,7 @@ func (c *Operator) handleAlertmanagerAdd(obj interface{}) {
 	analytics.AlertmanagerCreated()
 	c.logger.Log("msg", "Alertmanager added", "key", key)
 	c.enqueue(key)
+	c.enqueueSilencesFor(obj.(*v1alpha1.Alertmanager))
 }
 
 func (c *Operator) handleAlertmanagerDelete(obj interface{}) {
@@ -338,6 +516,99 @@ func (c *Operator) handleStatefulSetUpdate(oldo, curo interface{}) {
 	}
 }
 
+func (c *Operator) handleAlertmanagerConfigAdd(obj interface{}) {
+	c.enqueueForAlertmanagerConfig(obj)
+}
+
+func (c *Operator) handleAlertmanagerConfigDelete(obj interface{}) {
+	c.enqueueForAlertmanagerConfig(obj)
+}
+
+func (c *Operator) handleAlertmanagerConfigUpdate(old, cur interface{}) {
+	c.enqueueForAlertmanagerConfig(old, cur)
+}
+
+// enqueueForAlertmanagerConfig enqueues all Alertmanager objects selecting
+// any of the given versions of an AlertmanagerConfig.
+func (c *Operator) enqueueForAlertmanagerConfig(objs ...interface{}) {
+	var metas []metav1.Object
+	for _, obj := range objs {
+		if o, ok := c.getObject(obj); ok {
+			metas = append(metas, o)
+		}
+	}
+	if len(metas) == 0 {
+		return
+	}
+
+	cache.ListAll(c.alrtInf.GetStore(), labels.Everything(), func(obj interface{}) {
+		am := obj.(*v1alpha1.Alertmanager)
+		if am.Spec.AlertmanagerConfigSelector == nil {
+			return
+		}
+		selector, err := metav1.LabelSelectorAsSelector(am.Spec.AlertmanagerConfigSelector)
+		if err != nil {
+			// The sync surfaces the invalid selector.
+			c.enqueue(am)
+			return
+		}
+		for _, o := range metas {
+			if selector.Matches(labels.Set(o.GetLabels())) {
+				c.enqueue(am)
+				return
+			}
+		}
+	})
+}
+
+func (c *Operator) handleSecretAdd(obj interface{}) {
+	c.enqueueForSecret(obj)
+}
+
+func (c *Operator) handleSecretDelete(obj interface{}) {
+	c.enqueueForSecret(obj)
+}
+
+func (c *Operator) handleSecretUpdate(old, cur interface{}) {
+	if old.(*v1.Secret).ResourceVersion == cur.(*v1.Secret).ResourceVersion {
+		return
+	}
+	c.enqueueForSecret(cur)
+}
+
+// enqueueForSecret enqueues the Alertmanager object using the Secret as base
+// config and all Alertmanager objects selecting an AlertmanagerConfig that
+// references the Secret.
+func (c *Operator) enqueueForSecret(obj interface{}) {
+	o, ok := c.getObject(obj)
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(o.GetName(), "alertmanager-") {
+		key := o.GetNamespace() + "/" + alertmanagerNameFromStatefulSetName(o.GetName())
+		if _, exists, _ := c.alrtInf.GetStore().GetByKey(key); exists {
+			c.enqueue(key)
+		}
+	}
+
+	var referencing []interface{}
+	cache.ListAll(c.amcInf.GetStore(), labels.Everything(), func(obj interface{}) {
+		amc := obj.(*v1alpha1.AlertmanagerConfig)
+		if amc.Namespace != o.GetNamespace() {
+			return
+		}
+		for _, name := range secretRefs(amc) {
+			if name == o.GetName() {
+				referencing = append(referencing, amc)
+				return
+			}
+		}
+	})
+	if len(referencing) > 0 {
+		c.enqueueForAlertmanagerConfig(referencing...)
+	}
+}
+
 func (c *Operator) sync(key string) error {
 	obj, exists, err := c.alrtInf.GetIndexer().GetByKey(key)
 	if err != nil {
@@ -362,13 +633,42 @@ func (c *Operator) sync(key string) error {
 
 	c.logger.Log("msg", "sync alertmanager", "key", key)
 
+	version := am.Spec.Version
+	if version == "" {
+		version = defaultVersion
+	}
+	_, verr := profileForVersion(version)
+	if err := c.updateVersionCondition(am, verr); err != nil {
+		return errors.Wrap(err, "updating version condition failed")
+	}
+	if verr != nil {
+		c.logger.Log("msg", "skipping alertmanager with unsupported version", "key", key, "err", verr)
+		return nil
+	}
+
 	// Create governing service if it doesn't exist.
 	svcClient := c.kclient.Core().Services(am.Namespace)
 	if err = k8sutil.CreateOrUpdateService(svcClient, makeStatefulSetService(am)); err != nil {
 		return errors.Wrap(err, "synchronizing governing service failed")
 	}
 
-	ssetClient := c.kclient.Apps().StatefulSets(am.Namespace)
+	if err = k8sutil.CreateOrUpdateService(svcClient, makePeerService(am, c.config)); err != nil {
+		return errors.Wrap(err, "synchronizing peer service failed")
+	}
+
+	if err := c.syncConfig(am); err != nil {
+		return errors.Wrap(err, "synchronizing generated configuration failed")
+	}
+
+	if err := c.syncPodDisruptionBudget(am); err != nil {
+		return errors.Wrap(err, "synchronizing pod disruption budget failed")
+	}
+
+	if err := c.syncService(am); err != nil {
+		return errors.Wrap(err, "synchronizing service failed")
+	}
+
+	ssetClient := k8sutil.StatefulSets(c.kclient, am.Namespace, c.ssetGroupVersion)
 	// Ensure we have a StatefulSet running Alertmanager deployed.
 	obj, exists, err = c.ssetInf.GetIndexer().GetByKey(alertmanagerKeyToStatefulSetKey(key))
 	if err != nil {
@@ -386,15 +686,44 @@ func (c *Operator) sync(key string) error {
 		return nil
 	}
 
+	if o := obj.(*v1beta1.StatefulSet); !prometheusoperator.IsOperatorInstance(o, c.config.OperatorInstance) {
+		return fmt.Errorf("statefulset is managed by operator instance %q", o.Labels[prometheusoperator.OperatorInstanceLabel])
+	}
 	sset, err := makeStatefulSet(am, obj.(*v1beta1.StatefulSet), c.config)
 	if err != nil {
 		return errors.Wrap(err, "making the statefulset, to update, failed")
 	}
-	if _, err := ssetClient.Update(sset); err != nil {
-		return errors.Wrap(err, "updating statefulset failed")
+	if c.ssetGroupVersion != "" {
+		k8sutil.HoldPodTemplateChange(obj.(*v1beta1.StatefulSet), sset)
+	}
+	// Increased storage requests are applied to the existing persistent
+	// volume claims, as the volume claim templates can't be updated.
+	claimTemplates := sset.Spec.VolumeClaimTemplates
+	sset.Spec.VolumeClaimTemplates = k8sutil.KeepExpandedClaimTemplates(obj.(*v1beta1.StatefulSet).Spec.VolumeClaimTemplates, claimTemplates)
+	if usesLegacyStorageDir(obj.(*v1beta1.StatefulSet)) {
+		// Silences are lost once the pods using the legacy storage path are
+		// replaced, so they are exported first and imported into the
+		// updated pods. The pods are not replaced until the export
+		// succeeded.
+		if err := c.exportSilences(am); err != nil {
+			return errors.Wrap(err, "exporting silences for storage migration failed")
+		}
+	}
+	deleting, err := k8sutil.UpdateStatefulSet(ssetClient, obj.(*v1beta1.StatefulSet), sset, k8sutil.RecreateStatefulSetAllowed(am))
+	if err != nil {
+		return err
+	}
+	if deleting {
+		// The StatefulSet is created again once its deletion is observed.
+		c.logger.Log("msg", "recreating statefulset", "key", key)
+		return nil
 	}
 
-	return c.syncVersion(am)
+	if err := c.syncClaims(am, sset, claimTemplates); err != nil {
+		return errors.Wrap(err, "syncing persistent volume claims failed")
+	}
+
+	return c.syncVersion(key, am)
 }
 
 func ListOptions(name string) metav1.ListOptions {
@@ -411,8 +740,16 @@ func ListOptions(name string) metav1.ListOptions {
 // create new pods.
 //
 // TODO(fabxc): remove this once the StatefulSet controller learns how to do rolling updates.
-func (c *Operator) syncVersion(a *v1alpha1.Alertmanager) error {
-	status, oldPods, err := AlertmanagerStatus(c.kclient, a)
+func (c *Operator) syncVersion(key string, a *v1alpha1.Alertmanager) error {
+	pods, err := c.kclient.Core().Pods(a.Namespace).List(ListOptions(a.Name))
+	if err != nil {
+		return errors.Wrap(err, "retrieving pods of failed")
+	}
+	sset, err := c.kclient.Apps().StatefulSets(a.Namespace).Get(statefulSetNameFromAlertmanagerName(a.Name), metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "retrieving stateful set failed")
+	}
+	status, oldPods, err := alertmanagerStatus(a, pods.Items, sset)
 	if err != nil {
 		return errors.Wrap(err, "retrieving Alertmanager status failed")
 	}
@@ -430,26 +767,58 @@ func (c *Operator) syncVersion(a *v1alpha1.Alertmanager) error {
 		return nil
 	}
 	if len(oldPods) == 0 {
+		if err := c.importSilences(a); err != nil {
+			return err
+		}
+		if err := c.expireOrphanedSilences(a); err != nil {
+			c.logger.Log("msg", "expiring orphaned silences failed", "key", key, "err", err)
+		}
 		return nil
 	}
-	if status.UnavailableReplicas > 0 {
-		return fmt.Errorf("waiting for %d unavailable pods to become ready", status.UnavailableReplicas)
-	}
 
-	// TODO(fabxc): delete oldest pod first.
-	if err := c.kclient.Core().Pods(a.Namespace).Delete(oldPods[0].Name, nil); err != nil {
+	vp, err := profileForAlertmanager(a)
+	if err != nil {
+		return err
+	}
+	webRoutePrefix := "/"
+	if a.Spec.RoutePrefix != "" {
+		webRoutePrefix = a.Spec.RoutePrefix
+	}
+	u := k8sutil.NewRollingUpdate(a.Spec.UpdateStrategy, 9093, path.Clean(webRoutePrefix+vp.probePath))
+	if c.ssetGroupVersion != "" {
+		// The StatefulSet controller updates the pods from the highest
+		// ordinal down to the partition.
+		u.Strategy.Order = v1alpha1.UpdateOrderOrdinal
+	}
+	next, retryAfter, err := u.Next(int(expectedReplicas), pods.Items, oldPods)
+	if err != nil {
 		return err
 	}
+	if len(next) == 0 {
+		c.queue.AddAfter(key, retryAfter)
+		return nil
+	}
+
+	if c.ssetGroupVersion != "" {
+		ssetClient := k8sutil.StatefulSets(c.kclient, a.Namespace, c.ssetGroupVersion)
+		if err := k8sutil.LowerPartition(ssetClient, sset, next); err != nil {
+			return err
+		}
+	} else {
+		for _, pod := range next {
+			if err := c.kclient.Core().Pods(a.Namespace).Delete(pod.Name, nil); err != nil {
+				return err
+			}
+		}
+	}
 	// If there are further pods that need updating, we enqueue ourselves again.
-	if len(oldPods) > 1 {
-		return fmt.Errorf("%d out-of-date pods remaining", len(oldPods)-1)
+	if len(oldPods) > len(next) {
+		return fmt.Errorf("%d out-of-date pods remaining", len(oldPods)-len(next))
 	}
 	return nil
 }
 
 func AlertmanagerStatus(kclient *kubernetes.Clientset, a *v1alpha1.Alertmanager) (*v1alpha1.AlertmanagerStatus, []v1.Pod, error) {
-	res := &v1alpha1.AlertmanagerStatus{Paused: a.Spec.Paused}
-
 	pods, err := kclient.Core().Pods(a.Namespace).List(ListOptions(a.Name))
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "retrieving pods of failed")
@@ -459,18 +828,31 @@ func AlertmanagerStatus(kclient *kubernetes.Clientset, a *v1alpha1.Alertmanager)
 		return nil, nil, errors.Wrap(err, "retrieving stateful set failed")
 	}
 
-	res.Replicas = int32(len(pods.Items))
+	res, oldPods, err := alertmanagerStatus(a, pods.Items, sset)
+	if err != nil {
+		return nil, nil, err
+	}
+	res.VolumeExpansions, err = k8sutil.ClaimExpansions(kclient.CoreV1().PersistentVolumeClaims(a.Namespace), sset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, oldPods, nil
+}
+
+func alertmanagerStatus(a *v1alpha1.Alertmanager, pods []v1.Pod, sset *v1beta1.StatefulSet) (*v1alpha1.AlertmanagerStatus, []v1.Pod, error) {
+	res := &v1alpha1.AlertmanagerStatus{Paused: a.Spec.Paused}
+	res.Replicas = int32(len(pods))
+	res.RecreatePending = k8sutil.IsRecreatePending(sset)
 
 	var oldPods []v1.Pod
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		ready, err := k8sutil.PodRunningAndReady(pod)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "cannot determine pod ready state")
 		}
 		if ready {
 			res.AvailableReplicas++
-			// TODO(fabxc): detect other fields of the pod template that are mutable.
-			if needsUpdate(&pod, sset.Spec.Template) {
+			if k8sutil.PodNeedsUpdate(&pod, sset.Spec.Template) {
 				oldPods = append(oldPods, pod)
 			} else {
 				res.UpdatedReplicas++
@@ -483,21 +865,15 @@ func AlertmanagerStatus(kclient *kubernetes.Clientset, a *v1alpha1.Alertmanager)
 	return res, oldPods, nil
 }
 
-func needsUpdate(pod *v1.Pod, tmpl v1.PodTemplateSpec) bool {
-	c1 := pod.Spec.Containers[0]
-	c2 := tmpl.Spec.Containers[0]
-
-	if c1.Image != c2.Image {
-		return true
-	}
-
-	if !reflect.DeepEqual(c1.Args, c2.Args) {
-		return true
-	}
-
-	return false
+// isOperatorInstance checks whether the object is managed by this operator
+// instance.
+func (c *Operator) isOperatorInstance(o metav1.Object) bool {
+	return prometheusoperator.IsOperatorInstance(o, c.config.OperatorInstance)
 }
 
+// destroyAlertmanager deletes the objects created for a deleted Alertmanager.
+// The objects are kept if they are managed by another operator instance, or
+// the Alertmanager still exists.
 func (c *Operator) destroyAlertmanager(key string) error {
 	ssetKey := alertmanagerKeyToStatefulSetKey(key)
 	obj, exists, err := c.ssetInf.GetStore().GetByKey(ssetKey)
@@ -508,6 +884,18 @@ func (c *Operator) destroyAlertmanager(key string) error {
 		return nil
 	}
 	sset := obj.(*v1beta1.StatefulSet)
+	if !c.isOperatorInstance(sset) {
+		c.logger.Log("msg", "skipping destruction, statefulset is managed by another operator instance", "key", key, "instance", sset.Labels[prometheusoperator.OperatorInstanceLabel])
+		return nil
+	}
+	// The Alertmanager is only gone from the cache if it was handed over to
+	// another operator instance, or no longer matches the selector.
+	if _, err := c.mclient.Alertmanagers(sset.Namespace).Get(alertmanagerNameFromStatefulSetName(sset.Name)); err == nil {
+		c.logger.Log("msg", "skipping destruction, Alertmanager still exists", "key", key)
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Alertmanager failed")
+	}
 	*sset.Spec.Replicas = 0
 
 	// Update the replica count to 0 and wait for all pods to be deleted.
@@ -537,9 +925,220 @@ func (c *Operator) destroyAlertmanager(key string) error {
 		return errors.Wrap(err, "deleting statefulset failed")
 	}
 
+	if k8sutil.DeleteClaimsOnDestroy(sset) {
+		if err := k8sutil.DeleteClaims(c.kclient.CoreV1().PersistentVolumeClaims(sset.Namespace), sset); err != nil {
+			return err
+		}
+	}
+
+	pclient := c.kclient.PolicyV1beta1().PodDisruptionBudgets(sset.Namespace)
+	if err := k8sutil.DeletePodDisruptionBudgets(pclient, ListOptions(alertmanagerNameFromStatefulSetName(sset.Name)), c.isOperatorInstance); err != nil {
+		return err
+	}
+
+	if err := k8sutil.DeleteIngress(c.kclient.ExtensionsV1beta1().Ingresses(sset.Namespace), sset.Name, c.isOperatorInstance); err != nil {
+		return err
+	}
+	sclient := c.kclient.CoreV1().Secrets(sset.Namespace)
+	if err := k8sutil.DeleteSecret(sclient, generatedConfigSecretName(alertmanagerNameFromStatefulSetName(sset.Name))); err != nil {
+		return err
+	}
+	// The default configuration is deleted, unless it was changed since.
+	s, err := sclient.Get(configSecretName(alertmanagerNameFromStatefulSetName(sset.Name)), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving config secret failed")
+	}
+	if err == nil && isDefaultConfig(s) {
+		if err := k8sutil.DeleteSecret(sclient, s.Name); err != nil {
+			return err
+		}
+	}
+	err = c.kclient.CoreV1().ConfigMaps(sset.Namespace).Delete(migrationConfigMapName(alertmanagerNameFromStatefulSetName(sset.Name)), nil)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "deleting migration configmap failed")
+	}
+	c.migratedMtx.Lock()
+	delete(c.migrated, sset.Namespace+"/"+alertmanagerNameFromStatefulSetName(sset.Name))
+	c.migratedMtx.Unlock()
+	c.orphansExpiredMtx.Lock()
+	delete(c.orphansExpired, sset.Namespace+"/"+alertmanagerNameFromStatefulSetName(sset.Name))
+	c.orphansExpiredMtx.Unlock()
+	if err := k8sutil.DeleteService(c.kclient.CoreV1().Services(sset.Namespace), peerServiceName(alertmanagerNameFromStatefulSetName(sset.Name)), c.isOperatorInstance); err != nil {
+		return err
+	}
+	return k8sutil.DeleteService(c.kclient.CoreV1().Services(sset.Namespace), sset.Name, c.isOperatorInstance)
+}
+
+// updateVersionCondition records on the Alertmanager object whether its
+// version is supported. The condition is only recorded once a version was
+// rejected, so that supported versions don't cause status updates.
+func (c *Operator) updateVersionCondition(am *v1alpha1.Alertmanager, verr error) error {
+	if verr == nil && (am.Status == nil || !k8sutil.HasCondition(am.Status.Conditions, v1alpha1.ConditionVersionSupported)) {
+		return nil
+	}
+	_, err := c.updateCondition(am, k8sutil.VersionSupportedCondition(verr))
+	return err
+}
+
+// updateConfigCondition records on the Alertmanager object whether its
+// configuration is valid.
+func (c *Operator) updateConfigCondition(am *v1alpha1.Alertmanager, verr error) error {
+	return c.updateConditionWithEvent(am, k8sutil.ConfigValidCondition(verr))
+}
+
+// updateConvertedCondition records on the Alertmanager object the
+// AlertmanagerConfigs left out of its configuration, as they could not be
+// converted. The condition is only recorded once an AlertmanagerConfig was
+// skipped.
+func (c *Operator) updateConvertedCondition(am *v1alpha1.Alertmanager, skipped map[string]error) error {
+	if len(skipped) == 0 && (am.Status == nil || !k8sutil.HasCondition(am.Status.Conditions, v1alpha1.ConditionAlertmanagerConfigsConverted)) {
+		return nil
+	}
+	return c.updateConditionWithEvent(am, k8sutil.AlertmanagerConfigsConvertedCondition(skipped))
+}
+
+// updateDefaultConfigCondition records on the Alertmanager object whether it
+// runs the default configuration. The condition is only recorded once the
+// default configuration was used.
+func (c *Operator) updateDefaultConfigCondition(am *v1alpha1.Alertmanager, base *v1.Secret) error {
+	provided := base == nil || !isDefaultConfig(base)
+	if provided && (am.Status == nil || !k8sutil.HasCondition(am.Status.Conditions, v1alpha1.ConditionConfigProvided)) {
+		return nil
+	}
+	return c.updateConditionWithEvent(am, k8sutil.ConfigProvidedCondition(configSecretName(am.Name), provided))
+}
+
+// syncClaims expands the persistent volume claims of the StatefulSet to the
+// storage requests of the volume claim templates and records the progress in
+// the VolumesExpanded condition.
+func (c *Operator) syncClaims(am *v1alpha1.Alertmanager, sset *v1beta1.StatefulSet, templates []v1.PersistentVolumeClaim) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	expandErr := k8sutil.ExpandClaims(c.kclient, sset, templates)
+	if expandErr != nil {
+		c.logger.Log("msg", "expanding persistent volume claims failed", "key", am.Namespace+"/"+am.Name, "err", expandErr)
+	}
+	pending, err := k8sutil.ClaimExpansions(c.kclient.CoreV1().PersistentVolumeClaims(am.Namespace), sset)
+	if err != nil {
+		return err
+	}
+	return c.updateVolumesCondition(am, k8sutil.VolumesExpandedCondition(pending, expandErr))
+}
+
+// updateVolumesCondition records on the Alertmanager object the progress of
+// expanding its persistent volume claims. The condition is only recorded once
+// claims were pending or failed to expand.
+func (c *Operator) updateVolumesCondition(am *v1alpha1.Alertmanager, cond v1alpha1.Condition) error {
+	if cond.Status == v1.ConditionTrue && (am.Status == nil || !k8sutil.HasCondition(am.Status.Conditions, v1alpha1.ConditionVolumesExpanded)) {
+		return nil
+	}
+	return c.updateConditionWithEvent(am, cond)
+}
+
+// updateConditionWithEvent sets the condition on the Alertmanager object and
+// records its changes as Events. No Event is recorded for a condition that is
+// true when first set.
+func (c *Operator) updateConditionWithEvent(am *v1alpha1.Alertmanager, cond v1alpha1.Condition) error {
+	recorded := am.Status != nil && k8sutil.HasCondition(am.Status.Conditions, cond.Type)
+	changed, err := c.updateCondition(am, cond)
+	if err != nil || !changed {
+		return err
+	}
+	if !recorded && cond.Status == v1.ConditionTrue {
+		return nil
+	}
+
+	eventType := v1.EventTypeNormal
+	if cond.Status != v1.ConditionTrue {
+		eventType = v1.EventTypeWarning
+	}
+	ref := v1.ObjectReference{
+		Kind:       v1alpha1.TPRAlertmanagersKind,
+		APIVersion: v1alpha1.TPRGroup + "/" + v1alpha1.TPRVersion,
+		Namespace:  am.Namespace,
+		Name:       am.Name,
+		UID:        am.UID,
+	}
+	if err := k8sutil.RecordEvent(c.kclient.CoreV1().Events(am.Namespace), ref, eventType, cond.Reason, cond.Message); err != nil {
+		c.logger.Log("msg", "recording event failed", "key", am.Namespace+"/"+am.Name, "err", err)
+	}
 	return nil
 }
 
+// updateCondition sets the condition in the status of the Alertmanager
+// object. It returns whether the condition changed. The operator does not
+// maintain the status of Alertmanager objects otherwise, so the remaining
+// status is computed when a condition changes.
+func (c *Operator) updateCondition(am *v1alpha1.Alertmanager, cond v1alpha1.Condition) (bool, error) {
+	var conds []v1alpha1.Condition
+	if am.Status != nil {
+		conds = am.Status.Conditions
+	}
+	if _, changed := k8sutil.SetCondition(conds, cond); !changed {
+		return false, nil
+	}
+
+	cur, err := c.mclient.Alertmanagers(am.Namespace).Get(am.Name)
+	if err != nil {
+		return false, err
+	}
+	status, _, err := AlertmanagerStatus(c.kclient, cur)
+	if err != nil {
+		status = &v1alpha1.AlertmanagerStatus{Paused: cur.Spec.Paused}
+	}
+	conds = nil
+	if cur.Status != nil {
+		conds = cur.Status.Conditions
+	}
+	status.Conditions, _ = k8sutil.SetCondition(conds, cond)
+	cur.Status = status
+
+	if _, err := c.mclient.Alertmanagers(am.Namespace).Update(cur); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// syncService creates, updates or deletes the Service and Ingress of the
+// Alertmanager object.
+func (c *Operator) syncService(am *v1alpha1.Alertmanager) error {
+	name := prefixedName(am.Name)
+	sclient := c.kclient.CoreV1().Services(am.Namespace)
+	iclient := c.kclient.ExtensionsV1beta1().Ingresses(am.Namespace)
+
+	if am.Spec.Service == nil && am.Spec.Ingress == nil {
+		if err := k8sutil.DeleteIngress(iclient, name, c.isOperatorInstance); err != nil {
+			return err
+		}
+		return k8sutil.DeleteService(sclient, name, c.isOperatorInstance)
+	}
+
+	if err := k8sutil.CreateOrUpdateService(sclient, makeService(am, c.config)); err != nil {
+		return err
+	}
+	if am.Spec.Ingress == nil {
+		return k8sutil.DeleteIngress(iclient, name, c.isOperatorInstance)
+	}
+	ing, err := makeIngress(am, c.config)
+	if err != nil {
+		return err
+	}
+	return k8sutil.CreateOrUpdateIngress(iclient, ing)
+}
+
+// syncPodDisruptionBudget creates, updates or deletes the PodDisruptionBudget
+// of the Alertmanager object.
+func (c *Operator) syncPodDisruptionBudget(am *v1alpha1.Alertmanager) error {
+	pclient := c.kclient.PolicyV1beta1().PodDisruptionBudgets(am.Namespace)
+
+	pdb, err := makePodDisruptionBudget(am, c.config)
+	if err != nil {
+		return err
+	}
+	return k8sutil.SyncPodDisruptionBudget(pclient, ListOptions(am.Name), pdb)
+}
+
 func (c *Operator) createTPRs() error {
 	tprs := []*extensionsobj.ThirdPartyResource{
 		{
@@ -551,6 +1150,24 @@ func (c *Operator) createTPRs() error {
 			},
 			Description: "Managed Alertmanager cluster",
 		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: tprAlertmanagerConfig,
+			},
+			Versions: []extensionsobj.APIVersion{
+				{Name: v1alpha1.TPRVersion},
+			},
+			Description: "Alertmanager routes, receivers and inhibit rules of a namespace",
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: tprSilence,
+			},
+			Versions: []extensionsobj.APIVersion{
+				{Name: v1alpha1.TPRVersion},
+			},
+			Description: "Silence of alerts in an Alertmanager",
+		},
 	}
 	tprClient := c.kclient.Extensions().ThirdPartyResources()
 
@@ -562,5 +1179,160 @@ func (c *Operator) createTPRs() error {
 	}
 
 	// We have to wait for the TPRs to be ready. Otherwise the initial watch may fail.
-	return k8sutil.WaitForTPRReady(c.kclient.CoreV1().RESTClient(), v1alpha1.TPRGroup, v1alpha1.TPRVersion, v1alpha1.TPRAlertmanagerName)
+	err := k8sutil.WaitForTPRReady(c.kclient.CoreV1().RESTClient(), v1alpha1.TPRGroup, v1alpha1.TPRVersion, v1alpha1.TPRAlertmanagerName)
+	if err != nil {
+		return err
+	}
+	err = k8sutil.WaitForTPRReady(c.kclient.CoreV1().RESTClient(), v1alpha1.TPRGroup, v1alpha1.TPRVersion, v1alpha1.TPRAlertmanagerConfigName)
+	if err != nil {
+		return err
+	}
+	return k8sutil.WaitForTPRReady(c.kclient.CoreV1().RESTClient(), v1alpha1.TPRGroup, v1alpha1.TPRVersion, v1alpha1.TPRSilenceName)
+}
+
+// syncConfig validates the configuration of the Alertmanager and records the
+// result in the ConfigValid condition. For an Alertmanager selecting
+// AlertmanagerConfigs, the configuration is generated from its base config
+// Secret and the selected AlertmanagerConfigs, which are skipped if they
+// can't be converted. If the Alertmanager selects AlertmanagerConfigs or
+// mounts validated configurations only, valid configurations are written to
+// the generated Secret. Otherwise the generated Secret is deleted.
+func (c *Operator) syncConfig(am *v1alpha1.Alertmanager) error {
+	// Further files of the base config Secret, like templates, are copied
+	// to the generated Secret.
+	data := map[string][]byte{}
+	obj, exists, err := c.secrInf.GetIndexer().GetByKey(am.Namespace + "/" + configSecretName(am.Name))
+	if err != nil {
+		return errors.Wrap(err, "retrieving base config secret from cache failed")
+	}
+	var base *v1.Secret
+	if exists {
+		base = obj.(*v1.Secret)
+	} else if am.Spec.AlertmanagerConfigSelector == nil {
+		// Without a config Secret the pods can't start, so a default
+		// configuration is provided.
+		base, err = c.createDefaultConfig(am)
+		if err != nil {
+			return errors.Wrap(err, "creating default config secret failed")
+		}
+	}
+	if base != nil {
+		for k, v := range base.Data {
+			data[k] = v
+		}
+	}
+	if err := c.updateDefaultConfigCondition(am, base); err != nil {
+		return errors.Wrap(err, "updating default config condition failed")
+	}
+
+	var (
+		verr    error
+		skipped map[string]error
+	)
+	if am.Spec.AlertmanagerConfigSelector != nil {
+		data[configFilename], skipped, verr = c.generateConfig(am, data[configFilename])
+	}
+	if err := c.updateConvertedCondition(am, skipped); err != nil {
+		return errors.Wrap(err, "updating AlertmanagerConfig condition failed")
+	}
+	if verr == nil {
+		verr = validateConfig(data[configFilename])
+	}
+	if err := c.updateConfigCondition(am, verr); err != nil {
+		return errors.Wrap(err, "updating config condition failed")
+	}
+
+	sclient := c.kclient.CoreV1().Secrets(am.Namespace)
+	if mountedConfigSecretName(am) != generatedConfigSecretName(am.Name) {
+		return k8sutil.DeleteSecret(sclient, generatedConfigSecretName(am.Name))
+	}
+	if verr != nil {
+		c.logger.Log("msg", "keeping last valid configuration", "key", am.Namespace+"/"+am.Name, "err", verr)
+		return nil
+	}
+	return k8sutil.CreateOrUpdateSecret(sclient, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: generatedConfigSecretName(am.Name),
+		},
+		Data: data,
+	})
+}
+
+// createDefaultConfig creates the config Secret of the Alertmanager with the
+// default configuration. A Secret created in the meantime is not overwritten.
+func (c *Operator) createDefaultConfig(am *v1alpha1.Alertmanager) (*v1.Secret, error) {
+	s, err := k8sutil.CreateSecret(c.kclient.CoreV1().Secrets(am.Namespace), &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: configSecretName(am.Name),
+			Labels: map[string]string{
+				prometheusoperator.WatchedLabel: "true",
+			},
+		},
+		Data: map[string][]byte{
+			configFilename: defaultBaseConfig,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if isDefaultConfig(s) {
+		c.logger.Log("msg", "created default configuration", "key", am.Namespace+"/"+am.Name)
+	}
+	return s, nil
+}
+
+// isDefaultConfig checks whether the config Secret was created by the
+// operator and still holds the default configuration.
+func isDefaultConfig(s *v1.Secret) bool {
+	return k8sutil.IsManaged(s) && bytes.Equal(s.Data[configFilename], defaultBaseConfig)
+}
+
+// generateConfig merges the AlertmanagerConfigs selected by the Alertmanager
+// into the base configuration. AlertmanagerConfigs that can't be converted
+// are skipped and returned with their error, keyed by namespace and name.
+func (c *Operator) generateConfig(am *v1alpha1.Alertmanager, base []byte) ([]byte, map[string]error, error) {
+	selector, err := metav1.LabelSelectorAsSelector(am.Spec.AlertmanagerConfigSelector)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parsing AlertmanagerConfig selector failed")
+	}
+	var amcs []*v1alpha1.AlertmanagerConfig
+	cache.ListAll(c.amcInf.GetStore(), selector, func(obj interface{}) {
+		amcs = append(amcs, obj.(*v1alpha1.AlertmanagerConfig))
+	})
+	sort.Slice(amcs, func(i, j int) bool {
+		if amcs[i].Namespace != amcs[j].Namespace {
+			return amcs[i].Namespace < amcs[j].Namespace
+		}
+		return amcs[i].Name < amcs[j].Name
+	})
+
+	var configs []*namespaceConfig
+	skipped := map[string]error{}
+	for _, amc := range amcs {
+		nc, err := convertAlertmanagerConfig(amc, c.loadSecretKey)
+		if err != nil {
+			c.logger.Log("msg", "skipping AlertmanagerConfig", "alertmanager", am.Namespace+"/"+am.Name, "alertmanagerconfig", amc.Namespace+"/"+amc.Name, "err", err)
+			skipped[amc.Namespace+"/"+amc.Name] = err
+			continue
+		}
+		configs = append(configs, nc)
+	}
+	b, err := generateConfig(base, configs)
+	return b, skipped, err
+}
+
+// loadSecretKey reads the value of the key from the Secret cache.
+func (c *Operator) loadSecretKey(ns string, sel v1.SecretKeySelector) (string, error) {
+	obj, exists, err := c.secrInf.GetIndexer().GetByKey(ns + "/" + sel.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "retrieving secret %q failed", sel.Name)
+	}
+	if !exists {
+		return "", fmt.Errorf("secret %q not found", sel.Name)
+	}
+	v, ok := obj.(*v1.Secret).Data[sel.Key]
+	if !ok {
+		return "", fmt.Errorf("secret key %q in secret %q not found", sel.Key, sel.Name)
+	}
+	return string(v), nil
 }
@@ -0,0 +1,520 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+)
+
+const (
+	tprSilence = "silence." + v1alpha1.TPRGroup
+
+	// silenceAlertmanagerIndex indexes Silences by the key of the
+	// Alertmanager they reference.
+	silenceAlertmanagerIndex = "alertmanager"
+
+	defaultSilenceCreatedBy = "prometheus-operator"
+)
+
+var apiClient = &http.Client{Timeout: 10 * time.Second}
+
+// silence is a silence as returned and accepted by the Alertmanager API.
+type silence struct {
+	ID        string           `json:"id,omitempty"`
+	Matchers  []silenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+type silenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+}
+
+// sameAs checks whether both silences mute the same alerts until the same
+// time for the same reason.
+func (s silence) sameAs(o silence) bool {
+	if s.ID == o.ID {
+		return true
+	}
+	return reflect.DeepEqual(sortedMatchers(s.Matchers), sortedMatchers(o.Matchers)) && s.EndsAt.Equal(o.EndsAt) && s.CreatedBy == o.CreatedBy && s.Comment == o.Comment
+}
+
+func sortedMatchers(ms []silenceMatcher) []silenceMatcher {
+	res := append([]silenceMatcher{}, ms...)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Value < res[j].Value
+	})
+	return res
+}
+
+// makeSilence returns the silence of the Alertmanager API for the Silence.
+// Start times in the past are moved to now, as the silence is created now.
+func makeSilence(s *v1alpha1.Silence, now time.Time) silence {
+	res := silence{
+		StartsAt:  s.CreationTimestamp.Time,
+		EndsAt:    s.Spec.EndsAt.Time,
+		CreatedBy: s.Spec.CreatedBy,
+		Comment:   s.Spec.Comment,
+	}
+	if s.Spec.StartsAt != nil {
+		res.StartsAt = s.Spec.StartsAt.Time
+	}
+	if res.StartsAt.Before(now) {
+		res.StartsAt = now
+	}
+	if res.CreatedBy == "" {
+		res.CreatedBy = defaultSilenceCreatedBy
+	}
+	for _, m := range s.Spec.Matchers {
+		res.Matchers = append(res.Matchers, silenceMatcher{
+			Name:    m.Name,
+			Value:   m.Value,
+			IsRegex: m.IsRegex,
+		})
+	}
+	return res
+}
+
+// upToDate checks whether the silence cur in the Alertmanager is active or
+// pending and matches the desired one, which has no ID.
+func upToDate(cur, desired silence, now time.Time) bool {
+	if !cur.EndsAt.After(now) {
+		return false
+	}
+	if (cur.StartsAt.After(now) || desired.StartsAt.After(now)) && !cur.StartsAt.Equal(desired.StartsAt) {
+		return false
+	}
+	return cur.sameAs(desired)
+}
+
+func silenceAlertmanager(obj interface{}) ([]string, error) {
+	s := obj.(*v1alpha1.Silence)
+	return []string{s.Namespace + "/" + s.Spec.Alertmanager}, nil
+}
+
+func (c *Operator) handleSilenceAdd(obj interface{}) {
+	s := obj.(*v1alpha1.Silence)
+	c.logger.Log("msg", "Silence added", "key", s.Namespace+"/"+s.Name)
+	c.enqueueSilence(s)
+}
+
+func (c *Operator) handleSilenceUpdate(old, cur interface{}) {
+	c.enqueueSilence(cur.(*v1alpha1.Silence))
+}
+
+func (c *Operator) handleSilenceDelete(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	s, ok := obj.(*v1alpha1.Silence)
+	if !ok {
+		return
+	}
+	c.logger.Log("msg", "Silence deleted", "key", s.Namespace+"/"+s.Name)
+	if key, ok := c.keyFunc(s); ok && s.Status != nil && s.Status.SilenceID != "" {
+		c.deletedSilencesMtx.Lock()
+		c.deletedSilences[key] = s
+		c.deletedSilencesMtx.Unlock()
+	}
+	c.enqueueSilence(s)
+}
+
+func (c *Operator) enqueueSilence(s *v1alpha1.Silence) {
+	if key, ok := c.keyFunc(s); ok {
+		c.silQueue.Add(key)
+	}
+}
+
+// enqueueSilencesFor enqueues the Silences referencing the Alertmanager.
+func (c *Operator) enqueueSilencesFor(am *v1alpha1.Alertmanager) {
+	sils, err := c.silInf.GetIndexer().ByIndex(silenceAlertmanagerIndex, am.Namespace+"/"+am.Name)
+	if err != nil {
+		c.logger.Log("msg", "looking up Silences failed", "err", err)
+		return
+	}
+	for _, s := range sils {
+		c.enqueueSilence(s.(*v1alpha1.Silence))
+	}
+}
+
+func (c *Operator) silenceWorker() {
+	for c.processNextSilence() {
+	}
+}
+
+func (c *Operator) processNextSilence() bool {
+	key, quit := c.silQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.silQueue.Done(key)
+
+	c.silenceSyncMtx.Lock()
+	err := c.syncSilence(key.(string))
+	c.silenceSyncMtx.Unlock()
+	if err == nil {
+		c.silQueue.Forget(key)
+		return true
+	}
+
+	utilruntime.HandleError(errors.Wrap(err, fmt.Sprintf("Sync %q failed", key)))
+	c.silQueue.AddRateLimited(key)
+
+	return true
+}
+
+// syncSilence creates the silence of a Silence in its Alertmanager, or
+// replaces it if it differs from the Silence or is no longer active before
+// the Silence ends. The silence of a deleted Silence is expired.
+func (c *Operator) syncSilence(key string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	obj, exists, err := c.silInf.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		c.deletedSilencesMtx.Lock()
+		s, ok := c.deletedSilences[key]
+		c.deletedSilencesMtx.Unlock()
+		if !ok {
+			return nil
+		}
+		if err := c.expireSilence(ns, *s.Status); err != nil {
+			return err
+		}
+		c.deletedSilencesMtx.Lock()
+		delete(c.deletedSilences, key)
+		c.deletedSilencesMtx.Unlock()
+		return nil
+	}
+	s := obj.(*v1alpha1.Silence)
+
+	status := v1alpha1.SilenceStatus{}
+	if s.Status != nil {
+		status = *s.Status
+	}
+	// The silence is moved to another Alertmanager.
+	if status.SilenceID != "" && status.Alertmanager != s.Spec.Alertmanager {
+		if err := c.expireSilence(ns, status); err != nil {
+			return err
+		}
+		status = v1alpha1.SilenceStatus{}
+	}
+
+	amObj, exists, err := c.alrtInf.GetIndexer().GetByKey(ns + "/" + s.Spec.Alertmanager)
+	if err != nil {
+		return errors.Wrap(err, "retrieving alertmanager from cache failed")
+	}
+	if !exists {
+		c.logger.Log("msg", "skipping Silence, alertmanager not found", "key", key, "alertmanager", s.Spec.Alertmanager)
+		return nil
+	}
+	am := amObj.(*v1alpha1.Alertmanager)
+	pods, err := c.readyPods(am)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	desired := makeSilence(s, now)
+	var cur *silence
+	if status.SilenceID != "" {
+		if cur, err = findSilence(am, pods, status.SilenceID); err != nil {
+			return err
+		}
+	}
+	if cur != nil && upToDate(*cur, desired, now) {
+		return nil
+	}
+	active := cur != nil && cur.EndsAt.After(now)
+
+	if desired.EndsAt.After(now) {
+		id, err := postSilence(am, pods[0], desired)
+		if err != nil {
+			return err
+		}
+		c.logger.Log("msg", "silence created", "key", key, "silence", id)
+		status = v1alpha1.SilenceStatus{Alertmanager: am.Name, SilenceID: id}
+	}
+	// The replaced silence, or the silence of a Silence whose end moved into
+	// the past, is expired.
+	if active {
+		if err := expireSilence(am, pods[0], cur.ID); err != nil {
+			return err
+		}
+	}
+
+	if s.Status != nil && *s.Status == status {
+		return nil
+	}
+	upd, err := c.mclient.Silences(ns).Get(name)
+	if err != nil {
+		return errors.Wrap(err, "retrieving Silence failed")
+	}
+	upd.Status = &status
+	if _, err := c.mclient.Silences(ns).Update(upd); err != nil {
+		return errors.Wrap(err, "updating Silence status failed")
+	}
+	return nil
+}
+
+// expireSilence expires the silence recorded in the status, if it is still
+// active. Silences of deleted Alertmanagers are gone along with them.
+func (c *Operator) expireSilence(ns string, status v1alpha1.SilenceStatus) error {
+	obj, exists, err := c.alrtInf.GetIndexer().GetByKey(ns + "/" + status.Alertmanager)
+	if err != nil {
+		return errors.Wrap(err, "retrieving alertmanager from cache failed")
+	}
+	if !exists {
+		return nil
+	}
+	am := obj.(*v1alpha1.Alertmanager)
+	pods, err := c.readyPods(am)
+	if err != nil {
+		return err
+	}
+	cur, err := findSilence(am, pods, status.SilenceID)
+	if err != nil {
+		return err
+	}
+	if cur == nil || !cur.EndsAt.After(time.Now()) {
+		return nil
+	}
+	if err := expireSilence(am, pods[0], cur.ID); err != nil {
+		return err
+	}
+	c.logger.Log("msg", "silence expired", "alertmanager", ns+"/"+am.Name, "silence", cur.ID)
+	return nil
+}
+
+// expireOrphanedSilences expires the active silences created by the operator
+// in the Alertmanager that no Silence records in its status. They are left
+// behind by Silences deleted while the operator wasn't running, as the
+// silences of deleted Silences are only expired when the deletion is
+// observed. Silences with a custom createdBy are not recognized. Each
+// Alertmanager is checked once after the operator started.
+func (c *Operator) expireOrphanedSilences(am *v1alpha1.Alertmanager) error {
+	key := am.Namespace + "/" + am.Name
+	c.orphansExpiredMtx.Lock()
+	_, done := c.orphansExpired[key]
+	c.orphansExpiredMtx.Unlock()
+	if done {
+		return nil
+	}
+
+	pods, err := c.readyPods(am)
+	if err != nil {
+		return err
+	}
+
+	c.silenceSyncMtx.Lock()
+	defer c.silenceSyncMtx.Unlock()
+
+	// The Silences are listed from the API server, as the cache may not
+	// contain the latest status updates yet.
+	obj, err := c.mclient.Silences(am.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "listing Silences failed")
+	}
+	known := map[string]bool{}
+	for _, s := range obj.(*v1alpha1.SilenceList).Items {
+		if s.Status != nil && s.Status.Alertmanager == am.Name {
+			known[s.Status.SilenceID] = true
+		}
+	}
+
+	sils, err := getSilences(am, pods[0])
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, s := range sils {
+		if s.CreatedBy != defaultSilenceCreatedBy || known[s.ID] || !s.EndsAt.After(now) {
+			continue
+		}
+		if err := expireSilence(am, pods[0], s.ID); err != nil {
+			return err
+		}
+		c.logger.Log("msg", "orphaned silence expired", "alertmanager", key, "silence", s.ID)
+	}
+
+	c.orphansExpiredMtx.Lock()
+	c.orphansExpired[key] = struct{}{}
+	c.orphansExpiredMtx.Unlock()
+	return nil
+}
+
+// readyPods returns the ready pods of the Alertmanager. An error is returned
+// if there are none.
+func (c *Operator) readyPods(am *v1alpha1.Alertmanager) ([]v1.Pod, error) {
+	pods, err := c.kclient.CoreV1().Pods(am.Namespace).List(ListOptions(am.Name))
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving pods failed")
+	}
+	res := filterReadyPods(pods.Items)
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no ready pod of alertmanager %s", am.Name)
+	}
+	return res, nil
+}
+
+func filterReadyPods(pods []v1.Pod) []v1.Pod {
+	var res []v1.Pod
+	for _, p := range pods {
+		if ready, _ := k8sutil.PodRunningAndReady(p); ready && p.Status.PodIP != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// findSilence returns the silence from the first of the pods that knows it,
+// or nil if none does. Pods may not know a silence yet, while it is
+// propagated through the cluster.
+func findSilence(am *v1alpha1.Alertmanager, pods []v1.Pod, id string) (*silence, error) {
+	for _, pod := range pods {
+		s, err := getSilence(am, pod, id)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// apiRequest sends a request to the silences API of the pod, at the path p
+// relative to the API of the Alertmanager version, and decodes the response
+// into res. It returns the status code of the response. The v1 API wraps the
+// response in a data field, the v2 API does not.
+func apiRequest(am *v1alpha1.Alertmanager, pod v1.Pod, method, p string, body interface{}, res interface{}) (int, error) {
+	vp, err := profileForAlertmanager(am)
+	if err != nil {
+		return 0, err
+	}
+	webRoutePrefix := "/"
+	if am.Spec.RoutePrefix != "" {
+		webRoutePrefix = am.Spec.RoutePrefix
+	}
+	u := "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(9093)) + path.Clean(webRoutePrefix+vp.apiPath+p)
+
+	var b []byte
+	if body != nil {
+		if b, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("%s %s returned status %d: %s", method, u, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if res == nil {
+		return resp.StatusCode, nil
+	}
+	var r interface{} = res
+	if vp.apiPath == "/api/v1" {
+		r = &struct {
+			Data interface{} `json:"data"`
+		}{Data: res}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return resp.StatusCode, errors.Wrap(err, "decoding response failed")
+	}
+	return resp.StatusCode, nil
+}
+
+func getSilences(am *v1alpha1.Alertmanager, pod v1.Pod) ([]silence, error) {
+	var res []silence
+	if _, err := apiRequest(am, pod, "GET", "/silences", nil, &res); err != nil {
+		return nil, errors.Wrap(err, "retrieving silences failed")
+	}
+	return res, nil
+}
+
+// getSilence returns the silence with the ID, or nil if the pod does not know
+// it.
+func getSilence(am *v1alpha1.Alertmanager, pod v1.Pod, id string) (*silence, error) {
+	var res silence
+	code, err := apiRequest(am, pod, "GET", "/silence/"+id, nil, &res)
+	if code == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving silence failed")
+	}
+	return &res, nil
+}
+
+// postSilence creates the silence and returns its ID.
+func postSilence(am *v1alpha1.Alertmanager, pod v1.Pod, s silence) (string, error) {
+	s.ID = ""
+	// The ID is returned as silenceId by the v1 API and as silenceID by the
+	// v2 API, both of which are decoded into the field.
+	var res struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if _, err := apiRequest(am, pod, "POST", "/silences", s, &res); err != nil {
+		return "", errors.Wrap(err, "creating silence failed")
+	}
+	return res.SilenceID, nil
+}
+
+func expireSilence(am *v1alpha1.Alertmanager, pod v1.Pod, id string) error {
+	if _, err := apiRequest(am, pod, "DELETE", "/silence/"+id, nil, nil); err != nil {
+		return errors.Wrap(err, "expiring silence failed")
+	}
+	return nil
+}
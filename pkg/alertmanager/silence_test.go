@@ -0,0 +1,196 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+)
+
+func TestMakeSilence(t *testing.T) {
+	now := time.Date(2017, 9, 1, 20, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	end := now.Add(3 * time.Hour)
+
+	for _, c := range []struct {
+		name     string
+		spec     v1alpha1.SilenceSpec
+		expected silence
+	}{
+		{
+			name: "defaults",
+			spec: v1alpha1.SilenceSpec{
+				Matchers: []v1alpha1.SilenceMatcher{{Name: "service", Value: "database"}},
+				EndsAt:   metav1.NewTime(end),
+				Comment:  "maintenance",
+			},
+			expected: silence{
+				Matchers:  []silenceMatcher{{Name: "service", Value: "database"}},
+				StartsAt:  now,
+				EndsAt:    end,
+				CreatedBy: defaultSilenceCreatedBy,
+				Comment:   "maintenance",
+			},
+		},
+		{
+			name: "start in the past",
+			spec: v1alpha1.SilenceSpec{
+				StartsAt: &metav1.Time{Time: now.Add(-time.Hour)},
+				EndsAt:   metav1.NewTime(end),
+			},
+			expected: silence{
+				StartsAt:  now,
+				EndsAt:    end,
+				CreatedBy: defaultSilenceCreatedBy,
+			},
+		},
+		{
+			name: "pending",
+			spec: v1alpha1.SilenceSpec{
+				Matchers:  []v1alpha1.SilenceMatcher{{Name: "instance", Value: "db-[0-9]+", IsRegex: true}},
+				StartsAt:  &metav1.Time{Time: later},
+				EndsAt:    metav1.NewTime(end),
+				CreatedBy: "ops-team",
+			},
+			expected: silence{
+				Matchers:  []silenceMatcher{{Name: "instance", Value: "db-[0-9]+", IsRegex: true}},
+				StartsAt:  later,
+				EndsAt:    end,
+				CreatedBy: "ops-team",
+			},
+		},
+	} {
+		s := &v1alpha1.Silence{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+			Spec:       c.spec,
+		}
+		res := makeSilence(s, now)
+		if !reflect.DeepEqual(res, c.expected) {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, res)
+		}
+	}
+}
+
+func TestSilenceSameAs(t *testing.T) {
+	end := time.Date(2017, 9, 1, 23, 0, 0, 0, time.UTC)
+	s := silence{
+		ID: "1",
+		Matchers: []silenceMatcher{
+			{Name: "service", Value: "database"},
+			{Name: "instance", Value: "db-[0-9]+", IsRegex: true},
+		},
+		EndsAt:    end,
+		CreatedBy: defaultSilenceCreatedBy,
+		Comment:   "maintenance",
+	}
+
+	for _, c := range []struct {
+		name string
+		mod  func(*silence)
+		same bool
+	}{
+		{name: "identical", mod: func(*silence) {}, same: true},
+		{name: "same ID", mod: func(o *silence) { o.ID = "1"; o.Comment = "other" }, same: true},
+		{
+			name: "reordered matchers",
+			mod:  func(o *silence) { o.Matchers = []silenceMatcher{o.Matchers[1], o.Matchers[0]} },
+			same: true,
+		},
+		{name: "other end", mod: func(o *silence) { o.EndsAt = end.Add(time.Hour) }},
+		{name: "other comment", mod: func(o *silence) { o.Comment = "other" }},
+		{name: "other author", mod: func(o *silence) { o.CreatedBy = "ops-team" }},
+		{name: "no regex", mod: func(o *silence) { o.Matchers[1].IsRegex = false }},
+	} {
+		o := s
+		o.ID = "2"
+		o.Matchers = append([]silenceMatcher{}, s.Matchers...)
+		c.mod(&o)
+		if res := s.sameAs(o); res != c.same {
+			t.Errorf("%s: expected %v, got %v", c.name, c.same, res)
+		}
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	now := time.Date(2017, 9, 1, 20, 0, 0, 0, time.UTC)
+	desired := silence{
+		Matchers:  []silenceMatcher{{Name: "service", Value: "database"}},
+		StartsAt:  now,
+		EndsAt:    now.Add(3 * time.Hour),
+		CreatedBy: defaultSilenceCreatedBy,
+	}
+
+	for _, c := range []struct {
+		name     string
+		cur      func(silence) silence
+		desired  func(silence) silence
+		upToDate bool
+	}{
+		{
+			name:     "active since earlier",
+			cur:      func(s silence) silence { s.StartsAt = now.Add(-time.Hour); return s },
+			upToDate: true,
+		},
+		{
+			name: "expired",
+			cur:  func(s silence) silence { s.EndsAt = now; return s },
+		},
+		{
+			name: "expired early",
+			cur:  func(s silence) silence { s.EndsAt = now.Add(-time.Minute); return s },
+		},
+		{
+			name: "other end",
+			cur:  func(s silence) silence { s.EndsAt = now.Add(time.Hour); return s },
+		},
+		{
+			name: "other matchers",
+			cur:  func(s silence) silence { s.Matchers = []silenceMatcher{{Name: "service", Value: "web"}}; return s },
+		},
+		{
+			name:     "pending",
+			cur:      func(s silence) silence { s.StartsAt = now.Add(time.Hour); return s },
+			desired:  func(s silence) silence { s.StartsAt = now.Add(time.Hour); return s },
+			upToDate: true,
+		},
+		{
+			name:    "pending with other start",
+			cur:     func(s silence) silence { s.StartsAt = now.Add(time.Hour); return s },
+			desired: func(s silence) silence { s.StartsAt = now.Add(2 * time.Hour); return s },
+		},
+		{
+			name:    "start moved into the future",
+			cur:     func(s silence) silence { s.StartsAt = now.Add(-time.Hour); return s },
+			desired: func(s silence) silence { s.StartsAt = now.Add(time.Hour); return s },
+		},
+	} {
+		cur, des := desired, desired
+		cur.ID = "1"
+		if c.cur != nil {
+			cur = c.cur(cur)
+		}
+		if c.desired != nil {
+			des = c.desired(des)
+		}
+		if res := upToDate(cur, des, now); res != c.upToDate {
+			t.Errorf("%s: expected %v, got %v", c.name, c.upToDate, res)
+		}
+	}
+}
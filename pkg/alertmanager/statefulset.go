@@ -15,27 +15,54 @@
 package alertmanager
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
 
-	"github.com/blang/semver"
 	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+	prometheusoperator "github.com/coreos/prometheus-operator/pkg/prometheus"
 	"github.com/pkg/errors"
 )
 
 const (
 	governingServiceName = "alertmanager-operated"
 	defaultVersion       = "v0.7.1"
+	defaultRetention     = "120h"
+	// storageDir is the mount path of the data volume.
+	storageDir = "/var/alertmanager/data"
+	meshPort   = 6783
+
+	// tolerateUnreadyEndpointsAnnotation publishes the addresses of pods
+	// that are not ready in the DNS records of a Service, so that
+	// Alertmanager instances find their peers before becoming ready.
+	tolerateUnreadyEndpointsAnnotation = "service.alpha.kubernetes.io/tolerate-unready-endpoints"
 )
 
+// meshPeersScript starts an Alertmanager using mesh peers with the other
+// addresses the peer Service resolves to as peers. The Service also resolves
+// to pods that are not ready yet. Pods started later connect to the pods
+// started before, so a pod resolving no peers still joins the cluster.
+const meshPeersScript = `peers=""
+for ip in $(nslookup "$PEER_SERVICE" 2>/dev/null | sed -n '/^Name:/,$p' | grep -oE '([0-9]{1,3}\.){3}[0-9]{1,3}'); do
+  if [ "$ip" != "$POD_IP" ]; then
+    peers="$peers $MESH_PEER_FLAG=$ip"
+  fi
+done
+exec /bin/alertmanager "$@" $peers`
+
 var (
 	minReplicas         int32 = 1
 	probeTimeoutSeconds int32 = 3
@@ -52,6 +79,9 @@ func makeStatefulSet(am *v1alpha1.Alertmanager, old *v1beta1.StatefulSet, config
 	if am.Spec.Version == "" {
 		am.Spec.Version = defaultVersion
 	}
+	if am.Spec.Retention == "" {
+		am.Spec.Retention = defaultRetention
+	}
 	if am.Spec.Replicas != nil && *am.Spec.Replicas < minReplicas {
 		am.Spec.Replicas = &minReplicas
 	}
@@ -69,7 +99,7 @@ func makeStatefulSet(am *v1alpha1.Alertmanager, old *v1beta1.StatefulSet, config
 	statefulset := &v1beta1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        prefixedName(am.Name),
-			Labels:      am.ObjectMeta.Labels,
+			Labels:      prometheusoperator.OperatorInstanceLabels(am.ObjectMeta.Labels, config.OperatorInstance),
 			Annotations: am.ObjectMeta.Annotations,
 		},
 		Spec: *spec,
@@ -96,12 +126,90 @@ func makeStatefulSet(am *v1alpha1.Alertmanager, old *v1beta1.StatefulSet, config
 		statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, pvcTemplate)
 	}
 
+	tmpl := &statefulset.Spec.Template
+	tmpl.Spec.Containers[0].VolumeMounts = append(tmpl.Spec.Containers[0].VolumeMounts, am.Spec.VolumeMounts...)
+	if err := k8sutil.CustomizePodTemplate(tmpl, am.Spec.PodMetadata, am.Spec.SecurityContext, am.Spec.InitContainers, am.Spec.Containers, am.Spec.Volumes); err != nil {
+		return nil, errors.Wrap(err, "customize pod template")
+	}
+
 	if old != nil {
 		statefulset.Annotations = old.Annotations
 	}
+
+	var pvcRetentionPolicy v1alpha1.PVCRetentionPolicy
+	if storageSpec != nil {
+		pvcRetentionPolicy = storageSpec.PVCRetentionPolicy
+	}
+	k8sutil.SetPVCRetentionPolicy(statefulset, pvcRetentionPolicy)
+
+	if err := k8sutil.SetPodTemplateHash(statefulset); err != nil {
+		return nil, err
+	}
 	return statefulset, nil
 }
 
+func makePodDisruptionBudget(am *v1alpha1.Alertmanager, config Config) (*policy.PodDisruptionBudget, error) {
+	replicas := minReplicas
+	if am.Spec.Replicas != nil {
+		replicas = *am.Spec.Replicas
+	}
+	pdb, err := k8sutil.MakePodDisruptionBudget(prefixedName(am.Name), map[string]string{
+		"app":          "alertmanager",
+		"alertmanager": am.Name,
+	}, replicas, am.Spec.PodDisruptionBudget)
+	if pdb == nil || err != nil {
+		return nil, err
+	}
+	pdb.Labels = prometheusoperator.OperatorInstanceLabels(pdb.Labels, config.OperatorInstance)
+	return pdb, nil
+}
+
+func makeService(am *v1alpha1.Alertmanager, config Config) *v1.Service {
+	svc := k8sutil.MakeService(prefixedName(am.Name), map[string]string{
+		"app":          "alertmanager",
+		"alertmanager": am.Name,
+	}, 9093, am.Spec.Service)
+	svc.Labels = prometheusoperator.OperatorInstanceLabels(svc.Labels, config.OperatorInstance)
+	return svc
+}
+
+func makeIngress(am *v1alpha1.Alertmanager, config Config) (*extensions.Ingress, error) {
+	ing, err := k8sutil.MakeIngress(prefixedName(am.Name), map[string]string{
+		"app":          "alertmanager",
+		"alertmanager": am.Name,
+	}, am.Spec.ExternalURL, am.Spec.RoutePrefix, am.Spec.Ingress)
+	if err != nil {
+		return nil, err
+	}
+	ing.Labels = prometheusoperator.OperatorInstanceLabels(ing.Labels, config.OperatorInstance)
+	return ing, nil
+}
+
+// makePeerService returns the headless Service through whose DNS records the
+// Alertmanager instances discover each other. Unlike the governing Service,
+// it only selects the pods of a single Alertmanager.
+func makePeerService(am *v1alpha1.Alertmanager, config Config) *v1.Service {
+	svc := k8sutil.MakeService(peerServiceName(am.Name), map[string]string{
+		"app":          "alertmanager",
+		"alertmanager": am.Name,
+	}, meshPort, &v1alpha1.ServiceSpec{
+		Annotations: map[string]string{
+			tolerateUnreadyEndpointsAnnotation: "true",
+		},
+		Ports: []v1.ServicePort{
+			{
+				Name:       "mesh",
+				Port:       meshPort,
+				TargetPort: intstr.FromString("mesh"),
+				Protocol:   v1.ProtocolTCP,
+			},
+		},
+	})
+	svc.Spec.ClusterIP = "None"
+	svc.Labels = prometheusoperator.OperatorInstanceLabels(svc.Labels, config.OperatorInstance)
+	return svc
+}
+
 func makeStatefulSetService(p *v1alpha1.Alertmanager) *v1.Service {
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -136,36 +244,32 @@ func makeStatefulSetService(p *v1alpha1.Alertmanager) *v1.Service {
 
 func makeStatefulSetSpec(a *v1alpha1.Alertmanager, config Config) (*v1beta1.StatefulSetSpec, error) {
 	image := fmt.Sprintf("%s:%s", a.Spec.BaseImage, a.Spec.Version)
-	versionStr := strings.TrimLeft(a.Spec.Version, "v")
-
-	version, err := semver.Parse(versionStr)
+	vp, err := profileForVersion(a.Spec.Version)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse version")
+		return nil, err
+	}
+	if _, err := time.ParseDuration(a.Spec.Retention); err != nil {
+		return nil, errors.Wrap(err, "invalid retention")
 	}
 
 	amArgs := []string{
-		fmt.Sprintf("-config.file=%s", "/etc/alertmanager/config/alertmanager.yaml"),
-		fmt.Sprintf("-web.listen-address=:%d", 9093),
-		fmt.Sprintf("-mesh.listen-address=:%d", 6783),
-		fmt.Sprintf("-storage.path=%s", "/etc/alertmanager/data"),
+		fmt.Sprintf("config.file=%s", "/etc/alertmanager/config/alertmanager.yaml"),
+		fmt.Sprintf("web.listen-address=:%d", 9093),
+		fmt.Sprintf("%s.listen-address=:%d", vp.clusterFlagPrefix, 6783),
+		fmt.Sprintf("storage.path=%s", storageDir),
+		fmt.Sprintf("data.retention=%s", a.Spec.Retention),
 	}
 
 	if a.Spec.ExternalURL != "" {
-		amArgs = append(amArgs, "-web.external-url="+a.Spec.ExternalURL)
+		amArgs = append(amArgs, "web.external-url="+a.Spec.ExternalURL)
 	}
 
 	webRoutePrefix := "/"
 	if a.Spec.RoutePrefix != "" {
 		webRoutePrefix = a.Spec.RoutePrefix
 	}
-
-	switch version.Major {
-	case 0:
-		if version.Minor >= 7 {
-			amArgs = append(amArgs, "-web.route-prefix="+webRoutePrefix)
-		}
-	default:
-		return nil, errors.Errorf("unsupported Alertmanager major version %s", version)
+	if vp.routePrefix {
+		amArgs = append(amArgs, "web.route-prefix="+webRoutePrefix)
 	}
 
 	localReloadURL := &url.URL{
@@ -176,19 +280,132 @@ func makeStatefulSetSpec(a *v1alpha1.Alertmanager, config Config) (*v1beta1.Stat
 
 	probeHandler := v1.Handler{
 		HTTPGet: &v1.HTTPGetAction{
-			Path: path.Clean(webRoutePrefix + "/api/v1/status"),
+			Path: path.Clean(webRoutePrefix + vp.probePath),
 			Port: intstr.FromString("web"),
 		},
 	}
 
-	for i := int32(0); i < *a.Spec.Replicas; i++ {
-		amArgs = append(amArgs, fmt.Sprintf("-mesh.peer=%s-%d.%s.%s.svc", prefixedName(a.Name), i, governingServiceName, a.Namespace))
+	// Peers are resolved through the DNS records of the peer Service, so
+	// that scaling the Alertmanager does not change the pod template and
+	// thereby replace all pods.
+	peerService := fmt.Sprintf("%s.%s.svc", peerServiceName(a.Name), a.Namespace)
+	var (
+		peers   []string
+		command []string
+		env     []v1.EnvVar
+	)
+	if vp.clusterFlagPrefix == "cluster" {
+		// Unlike mesh peers, cluster peers require a port.
+		peers = append(peers, fmt.Sprintf("%s:%d", peerService, meshPort))
+	} else {
+		// A mesh peer is only resolved to a single address, so the
+		// addresses are resolved when the container starts.
+		command = []string{"/bin/sh", "-c", meshPeersScript, "alertmanager"}
+		env = []v1.EnvVar{
+			{Name: "PEER_SERVICE", Value: peerService},
+			{Name: "MESH_PEER_FLAG", Value: vp.flagPrefix + "mesh.peer"},
+			{
+				Name: "POD_IP",
+				ValueFrom: &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.podIP"},
+				},
+			},
+		}
+	}
+	peers = append(peers, a.Spec.AdditionalPeers...)
+	for _, peer := range peers {
+		amArgs = append(amArgs, fmt.Sprintf("%s.peer=%s", vp.clusterFlagPrefix, peer))
 	}
+	amArgs = vp.flags(amArgs...)
 
 	terminationGracePeriod := int64(0)
+	volumes := []v1.Volume{
+		{
+			Name: "config-volume",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: mountedConfigSecretName(a),
+				},
+			},
+		},
+	}
+	amVolumeMounts := []v1.VolumeMount{
+		{
+			Name:      "config-volume",
+			MountPath: "/etc/alertmanager/config",
+		},
+		{
+			Name:      volumeName(a.Name),
+			MountPath: storageDir,
+			SubPath:   subPathForStorage(a.Spec.Storage),
+		},
+	}
+	configReloadVolumeMounts := []v1.VolumeMount{
+		{
+			Name:      "config-volume",
+			ReadOnly:  true,
+			MountPath: "/etc/alertmanager/config",
+		},
+	}
+	configReloadArgs := []string{
+		fmt.Sprintf("-webhook-url=%s", localReloadURL),
+	}
+
+	// Additional ConfigMaps and Secrets, e.g. holding notification templates,
+	// are watched by the config reloader as well.
+	for _, name := range a.Spec.ConfigMaps {
+		volumes = append(volumes, v1.Volume{
+			Name: objectVolumeName("configmap", name),
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: name,
+					},
+				},
+			},
+		})
+		mount := v1.VolumeMount{
+			Name:      objectVolumeName("configmap", name),
+			ReadOnly:  true,
+			MountPath: "/etc/alertmanager/configmaps/" + name,
+		}
+		amVolumeMounts = append(amVolumeMounts, mount)
+		configReloadVolumeMounts = append(configReloadVolumeMounts, mount)
+		configReloadArgs = append(configReloadArgs, "-volume-dir="+mount.MountPath)
+	}
+	for _, name := range a.Spec.Secrets {
+		volumes = append(volumes, v1.Volume{
+			Name: objectVolumeName("secret", name),
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: name,
+				},
+			},
+		})
+		mount := v1.VolumeMount{
+			Name:      objectVolumeName("secret", name),
+			ReadOnly:  true,
+			MountPath: "/etc/alertmanager/secrets/" + name,
+		}
+		amVolumeMounts = append(amVolumeMounts, mount)
+		configReloadVolumeMounts = append(configReloadVolumeMounts, mount)
+		configReloadArgs = append(configReloadArgs, "-volume-dir="+mount.MountPath)
+	}
+
+	// The configuration directory comes last, so that config reloaders
+	// only supporting a single directory, which take the last flag, keep
+	// watching it.
+	configReloadArgs = append(configReloadArgs, "-volume-dir=/etc/alertmanager/config")
+
 	return &v1beta1.StatefulSetSpec{
 		ServiceName: governingServiceName,
 		Replicas:    a.Spec.Replicas,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app":          "alertmanager",
+				"alertmanager": a.Name,
+			},
+		},
 		Template: v1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
 				Labels: map[string]string{
@@ -201,9 +418,11 @@ func makeStatefulSetSpec(a *v1alpha1.Alertmanager, config Config) (*v1beta1.Stat
 				TerminationGracePeriodSeconds: &terminationGracePeriod,
 				Containers: []v1.Container{
 					{
-						Args:  amArgs,
-						Name:  "alertmanager",
-						Image: image,
+						Command: command,
+						Args:    amArgs,
+						Env:     env,
+						Name:    "alertmanager",
+						Image:   image,
 						Ports: []v1.ContainerPort{
 							{
 								Name:          "web",
@@ -216,17 +435,7 @@ func makeStatefulSetSpec(a *v1alpha1.Alertmanager, config Config) (*v1beta1.Stat
 								Protocol:      v1.ProtocolTCP,
 							},
 						},
-						VolumeMounts: []v1.VolumeMount{
-							{
-								Name:      "config-volume",
-								MountPath: "/etc/alertmanager/config",
-							},
-							{
-								Name:      volumeName(a.Name),
-								MountPath: "/var/alertmanager/data",
-								SubPath:   subPathForStorage(a.Spec.Storage),
-							},
-						},
+						VolumeMounts: amVolumeMounts,
 						LivenessProbe: &v1.Probe{
 							Handler:          probeHandler,
 							TimeoutSeconds:   probeTimeoutSeconds,
@@ -241,19 +450,10 @@ func makeStatefulSetSpec(a *v1alpha1.Alertmanager, config Config) (*v1beta1.Stat
 						},
 						Resources: a.Spec.Resources,
 					}, {
-						Name:  "config-reloader",
-						Image: config.ConfigReloaderImage,
-						Args: []string{
-							fmt.Sprintf("-webhook-url=%s", localReloadURL),
-							"-volume-dir=/etc/alertmanager/config",
-						},
-						VolumeMounts: []v1.VolumeMount{
-							{
-								Name:      "config-volume",
-								ReadOnly:  true,
-								MountPath: "/etc/alertmanager/config",
-							},
-						},
+						Name:         "config-reloader",
+						Image:        config.ConfigReloaderImage,
+						Args:         configReloadArgs,
+						VolumeMounts: configReloadVolumeMounts,
 						Resources: v1.ResourceRequirements{
 							Limits: v1.ResourceList{
 								v1.ResourceCPU:    resource.MustParse("5m"),
@@ -262,16 +462,7 @@ func makeStatefulSetSpec(a *v1alpha1.Alertmanager, config Config) (*v1beta1.Stat
 						},
 					},
 				},
-				Volumes: []v1.Volume{
-					{
-						Name: "config-volume",
-						VolumeSource: v1.VolumeSource{
-							Secret: &v1.SecretVolumeSource{
-								SecretName: configSecretName(a.Name),
-							},
-						},
-					},
-				},
+				Volumes:     volumes,
 				Tolerations: a.Spec.Tolerations,
 				Affinity:    a.Spec.Affinity,
 			},
@@ -283,10 +474,44 @@ func configSecretName(name string) string {
 	return prefixedName(name)
 }
 
+func peerServiceName(name string) string {
+	return prefixedName(name) + "-peers"
+}
+
+func generatedConfigSecretName(name string) string {
+	return prefixedName(name) + "-generated"
+}
+
+// mountedConfigSecretName returns the name of the Secret holding the
+// configuration the pods of the Alertmanager use.
+func mountedConfigSecretName(a *v1alpha1.Alertmanager) string {
+	if a.Spec.AlertmanagerConfigSelector != nil || a.Spec.ValidatedConfigOnly {
+		return generatedConfigSecretName(a.Name)
+	}
+	return configSecretName(a.Name)
+}
+
 func volumeName(name string) string {
 	return fmt.Sprintf("%s-db", prefixedName(name))
 }
 
+// objectVolumeName returns the name of the volume of a ConfigMap or Secret. Volume
+// names must be DNS-1123 labels, while object names may contain dots and be
+// longer than the 63 characters of a label. Such names are shortened and
+// suffixed with a hash of the object name, to keep them unique.
+func objectVolumeName(prefix, name string) string {
+	res := prefix + "-" + name
+	if len(validation.IsDNS1123Label(res)) == 0 {
+		return res
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:10]
+	res = strings.Replace(res, ".", "-", -1)
+	if max := validation.DNS1123LabelMaxLength - len(hash) - 1; len(res) > max {
+		res = res[:max]
+	}
+	return res + "-" + hash
+}
+
 func prefixedName(name string) string {
 	return fmt.Sprintf("alertmanager-%s", name)
 }
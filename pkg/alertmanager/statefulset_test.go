@@ -0,0 +1,145 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+)
+
+func TestStatefulSetPeers(t *testing.T) {
+	replicas := int32(2)
+	for _, c := range []struct {
+		version  string
+		expected []string
+	}{
+		{
+			version: "v0.7.1",
+			expected: []string{
+				"-mesh.peer=alertmanager.example.com:6783",
+			},
+		},
+		{
+			version: "v0.15.0",
+			expected: []string{
+				"--cluster.peer=alertmanager-main-peers.monitoring.svc:6783",
+				"--cluster.peer=alertmanager.example.com:6783",
+			},
+		},
+	} {
+		am := &v1alpha1.Alertmanager{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "main"},
+			Spec: v1alpha1.AlertmanagerSpec{
+				Version:         c.version,
+				Replicas:        &replicas,
+				AdditionalPeers: []string{"alertmanager.example.com:6783"},
+			},
+		}
+		sset, err := makeStatefulSet(am, nil, Config{})
+		if err != nil {
+			t.Fatalf("%s: %s", c.version, err)
+		}
+		var peers []string
+		for _, arg := range sset.Spec.Template.Spec.Containers[0].Args {
+			if strings.Contains(arg, ".peer=") {
+				peers = append(peers, arg)
+			}
+		}
+		if !reflect.DeepEqual(peers, c.expected) {
+			t.Fatalf("%s: expected peers %v, got %v", c.version, c.expected, peers)
+		}
+	}
+}
+
+func TestStatefulSetMeshPeers(t *testing.T) {
+	replicas := int32(2)
+	am := &v1alpha1.Alertmanager{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "main"},
+		Spec:       v1alpha1.AlertmanagerSpec{Version: "v0.7.1", Replicas: &replicas},
+	}
+	sset, err := makeStatefulSet(am, nil, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := sset.Spec.Template.Spec.Containers[0]
+	if len(c.Command) != 4 || c.Command[2] != meshPeersScript {
+		t.Fatalf("expected the mesh peers script as command, got %v", c.Command)
+	}
+	env := map[string]string{}
+	for _, e := range c.Env {
+		env[e.Name] = e.Value
+	}
+	if env["PEER_SERVICE"] != "alertmanager-main-peers.monitoring.svc" || env["MESH_PEER_FLAG"] != "-mesh.peer" {
+		t.Fatalf("unexpected environment %v", c.Env)
+	}
+}
+
+func TestStatefulSetScalingKeepsTemplate(t *testing.T) {
+	for _, version := range []string{"", "v0.15.0"} {
+		var hashes []string
+		for _, replicas := range []int32{3, 5} {
+			replicas := replicas
+			am := &v1alpha1.Alertmanager{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "main"},
+				Spec:       v1alpha1.AlertmanagerSpec{Version: version, Replicas: &replicas},
+			}
+			sset, err := makeStatefulSet(am, nil, Config{})
+			if err != nil {
+				t.Fatalf("%q: %s", version, err)
+			}
+			h, err := k8sutil.PodTemplateHash(sset.Spec.Template)
+			if err != nil {
+				t.Fatalf("%q: %s", version, err)
+			}
+			hashes = append(hashes, h)
+		}
+		if hashes[0] != hashes[1] {
+			t.Errorf("%q: scaling changed the pod template", version)
+		}
+	}
+}
+
+func TestObjectVolumeName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	for _, c := range []struct {
+		name     string
+		expected string
+	}{
+		{name: "am-templates", expected: "configmap-am-templates"},
+		{name: "am-templates.v1"},
+		{name: long},
+	} {
+		res := objectVolumeName("configmap", c.name)
+		if c.expected != "" && res != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, res)
+		}
+		if errs := validation.IsDNS1123Label(res); len(errs) > 0 {
+			t.Errorf("%s: invalid volume name %q: %s", c.name, res, strings.Join(errs, ", "))
+		}
+	}
+	if objectVolumeName("configmap", "am-templates.v1") == objectVolumeName("configmap", "am-templates-v1") {
+		t.Error("volume names of different objects are equal")
+	}
+	if objectVolumeName("configmap", long) == objectVolumeName("configmap", long+"b") {
+		t.Error("volume names of long object names are equal")
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// validatedConfig is the part of the Alertmanager configuration checked by
+// the operator. Other fields are ignored, as the fields known to Alertmanager
+// differ between its versions, e.g. matchers of routes or time intervals.
+type validatedConfig struct {
+	Global       map[string]interface{}  `yaml:"global"`
+	Route        *validatedRoute         `yaml:"route"`
+	InhibitRules []*validatedInhibitRule `yaml:"inhibit_rules"`
+	Receivers    []*validatedReceiver    `yaml:"receivers"`
+	Templates    []string                `yaml:"templates"`
+}
+
+type validatedRoute struct {
+	Receiver       string            `yaml:"receiver"`
+	GroupBy        []string          `yaml:"group_by"`
+	Match          map[string]string `yaml:"match"`
+	MatchRE        map[string]string `yaml:"match_re"`
+	Continue       bool              `yaml:"continue"`
+	Routes         []*validatedRoute `yaml:"routes"`
+	GroupWait      string            `yaml:"group_wait"`
+	GroupInterval  string            `yaml:"group_interval"`
+	RepeatInterval string            `yaml:"repeat_interval"`
+}
+
+type validatedInhibitRule struct {
+	SourceMatch   map[string]string `yaml:"source_match"`
+	SourceMatchRE map[string]string `yaml:"source_match_re"`
+	TargetMatch   map[string]string `yaml:"target_match"`
+	TargetMatchRE map[string]string `yaml:"target_match_re"`
+	Equal         []string          `yaml:"equal"`
+}
+
+type validatedReceiver struct {
+	Name string `yaml:"name"`
+}
+
+// validateConfig checks that Alertmanager can load the configuration. It
+// checks the structure of the configuration, the references to receivers,
+// durations, label names and regular expressions. The settings of the
+// notification integrations and fields not known to all Alertmanager
+// versions are not checked.
+func validateConfig(b []byte) error {
+	if len(b) == 0 {
+		return fmt.Errorf("configuration is empty")
+	}
+	cfg := &validatedConfig{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return fmt.Errorf("parsing configuration failed: %s", err)
+	}
+
+	if v, ok := cfg.Global["resolve_timeout"]; ok {
+		if err := checkDuration("global resolve_timeout", fmt.Sprint(v)); err != nil {
+			return err
+		}
+	}
+
+	receivers := map[string]struct{}{}
+	for _, r := range cfg.Receivers {
+		if r == nil || r.Name == "" {
+			return fmt.Errorf("receiver without name")
+		}
+		if _, ok := receivers[r.Name]; ok {
+			return fmt.Errorf("duplicate receiver %q", r.Name)
+		}
+		receivers[r.Name] = struct{}{}
+	}
+
+	if cfg.Route == nil {
+		return fmt.Errorf("no route provided")
+	}
+	if cfg.Route.Receiver == "" {
+		return fmt.Errorf("root route has no receiver")
+	}
+	if len(cfg.Route.Match) > 0 || len(cfg.Route.MatchRE) > 0 {
+		return fmt.Errorf("root route must not have any matchers")
+	}
+	if err := validateRoute(cfg.Route, receivers); err != nil {
+		return err
+	}
+
+	for i, r := range cfg.InhibitRules {
+		if r == nil {
+			continue
+		}
+		name := fmt.Sprintf("inhibit rule %d", i)
+		for _, m := range []map[string]string{r.SourceMatch, r.TargetMatch} {
+			if err := checkMatchers(name, m, false); err != nil {
+				return err
+			}
+		}
+		for _, m := range []map[string]string{r.SourceMatchRE, r.TargetMatchRE} {
+			if err := checkMatchers(name, m, true); err != nil {
+				return err
+			}
+		}
+		if err := checkLabelNames(name, r.Equal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRoute(r *validatedRoute, receivers map[string]struct{}) error {
+	name := "route"
+	if r.Receiver != "" {
+		name = fmt.Sprintf("route of receiver %q", r.Receiver)
+		if _, ok := receivers[r.Receiver]; !ok {
+			return fmt.Errorf("%s references undefined receiver", name)
+		}
+	}
+	if err := checkGroupBy(name, r.GroupBy); err != nil {
+		return err
+	}
+	if err := checkMatchers(name, r.Match, false); err != nil {
+		return err
+	}
+	if err := checkMatchers(name, r.MatchRE, true); err != nil {
+		return err
+	}
+	for _, d := range []struct{ field, value string }{
+		{"group_wait", r.GroupWait},
+		{"group_interval", r.GroupInterval},
+		{"repeat_interval", r.RepeatInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if err := checkDuration(name+" "+d.field, d.value); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range r.Routes {
+		if child == nil {
+			continue
+		}
+		if err := validateRoute(child, receivers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkDuration(name, d string) error {
+	if _, err := model.ParseDuration(d); err != nil {
+		return fmt.Errorf("invalid %s: %s", name, err)
+	}
+	return nil
+}
+
+// checkGroupBy checks the labels alerts are grouped by. The special value
+// "..." groups by all labels and can't be combined with other labels.
+func checkGroupBy(name string, labels []string) error {
+	for _, l := range labels {
+		if l != "..." {
+			continue
+		}
+		if len(labels) > 1 {
+			return fmt.Errorf("%s groups by all labels with \"...\" and other labels", name)
+		}
+		return nil
+	}
+	return checkLabelNames(name, labels)
+}
+
+func checkLabelNames(name string, labels []string) error {
+	for _, l := range labels {
+		if !model.LabelName(l).IsValid() {
+			return fmt.Errorf("invalid label name %q in %s", l, name)
+		}
+	}
+	return nil
+}
+
+func checkMatchers(name string, matchers map[string]string, regex bool) error {
+	for l, v := range matchers {
+		if !model.LabelName(l).IsValid() {
+			return fmt.Errorf("invalid label name %q in matchers of %s", l, name)
+		}
+		if !regex {
+			continue
+		}
+		if _, err := regexp.Compile("^(?:" + v + ")$"); err != nil {
+			return fmt.Errorf("invalid regular expression for label %q in %s: %s", l, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import "testing"
+
+func TestValidateConfig(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{
+			name:   "empty",
+			config: ``,
+		},
+		{
+			name:   "minimal",
+			config: "route:\n  receiver: default\nreceivers:\n- name: default\n",
+			valid:  true,
+		},
+		{
+			name:   "group by all labels",
+			config: "route:\n  receiver: default\n  group_by: ['...']\nreceivers:\n- name: default\n",
+			valid:  true,
+		},
+		{
+			name:   "group by all and other labels",
+			config: "route:\n  receiver: default\n  group_by: ['...', alertname]\nreceivers:\n- name: default\n",
+		},
+		{
+			name:   "invalid group by label",
+			config: "route:\n  receiver: default\n  group_by: [alert-name]\nreceivers:\n- name: default\n",
+		},
+		{
+			name: "fields of later versions",
+			config: `route:
+  receiver: default
+  routes:
+  - receiver: wechat
+    matchers:
+    - severity="critical"
+    mute_time_intervals: [weekends]
+receivers:
+- name: default
+- name: wechat
+  wechat_configs:
+  - corp_id: example
+time_intervals:
+- name: weekends
+  time_intervals:
+  - weekdays: [saturday, sunday]
+`,
+			valid: true,
+		},
+		{
+			name:   "undefined receiver",
+			config: "route:\n  receiver: missing\nreceivers:\n- name: default\n",
+		},
+		{
+			name:   "duplicate receiver",
+			config: "route:\n  receiver: default\nreceivers:\n- name: default\n- name: default\n",
+		},
+		{
+			name:   "root route with matchers",
+			config: "route:\n  receiver: default\n  match:\n    severity: critical\nreceivers:\n- name: default\n",
+		},
+		{
+			name:   "invalid duration",
+			config: "route:\n  receiver: default\n  group_wait: 30\nreceivers:\n- name: default\n",
+		},
+		{
+			name:   "invalid regular expression",
+			config: "route:\n  receiver: default\n  routes:\n  - receiver: default\n    match_re:\n      service: '(api'\nreceivers:\n- name: default\n",
+		},
+		{
+			name:   "invalid inhibit rule label",
+			config: "route:\n  receiver: default\nreceivers:\n- name: default\ninhibit_rules:\n- equal: [alert-name]\n",
+		},
+	} {
+		err := validateConfig([]byte(c.config))
+		if c.valid && err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.name, err)
+		}
+		if !c.valid && err == nil {
+			t.Fatalf("%s: expected error", c.name)
+		}
+	}
+}
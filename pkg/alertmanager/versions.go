@@ -0,0 +1,154 @@
+// Copyright 2016 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	prometheusoperator "github.com/coreos/prometheus-operator/pkg/prometheus"
+)
+
+// versionProfile describes how the Alertmanager releases of a version range
+// are run.
+type versionProfile struct {
+	versions prometheusoperator.VersionRange
+	// Releases of the range tested with the operator. They make up the
+	// CompatibilityMatrix.
+	releases []string
+	// Prefix of the command line flags.
+	flagPrefix string
+	// Whether the route prefix can be set.
+	routePrefix bool
+	// Prefix of the flags configuring the high availability cluster, mesh
+	// or cluster.
+	clusterFlagPrefix string
+	// Path of the liveness and readiness probes, relative to the route
+	// prefix.
+	probePath string
+	// Path of the API the silences are managed through, relative to the
+	// route prefix.
+	apiPath string
+}
+
+// versionProfiles are the profiles of all supported Alertmanager versions,
+// ordered by version.
+var versionProfiles = []versionProfile{
+	{
+		versions:          prometheusoperator.VersionRange{Min: "v0.5.0", Max: "v0.7.0"},
+		flagPrefix:        "-",
+		clusterFlagPrefix: "mesh",
+		probePath:         "/api/v1/status",
+		apiPath:           "/api/v1",
+	},
+	{
+		versions:          prometheusoperator.VersionRange{Min: "v0.7.0", Max: "v0.13.0"},
+		releases:          []string{"v0.7.0", "v0.7.1"},
+		flagPrefix:        "-",
+		routePrefix:       true,
+		clusterFlagPrefix: "mesh",
+		probePath:         "/api/v1/status",
+		apiPath:           "/api/v1",
+	},
+	{
+		// Alertmanager parses flags with kingpin starting with v0.13.0.
+		versions:          prometheusoperator.VersionRange{Min: "v0.13.0", Max: "v0.15.0-rc.0"},
+		flagPrefix:        "--",
+		routePrefix:       true,
+		clusterFlagPrefix: "mesh",
+		probePath:         "/api/v1/status",
+		apiPath:           "/api/v1",
+	},
+	{
+		// The mesh flags are replaced by the cluster flags of the gossip
+		// based cluster starting with v0.15.0.
+		versions:          prometheusoperator.VersionRange{Min: "v0.15.0-rc.0", Max: "v0.16.0"},
+		flagPrefix:        "--",
+		routePrefix:       true,
+		clusterFlagPrefix: "cluster",
+		probePath:         "/api/v1/status",
+		apiPath:           "/api/v1",
+	},
+	{
+		// The v2 API is served starting with v0.16.0. The v1 API is removed
+		// in v0.27.0.
+		versions:          prometheusoperator.VersionRange{Min: "v0.16.0", Max: "v1.0.0"},
+		flagPrefix:        "--",
+		routePrefix:       true,
+		clusterFlagPrefix: "cluster",
+		probePath:         "/api/v2/status",
+		apiPath:           "/api/v2",
+	},
+}
+
+// CompatibilityMatrix lists the Alertmanager releases tested with the
+// operator.
+var CompatibilityMatrix = compatibilityMatrix()
+
+func compatibilityMatrix() []string {
+	res := []string{}
+	for _, p := range versionProfiles {
+		res = append(res, p.releases...)
+	}
+	return res
+}
+
+// UnsupportedVersionError is returned for Alertmanager versions without a
+// version profile.
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	ranges := make([]string, 0, len(versionProfiles))
+	for _, p := range versionProfiles {
+		ranges = append(ranges, p.versions.String())
+	}
+	return fmt.Sprintf("unsupported Alertmanager version %s, supported versions are %s", e.Version, strings.Join(ranges, ", "))
+}
+
+// profileForVersion returns the version profile of the Alertmanager version.
+func profileForVersion(s string) (*versionProfile, error) {
+	v, err := prometheusoperator.ParseVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versionProfiles {
+		if versionProfiles[i].versions.Contains(v) {
+			return &versionProfiles[i], nil
+		}
+	}
+	return nil, &UnsupportedVersionError{Version: s}
+}
+
+// profileForAlertmanager returns the version profile of the Alertmanager,
+// which runs the default version if none is set.
+func profileForAlertmanager(am *v1alpha1.Alertmanager) (*versionProfile, error) {
+	version := am.Spec.Version
+	if version == "" {
+		version = defaultVersion
+	}
+	return profileForVersion(version)
+}
+
+// flags returns the flags with the flag prefix of the profile.
+func (vp *versionProfile) flags(flags ...string) []string {
+	res := make([]string, 0, len(flags))
+	for _, f := range flags {
+		res = append(res, vp.flagPrefix+f)
+	}
+	return res
+}
@@ -27,13 +27,20 @@ import (
 	"github.com/coreos/prometheus-operator/pkg/prometheus"
 )
 
+// StorageUsageSource returns the last observed disk usage of the pods of a
+// Prometheus.
+type StorageUsageSource interface {
+	StorageUsage(namespace, name string) []v1alpha1.StorageUsage
+}
+
 type API struct {
 	kclient *kubernetes.Clientset
 	mclient *v1alpha1.MonitoringV1alpha1Client
+	storage StorageUsageSource
 	logger  log.Logger
 }
 
-func New(conf prometheus.Config, l log.Logger) (*API, error) {
+func New(conf prometheus.Config, storage StorageUsageSource, l log.Logger) (*API, error) {
 	cfg, err := k8sutil.NewClusterConfig(conf.Host, conf.TLSInsecure, &conf.TLSConfig)
 	if err != nil {
 		return nil, err
@@ -52,6 +59,7 @@ func New(conf prometheus.Config, l log.Logger) (*API, error) {
 	return &API{
 		kclient: kclient,
 		mclient: mclient,
+		storage: storage,
 		logger:  l,
 	}, nil
 }
@@ -108,6 +116,9 @@ func (api *API) prometheusStatus(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		api.logger.Log("error", err)
 	}
+	if p.Status != nil {
+		p.Status.Storage = api.storage.StorageUsage(p.Namespace, p.Name)
+	}
 
 	b, err := json.Marshal(p)
 	if err != nil {
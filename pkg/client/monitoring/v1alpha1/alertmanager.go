@@ -19,6 +19,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
@@ -105,11 +106,17 @@ func (a *alertmanagers) Delete(name string, options *metav1.DeleteOptions) error
 }
 
 func (a *alertmanagers) List(opts metav1.ListOptions) (runtime.Object, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	req := a.restClient.Get().
 		Namespace(a.ns).
 		Resource("alertmanagers").
 		// VersionedParams(&options, api.ParameterCodec)
-		FieldsSelectorParam(nil)
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector)
 
 	b, err := req.DoRaw()
 	if err != nil {
@@ -120,12 +127,18 @@ func (a *alertmanagers) List(opts metav1.ListOptions) (runtime.Object, error) {
 }
 
 func (a *alertmanagers) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	r, err := a.restClient.Get().
 		Prefix("watch").
 		Namespace(a.ns).
 		Resource("alertmanagers").
 		// VersionedParams(&options, api.ParameterCodec).
 		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector).
 		Stream()
 	if err != nil {
 		return nil, err
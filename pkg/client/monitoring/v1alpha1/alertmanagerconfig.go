@@ -0,0 +1,200 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	TPRAlertmanagerConfigsKind = "AlertmanagerConfig"
+	TPRAlertmanagerConfigName  = "alertmanagerconfigs"
+)
+
+type AlertmanagerConfigsGetter interface {
+	AlertmanagerConfigs(namespace string) AlertmanagerConfigInterface
+}
+
+type AlertmanagerConfigInterface interface {
+	Create(*AlertmanagerConfig) (*AlertmanagerConfig, error)
+	Get(name string) (*AlertmanagerConfig, error)
+	Update(*AlertmanagerConfig) (*AlertmanagerConfig, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	List(opts metav1.ListOptions) (runtime.Object, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type alertmanagerconfigs struct {
+	restClient rest.Interface
+	client     *dynamic.ResourceClient
+	ns         string
+}
+
+func newAlertmanagerConfigs(r rest.Interface, c *dynamic.Client, namespace string) *alertmanagerconfigs {
+	return &alertmanagerconfigs{
+		r,
+		c.Resource(
+			&metav1.APIResource{
+				Kind:       TPRAlertmanagerConfigsKind,
+				Name:       TPRAlertmanagerConfigName,
+				Namespaced: true,
+			},
+			namespace,
+		),
+		namespace,
+	}
+}
+
+func (s *alertmanagerconfigs) Create(o *AlertmanagerConfig) (*AlertmanagerConfig, error) {
+	us, err := UnstructuredFromAlertmanagerConfig(o)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err = s.client.Create(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return AlertmanagerConfigFromUnstructured(us)
+}
+
+func (s *alertmanagerconfigs) Get(name string) (*AlertmanagerConfig, error) {
+	obj, err := s.client.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return AlertmanagerConfigFromUnstructured(obj)
+}
+
+func (s *alertmanagerconfigs) Update(o *AlertmanagerConfig) (*AlertmanagerConfig, error) {
+	us, err := UnstructuredFromAlertmanagerConfig(o)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err = s.client.Update(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return AlertmanagerConfigFromUnstructured(us)
+}
+
+func (s *alertmanagerconfigs) Delete(name string, options *metav1.DeleteOptions) error {
+	return s.client.Delete(name, options)
+}
+
+func (s *alertmanagerconfigs) List(opts metav1.ListOptions) (runtime.Object, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	req := s.restClient.Get().
+		Namespace(s.ns).
+		Resource("alertmanagerconfigs").
+		// VersionedParams(&options, v1.ParameterCodec)
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector)
+
+	b, err := req.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var sm AlertmanagerConfigList
+	return &sm, json.Unmarshal(b, &sm)
+}
+
+func (s *alertmanagerconfigs) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.restClient.Get().
+		Prefix("watch").
+		Namespace(s.ns).
+		Resource("alertmanagerconfigs").
+		// VersionedParams(&options, v1.ParameterCodec).
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector).
+		Stream()
+	if err != nil {
+		return nil, err
+	}
+	return watch.NewStreamWatcher(&alertmanagerConfigDecoder{
+		dec:   json.NewDecoder(r),
+		close: r.Close,
+	}), nil
+}
+
+// AlertmanagerConfigFromUnstructured unmarshals a AlertmanagerConfig object from dynamic client's unstructured
+func AlertmanagerConfigFromUnstructured(r *unstructured.Unstructured) (*AlertmanagerConfig, error) {
+	b, err := json.Marshal(r.Object)
+	if err != nil {
+		return nil, err
+	}
+	var s AlertmanagerConfig
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	s.TypeMeta.Kind = TPRAlertmanagerConfigsKind
+	s.TypeMeta.APIVersion = TPRGroup + "/" + TPRVersion
+	return &s, nil
+}
+
+// UnstructuredFromAlertmanagerConfig marshals a AlertmanagerConfig object into dynamic client's unstructured
+func UnstructuredFromAlertmanagerConfig(s *AlertmanagerConfig) (*unstructured.Unstructured, error) {
+	s.TypeMeta.Kind = TPRAlertmanagerConfigsKind
+	s.TypeMeta.APIVersion = TPRGroup + "/" + TPRVersion
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var r unstructured.Unstructured
+	if err := json.Unmarshal(b, &r.Object); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+type alertmanagerConfigDecoder struct {
+	dec   *json.Decoder
+	close func() error
+}
+
+func (d *alertmanagerConfigDecoder) Close() {
+	d.close()
+}
+
+func (d *alertmanagerConfigDecoder) Decode() (action watch.EventType, object runtime.Object, err error) {
+	var e struct {
+		Type   watch.EventType
+		Object AlertmanagerConfig
+	}
+	if err := d.dec.Decode(&e); err != nil {
+		return watch.Error, nil, err
+	}
+	return e.Type, &e.Object, nil
+}
@@ -32,6 +32,9 @@ type MonitoringV1alpha1Interface interface {
 	PrometheusesGetter
 	AlertmanagersGetter
 	ServiceMonitorsGetter
+	PrometheusSnapshotsGetter
+	AlertmanagerConfigsGetter
+	SilencesGetter
 }
 
 type MonitoringV1alpha1Client struct {
@@ -51,6 +54,18 @@ func (c *MonitoringV1alpha1Client) ServiceMonitors(namespace string) ServiceMoni
 	return newServiceMonitors(c.restClient, c.dynamicClient, namespace)
 }
 
+func (c *MonitoringV1alpha1Client) PrometheusSnapshots(namespace string) PrometheusSnapshotInterface {
+	return newPrometheusSnapshots(c.restClient, c.dynamicClient, namespace)
+}
+
+func (c *MonitoringV1alpha1Client) AlertmanagerConfigs(namespace string) AlertmanagerConfigInterface {
+	return newAlertmanagerConfigs(c.restClient, c.dynamicClient, namespace)
+}
+
+func (c *MonitoringV1alpha1Client) Silences(namespace string) SilenceInterface {
+	return newSilences(c.restClient, c.dynamicClient, namespace)
+}
+
 func (c *MonitoringV1alpha1Client) RESTClient() rest.Interface {
 	return c.restClient
 }
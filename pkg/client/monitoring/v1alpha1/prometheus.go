@@ -19,6 +19,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
@@ -105,11 +106,17 @@ func (p *prometheuses) Delete(name string, options *metav1.DeleteOptions) error
 }
 
 func (p *prometheuses) List(opts metav1.ListOptions) (runtime.Object, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	req := p.restClient.Get().
 		Namespace(p.ns).
 		Resource("prometheuses").
 		// VersionedParams(&options, v1.ParameterCodec)
-		FieldsSelectorParam(nil)
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector)
 
 	b, err := req.DoRaw()
 	if err != nil {
@@ -120,12 +127,18 @@ func (p *prometheuses) List(opts metav1.ListOptions) (runtime.Object, error) {
 }
 
 func (p *prometheuses) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	r, err := p.restClient.Get().
 		Prefix("watch").
 		Namespace(p.ns).
 		Resource("prometheuses").
 		// VersionedParams(&options, v1.ParameterCodec).
 		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector).
 		Stream()
 	if err != nil {
 		return nil, err
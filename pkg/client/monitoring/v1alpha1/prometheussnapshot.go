@@ -0,0 +1,200 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	TPRPrometheusSnapshotsKind = "PrometheusSnapshot"
+	TPRPrometheusSnapshotName  = "prometheussnapshots"
+)
+
+type PrometheusSnapshotsGetter interface {
+	PrometheusSnapshots(namespace string) PrometheusSnapshotInterface
+}
+
+type PrometheusSnapshotInterface interface {
+	Create(*PrometheusSnapshot) (*PrometheusSnapshot, error)
+	Get(name string) (*PrometheusSnapshot, error)
+	Update(*PrometheusSnapshot) (*PrometheusSnapshot, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	List(opts metav1.ListOptions) (runtime.Object, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type prometheussnapshots struct {
+	restClient rest.Interface
+	client     *dynamic.ResourceClient
+	ns         string
+}
+
+func newPrometheusSnapshots(r rest.Interface, c *dynamic.Client, namespace string) *prometheussnapshots {
+	return &prometheussnapshots{
+		r,
+		c.Resource(
+			&metav1.APIResource{
+				Kind:       TPRPrometheusSnapshotsKind,
+				Name:       TPRPrometheusSnapshotName,
+				Namespaced: true,
+			},
+			namespace,
+		),
+		namespace,
+	}
+}
+
+func (s *prometheussnapshots) Create(o *PrometheusSnapshot) (*PrometheusSnapshot, error) {
+	us, err := UnstructuredFromPrometheusSnapshot(o)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err = s.client.Create(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return PrometheusSnapshotFromUnstructured(us)
+}
+
+func (s *prometheussnapshots) Get(name string) (*PrometheusSnapshot, error) {
+	obj, err := s.client.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return PrometheusSnapshotFromUnstructured(obj)
+}
+
+func (s *prometheussnapshots) Update(o *PrometheusSnapshot) (*PrometheusSnapshot, error) {
+	us, err := UnstructuredFromPrometheusSnapshot(o)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err = s.client.Update(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return PrometheusSnapshotFromUnstructured(us)
+}
+
+func (s *prometheussnapshots) Delete(name string, options *metav1.DeleteOptions) error {
+	return s.client.Delete(name, options)
+}
+
+func (s *prometheussnapshots) List(opts metav1.ListOptions) (runtime.Object, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	req := s.restClient.Get().
+		Namespace(s.ns).
+		Resource("prometheussnapshots").
+		// VersionedParams(&options, v1.ParameterCodec)
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector)
+
+	b, err := req.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var sm PrometheusSnapshotList
+	return &sm, json.Unmarshal(b, &sm)
+}
+
+func (s *prometheussnapshots) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.restClient.Get().
+		Prefix("watch").
+		Namespace(s.ns).
+		Resource("prometheussnapshots").
+		// VersionedParams(&options, v1.ParameterCodec).
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector).
+		Stream()
+	if err != nil {
+		return nil, err
+	}
+	return watch.NewStreamWatcher(&prometheusSnapshotDecoder{
+		dec:   json.NewDecoder(r),
+		close: r.Close,
+	}), nil
+}
+
+// PrometheusSnapshotFromUnstructured unmarshals a PrometheusSnapshot object from dynamic client's unstructured
+func PrometheusSnapshotFromUnstructured(r *unstructured.Unstructured) (*PrometheusSnapshot, error) {
+	b, err := json.Marshal(r.Object)
+	if err != nil {
+		return nil, err
+	}
+	var s PrometheusSnapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	s.TypeMeta.Kind = TPRPrometheusSnapshotsKind
+	s.TypeMeta.APIVersion = TPRGroup + "/" + TPRVersion
+	return &s, nil
+}
+
+// UnstructuredFromPrometheusSnapshot marshals a PrometheusSnapshot object into dynamic client's unstructured
+func UnstructuredFromPrometheusSnapshot(s *PrometheusSnapshot) (*unstructured.Unstructured, error) {
+	s.TypeMeta.Kind = TPRPrometheusSnapshotsKind
+	s.TypeMeta.APIVersion = TPRGroup + "/" + TPRVersion
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var r unstructured.Unstructured
+	if err := json.Unmarshal(b, &r.Object); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+type prometheusSnapshotDecoder struct {
+	dec   *json.Decoder
+	close func() error
+}
+
+func (d *prometheusSnapshotDecoder) Close() {
+	d.close()
+}
+
+func (d *prometheusSnapshotDecoder) Decode() (action watch.EventType, object runtime.Object, err error) {
+	var e struct {
+		Type   watch.EventType
+		Object PrometheusSnapshot
+	}
+	if err := d.dec.Decode(&e); err != nil {
+		return watch.Error, nil, err
+	}
+	return e.Type, &e.Object, nil
+}
@@ -19,6 +19,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
@@ -105,11 +106,17 @@ func (s *servicemonitors) Delete(name string, options *metav1.DeleteOptions) err
 }
 
 func (s *servicemonitors) List(opts metav1.ListOptions) (runtime.Object, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	req := s.restClient.Get().
 		Namespace(s.ns).
 		Resource("servicemonitors").
 		// VersionedParams(&options, v1.ParameterCodec)
-		FieldsSelectorParam(nil)
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector)
 
 	b, err := req.DoRaw()
 	if err != nil {
@@ -120,12 +127,18 @@ func (s *servicemonitors) List(opts metav1.ListOptions) (runtime.Object, error)
 }
 
 func (s *servicemonitors) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	r, err := s.restClient.Get().
 		Prefix("watch").
 		Namespace(s.ns).
 		Resource("servicemonitors").
 		// VersionedParams(&options, v1.ParameterCodec).
 		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector).
 		Stream()
 	if err != nil {
 		return nil, err
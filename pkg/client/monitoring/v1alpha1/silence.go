@@ -0,0 +1,200 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	TPRSilencesKind = "Silence"
+	TPRSilenceName  = "silences"
+)
+
+type SilencesGetter interface {
+	Silences(namespace string) SilenceInterface
+}
+
+type SilenceInterface interface {
+	Create(*Silence) (*Silence, error)
+	Get(name string) (*Silence, error)
+	Update(*Silence) (*Silence, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	List(opts metav1.ListOptions) (runtime.Object, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type silences struct {
+	restClient rest.Interface
+	client     *dynamic.ResourceClient
+	ns         string
+}
+
+func newSilences(r rest.Interface, c *dynamic.Client, namespace string) *silences {
+	return &silences{
+		r,
+		c.Resource(
+			&metav1.APIResource{
+				Kind:       TPRSilencesKind,
+				Name:       TPRSilenceName,
+				Namespaced: true,
+			},
+			namespace,
+		),
+		namespace,
+	}
+}
+
+func (s *silences) Create(o *Silence) (*Silence, error) {
+	us, err := UnstructuredFromSilence(o)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err = s.client.Create(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return SilenceFromUnstructured(us)
+}
+
+func (s *silences) Get(name string) (*Silence, error) {
+	obj, err := s.client.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return SilenceFromUnstructured(obj)
+}
+
+func (s *silences) Update(o *Silence) (*Silence, error) {
+	us, err := UnstructuredFromSilence(o)
+	if err != nil {
+		return nil, err
+	}
+
+	us, err = s.client.Update(us)
+	if err != nil {
+		return nil, err
+	}
+
+	return SilenceFromUnstructured(us)
+}
+
+func (s *silences) Delete(name string, options *metav1.DeleteOptions) error {
+	return s.client.Delete(name, options)
+}
+
+func (s *silences) List(opts metav1.ListOptions) (runtime.Object, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	req := s.restClient.Get().
+		Namespace(s.ns).
+		Resource("silences").
+		// VersionedParams(&options, v1.ParameterCodec)
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector)
+
+	b, err := req.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var sm SilenceList
+	return &sm, json.Unmarshal(b, &sm)
+}
+
+func (s *silences) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.restClient.Get().
+		Prefix("watch").
+		Namespace(s.ns).
+		Resource("silences").
+		// VersionedParams(&options, v1.ParameterCodec).
+		FieldsSelectorParam(nil).
+		LabelsSelectorParam(selector).
+		Stream()
+	if err != nil {
+		return nil, err
+	}
+	return watch.NewStreamWatcher(&silenceDecoder{
+		dec:   json.NewDecoder(r),
+		close: r.Close,
+	}), nil
+}
+
+// SilenceFromUnstructured unmarshals a Silence object from dynamic client's unstructured
+func SilenceFromUnstructured(r *unstructured.Unstructured) (*Silence, error) {
+	b, err := json.Marshal(r.Object)
+	if err != nil {
+		return nil, err
+	}
+	var s Silence
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	s.TypeMeta.Kind = TPRSilencesKind
+	s.TypeMeta.APIVersion = TPRGroup + "/" + TPRVersion
+	return &s, nil
+}
+
+// UnstructuredFromSilence marshals a Silence object into dynamic client's unstructured
+func UnstructuredFromSilence(s *Silence) (*unstructured.Unstructured, error) {
+	s.TypeMeta.Kind = TPRSilencesKind
+	s.TypeMeta.APIVersion = TPRGroup + "/" + TPRVersion
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var r unstructured.Unstructured
+	if err := json.Unmarshal(b, &r.Object); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+type silenceDecoder struct {
+	dec   *json.Decoder
+	close func() error
+}
+
+func (d *silenceDecoder) Close() {
+	d.close()
+}
+
+func (d *silenceDecoder) Decode() (action watch.EventType, object runtime.Object, err error) {
+	var e struct {
+		Type   watch.EventType
+		Object Silence
+	}
+	if err := d.dec.Decode(&e); err != nil {
+		return watch.Error, nil, err
+	}
+	return e.Type, &e.Object, nil
+}
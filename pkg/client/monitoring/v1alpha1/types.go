@@ -66,6 +66,9 @@ type PrometheusSpec struct {
 	Replicas *int32 `json:"replicas,omitempty"`
 	// Time duration Prometheus shall retain data for.
 	Retention string `json:"retention,omitempty"`
+	// Maximum size of the persisted blocks, e.g. 100GB. The oldest blocks
+	// are removed first. Requires Prometheus 2.7 or later.
+	RetentionSize string `json:"retentionSize,omitempty"`
 	// Interval between consecutive evaluations.
 	EvaluationInterval string `json:"evaluationInterval,omitempty"`
 	// The labels to add to any time series or alerts when communicating with
@@ -96,10 +99,11 @@ type PrometheusSpec struct {
 	// Secrets is a list of Secrets in the same namespace as the Prometheus
 	// object, which shall be mounted into the Prometheus Pods.
 	// The Secrets are mounted into /etc/prometheus/secrets/<secret-name>.
-	// Secrets changes after initial creation of a Prometheus object are not
-	// reflected in the running Pods. To change the secrets mounted into the
-	// Prometheus Pods, the object must be deleted and recreated with the new list
-	// of secrets.
+	// Secrets changes after initial creation of a Prometheus object are only
+	// reflected in the running Pods if the object has the
+	// `monitoring.coreos.com/recreate-statefulset` annotation set to "true",
+	// which allows the operator to recreate the StatefulSet. Otherwise the
+	// object must be deleted and recreated with the new list of secrets.
 	Secrets []string `json:"secrets,omitempty"`
 	// EvaluationInterval string                    `json:"evaluationInterval"`
 	// Remote          RemoteSpec                 `json:"remote"`
@@ -114,6 +118,48 @@ type PrometheusSpec struct {
 	RemoteWrite []RemoteWriteSpec `json:"remoteWrite,omitempty"`
 	// If specified, the remote_read spec. This is an experimental feature, it may change in any upcoming release in a breaking way.
 	RemoteRead []RemoteReadSpec `json:"remoteRead,omitempty"`
+	// Defines how Pods running an outdated version are replaced.
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+	// If specified and more than one replica is requested, a
+	// PodDisruptionBudget limiting voluntary disruptions of the Pods is
+	// created.
+	PodDisruptionBudget *PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+	// Labels and annotations added to the Pods. Labels set by the operator
+	// take precedence.
+	PodMetadata *metav1.ObjectMeta `json:"podMetadata,omitempty"`
+	// SecurityContext holds pod-level security attributes and common
+	// container settings.
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// Containers merged into the containers generated by the operator. A
+	// container with the name of a generated container, such as "prometheus", is
+	// merged over it: environment variables, ports and volume mounts are
+	// merged by name, container port and mount path, all other fields that
+	// are set replace the generated ones. Other containers are added to the
+	// Pods.
+	Containers []v1.Container `json:"containers,omitempty"`
+	// Init containers added to the Pods.
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// Volumes added to the Pods.
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// Volume mounts added to the Prometheus container.
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+	// If specified, a Service selecting only the Pods of this Prometheus is
+	// created.
+	Service *ServiceSpec `json:"service,omitempty"`
+	// If specified, an Ingress routing the external URL to the Service of
+	// this Prometheus is created. The Service is created with default settings if
+	// not specified.
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// Settings of the query engine.
+	Query *QuerySpec `json:"query,omitempty"`
+	// Settings of the web server.
+	Web *WebSpec `json:"web,omitempty"`
+	// Settings of the Prometheus 2 time series database.
+	TSDB *TSDBSpec `json:"tsdb,omitempty"`
+	// Additional command line flags of Prometheus, for settings without a
+	// field. Flags set by the operator, such as config.file,
+	// storage.tsdb.path or web.route-prefix, are rejected.
+	AdditionalArgs []Argument `json:"additionalArgs,omitempty"`
 }
 
 // Most recent observed status of the Prometheus cluster. Read-only. Not
@@ -135,6 +181,206 @@ type PrometheusStatus struct {
 	AvailableReplicas int32 `json:"availableReplicas"`
 	// Total number of unavailable pods targeted by this Prometheus deployment.
 	UnavailableReplicas int32 `json:"unavailableReplicas"`
+	// Whether changes of the spec can't be applied to the StatefulSet in
+	// place. The StatefulSet is only recreated to apply them if the
+	// Prometheus object has the `monitoring.coreos.com/recreate-statefulset`
+	// annotation set to "true".
+	RecreatePending bool `json:"recreatePending,omitempty"`
+	// Conditions recorded by the operator.
+	Conditions []Condition `json:"conditions,omitempty"`
+	// Disk usage of the time series database of each available Pod.
+	Storage []StorageUsage `json:"storage,omitempty"`
+	// PVCs whose volume is being expanded to the requested storage size.
+	VolumeExpansions []VolumeExpansion `json:"volumeExpansions,omitempty"`
+}
+
+// VolumeExpansion is the progress of expanding the volume of a PVC.
+type VolumeExpansion struct {
+	// Name of the PVC.
+	Claim string `json:"claim"`
+	// Requested storage size.
+	Requested string `json:"requested"`
+	// Current capacity of the volume.
+	Capacity string `json:"capacity"`
+}
+
+// StorageUsage is the disk usage of the time series database of a Prometheus
+// Pod, as reported by Prometheus 2.
+type StorageUsage struct {
+	// Name of the Pod.
+	Pod string `json:"pod"`
+	// Bytes used by the persisted blocks and the write-ahead log.
+	UsedBytes int64 `json:"usedBytes"`
+	// Bytes requested by the volume claim template. Unset if the Pod stores
+	// its data in an emptyDir.
+	CapacityBytes int64 `json:"capacityBytes,omitempty"`
+}
+
+// ConditionType is the type of a condition of a Prometheus, Alertmanager or
+// PrometheusSnapshot.
+type ConditionType string
+
+const (
+	// ConditionVersionSupported is false if the operator does not support
+	// the requested version. The StatefulSet is not updated in that case.
+	ConditionVersionSupported ConditionType = "VersionSupported"
+	// ConditionConfigValid is false if the configuration of an Alertmanager
+	// failed validation.
+	ConditionConfigValid ConditionType = "ConfigValid"
+	// ConditionConfigProvided is false if an Alertmanager runs the default
+	// configuration the operator created, as its config Secret did not exist.
+	ConditionConfigProvided ConditionType = "ConfigProvided"
+	// ConditionAlertmanagerConfigsConverted is false if AlertmanagerConfigs
+	// selected by an Alertmanager could not be converted and were left out
+	// of its configuration.
+	ConditionAlertmanagerConfigsConverted ConditionType = "AlertmanagerConfigsConverted"
+	// ConditionVolumesExpanded is false while the persistent volume claims
+	// of a Prometheus or Alertmanager are not yet expanded to increased
+	// storage requests, or if their StorageClass does not allow expansion.
+	ConditionVolumesExpanded ConditionType = "VolumesExpanded"
+	// ConditionSnapshotsPruned is false if a Job deleting the snapshots of a
+	// PrometheusSnapshot exceeding its retention failed. It is retried after
+	// a delay.
+	ConditionSnapshotsPruned ConditionType = "SnapshotsPruned"
+)
+
+// Condition describes an aspect of the state of a Prometheus, Alertmanager
+// or PrometheusSnapshot.
+type Condition struct {
+	// Type of the condition.
+	Type ConditionType `json:"type"`
+	// Status of the condition, one of True, False or Unknown.
+	Status v1.ConditionStatus `json:"status"`
+	// Last time the status of the condition changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Machine readable reason of the last transition.
+	Reason string `json:"reason,omitempty"`
+	// Human readable details of the last transition.
+	Message string `json:"message,omitempty"`
+}
+
+// UpdateStrategy defines how the Pods of a Prometheus or Alertmanager
+// cluster running an outdated version are replaced one after another.
+type UpdateStrategy struct {
+	// Maximum number of Pods that may be unavailable during the update,
+	// including the Pods being replaced. Defaults to 1.
+	MaxUnavailable *int32 `json:"maxUnavailable,omitempty"`
+	// Minimum number of seconds a replaced Pod must be ready before it is
+	// considered available and the next Pod is replaced. Defaults to 0.
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+	// Order in which outdated Pods are replaced. Either "PodAge" to replace
+	// the oldest Pod first, or "Ordinal" to replace the Pod with the highest
+	// ordinal first. Defaults to "PodAge".
+	Order UpdateOrder `json:"order,omitempty"`
+	// If specified, replaced Pods are only considered available once an HTTP
+	// request to the server running in them succeeds.
+	ReadinessGate *ReadinessGate `json:"readinessGate,omitempty"`
+}
+
+// PodDisruptionBudgetSpec defines the PodDisruptionBudget of a Prometheus or
+// Alertmanager cluster. At most one of MinAvailable and MaxUnavailable may be
+// set. If neither is set, MaxUnavailable defaults to 1.
+type PodDisruptionBudgetSpec struct {
+	// Number or percentage of Pods that must remain available during
+	// voluntary disruptions.
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// Number or percentage of Pods that may be unavailable during voluntary
+	// disruptions.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// QuerySpec defines the settings of the Prometheus query engine. Fields not
+// supported by the Prometheus version are reported in the VersionSupported
+// condition and keep the Prometheus from being updated.
+type QuerySpec struct {
+	// Maximum time a query may take before it is aborted, e.g. 2m.
+	Timeout string `json:"timeout,omitempty"`
+	// Maximum number of queries executed concurrently.
+	MaxConcurrency *int32 `json:"maxConcurrency,omitempty"`
+	// Maximum time to look back for a sample of a series, e.g. 5m. Sets the
+	// staleness delta on Prometheus 1.
+	LookbackDelta string `json:"lookbackDelta,omitempty"`
+	// Maximum number of samples a single query may load into memory.
+	// Requires Prometheus 2.5 or later.
+	MaxSamples *int32 `json:"maxSamples,omitempty"`
+}
+
+// WebSpec defines the settings of the Prometheus web server. Fields not
+// supported by the Prometheus version are reported in the VersionSupported
+// condition and keep the Prometheus from being updated.
+type WebSpec struct {
+	// Enable the API endpoints for administrative actions like deleting
+	// series. Requires Prometheus 2.
+	EnableAdminAPI bool `json:"enableAdminAPI,omitempty"`
+	// Maximum number of simultaneous connections.
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+	// Maximum duration before timing out reads of a request, e.g. 5m.
+	ReadTimeout string `json:"readTimeout,omitempty"`
+}
+
+// TSDBSpec defines the settings of the Prometheus 2 time series database.
+// Fields not supported by the Prometheus version are reported in the
+// VersionSupported condition and keep the Prometheus from being updated.
+type TSDBSpec struct {
+	// Minimum duration of a data block before being persisted, e.g. 2h.
+	MinBlockDuration string `json:"minBlockDuration,omitempty"`
+	// Maximum duration compacted blocks may span, e.g. 36h.
+	MaxBlockDuration string `json:"maxBlockDuration,omitempty"`
+	// Compress the write-ahead log. Requires Prometheus 2.11 or later.
+	WALCompression bool `json:"walCompression,omitempty"`
+}
+
+// Argument is a command line flag.
+type Argument struct {
+	// Name of the flag, without leading dashes.
+	Name string `json:"name"`
+	// Value of the flag. Boolean flags are set without a value if empty.
+	Value string `json:"value,omitempty"`
+}
+
+// ServiceSpec defines the Service of a single Prometheus or Alertmanager. It
+// is named like the StatefulSet.
+type ServiceSpec struct {
+	// Type of the Service. Defaults to ClusterIP.
+	Type v1.ServiceType `json:"type,omitempty"`
+	// Annotations added to the Service.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Ports of the Service. Defaults to the port named web. An Ingress
+	// routes to the port named web.
+	Ports []v1.ServicePort `json:"ports,omitempty"`
+}
+
+// IngressSpec defines the Ingress of a single Prometheus or Alertmanager. Its
+// host is the host of the external URL, its path the path of the external
+// URL, or the route prefix if the external URL has no path.
+type IngressSpec struct {
+	// Annotations added to the Ingress, for example to configure the Ingress
+	// controller.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Name of the Secret holding the TLS certificate for the host. TLS is
+	// not configured if empty.
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// UpdateOrder is the order in which outdated Pods are replaced.
+type UpdateOrder string
+
+const (
+	// UpdateOrderPodAge replaces the oldest Pod first.
+	UpdateOrderPodAge UpdateOrder = "PodAge"
+	// UpdateOrderOrdinal replaces the Pod with the highest ordinal first.
+	UpdateOrderOrdinal UpdateOrder = "Ordinal"
+)
+
+// ReadinessGate defines an HTTP request to the Prometheus or Alertmanager
+// server of a Pod that must succeed before the Pod is considered available.
+type ReadinessGate struct {
+	// HTTP path, including the route prefix and an optional query string.
+	// Defaults to a query of the `up` metric for Prometheus and to the
+	// status endpoint for Alertmanager.
+	Path string `json:"path,omitempty"`
+	// Number of seconds after which the request times out. Defaults to 3.
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
 }
 
 // AlertingSpec defines parameters for alerting configuration of Prometheus servers.
@@ -156,10 +402,27 @@ type StorageSpec struct {
 	// info: http://kubernetes.io/docs/user-guide/persistent-volumes#resources
 	// DEPRECATED
 	Resources v1.ResourceRequirements `json:"resources"`
-	// A PVC spec to be used by the Prometheus StatefulSets.
+	// A PVC spec to be used by the Prometheus StatefulSets. Increasing the
+	// storage request expands the existing PVCs, if their StorageClass allows
+	// volume expansion.
 	VolumeClaimTemplate v1.PersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
+	// Whether the PVCs are retained or deleted when the Prometheus or
+	// Alertmanager object is deleted. Defaults to Retain.
+	PVCRetentionPolicy PVCRetentionPolicy `json:"pvcRetentionPolicy,omitempty"`
 }
 
+// PVCRetentionPolicy defines what happens to the PVCs of a Prometheus or
+// Alertmanager when it is deleted.
+type PVCRetentionPolicy string
+
+const (
+	// PVCRetentionPolicyRetain keeps the PVCs, so that the data is reused if
+	// the object is created again.
+	PVCRetentionPolicyRetain PVCRetentionPolicy = "Retain"
+	// PVCRetentionPolicyDelete deletes the PVCs along with the StatefulSet.
+	PVCRetentionPolicyDelete PVCRetentionPolicy = "Delete"
+)
+
 // AlertmanagerEndpoints defines a selection of a single Endpoints object
 // containing alertmanager IPs to fire alerts against.
 type AlertmanagerEndpoints struct {
@@ -254,6 +517,68 @@ type ServiceMonitorList struct {
 	Items []*ServiceMonitor `json:"items"`
 }
 
+// PrometheusSnapshot defines scheduled snapshots of the time series database
+// of a Prometheus.
+type PrometheusSnapshot struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object’s metadata. More info:
+	// https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the desired snapshots.
+	Spec PrometheusSnapshotSpec `json:"spec"`
+	// Most recent observed status of the snapshots. Read-only.
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#spec-and-status
+	Status *PrometheusSnapshotStatus `json:"status,omitempty"`
+}
+
+// PrometheusSnapshotSpec is a specification of scheduled snapshots of a
+// Prometheus.
+type PrometheusSnapshotSpec struct {
+	// Name of the Prometheus object in the same namespace whose data is
+	// snapshotted. Snapshots require Prometheus 2 with persistent storage. The
+	// admin API of the Prometheus is enabled while it is referenced.
+	Prometheus string `json:"prometheus"`
+	// Schedule of the snapshots in cron format, e.g. "0 */6 * * *". Times
+	// are in UTC.
+	Schedule string `json:"schedule"`
+	// Number of snapshots kept for each Pod. Older snapshots are deleted. All
+	// snapshots are kept if unset.
+	Retention int32 `json:"retention,omitempty"`
+}
+
+// PrometheusSnapshotStatus is the status of scheduled snapshots of a
+// Prometheus.
+type PrometheusSnapshotStatus struct {
+	// Time the snapshots were last taken.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// Snapshots currently kept, oldest first.
+	Snapshots []TSDBSnapshot `json:"snapshots,omitempty"`
+	// Conditions of the PrometheusSnapshot.
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// TSDBSnapshot is a snapshot of the time series database of a Prometheus
+// Pod.
+type TSDBSnapshot struct {
+	// Name of the Pod.
+	Pod string `json:"pod"`
+	// Name of the snapshot directory in the snapshots directory of the data
+	// directory.
+	Name string `json:"name"`
+	// Time the snapshot was taken.
+	Time metav1.Time `json:"time"`
+}
+
+// A list of PrometheusSnapshots.
+type PrometheusSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of PrometheusSnapshots
+	Items []*PrometheusSnapshot `json:"items"`
+}
+
 // Describes an Alertmanager cluster.
 type Alertmanager struct {
 	metav1.TypeMeta `json:",inline"`
@@ -288,6 +613,9 @@ type AlertmanagerSpec struct {
 	// Storage is the definition of how storage will be used by the Alertmanager
 	// instances.
 	Storage *StorageSpec `json:"storage,omitempty"`
+	// Time duration Alertmanager retains silences and the notification log
+	// for after they expire, e.g. 120h. Defaults to 120h.
+	Retention string `json:"retention,omitempty"`
 	// The external URL the Alertmanager instances will be available under. This is
 	// necessary to generate correct URLs. This is necessary if Alertmanager is not
 	// served from root of a DNS name.
@@ -309,6 +637,66 @@ type AlertmanagerSpec struct {
 	Affinity *v1.Affinity `json:"affinity,omitempty"`
 	// If specified, the pod's tolerations.
 	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Defines how Pods running an outdated version are replaced.
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+	// If specified and more than one replica is requested, a
+	// PodDisruptionBudget limiting voluntary disruptions of the Pods is
+	// created.
+	PodDisruptionBudget *PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+	// Labels and annotations added to the Pods. Labels set by the operator
+	// take precedence.
+	PodMetadata *metav1.ObjectMeta `json:"podMetadata,omitempty"`
+	// SecurityContext holds pod-level security attributes and common
+	// container settings.
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// Containers merged into the containers generated by the operator. A
+	// container with the name of a generated container, such as "alertmanager", is
+	// merged over it: environment variables, ports and volume mounts are
+	// merged by name, container port and mount path, all other fields that
+	// are set replace the generated ones. Other containers are added to the
+	// Pods.
+	Containers []v1.Container `json:"containers,omitempty"`
+	// Init containers added to the Pods.
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// Volumes added to the Pods.
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// Volume mounts added to the Alertmanager container.
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+	// If specified, a Service selecting only the Pods of this Alertmanager is
+	// created.
+	Service *ServiceSpec `json:"service,omitempty"`
+	// If specified, an Ingress routing the external URL to the Service of
+	// this Alertmanager is created. The Service is created with default settings if
+	// not specified.
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// AlertmanagerConfigs to be selected from all watched namespaces. If set,
+	// the operator generates the configuration from the Secret
+	// `alertmanager-<name>`, which is optional then, and the selected
+	// AlertmanagerConfigs. The Pods mount the generated Secret
+	// `alertmanager-<name>-generated` instead.
+	AlertmanagerConfigSelector *metav1.LabelSelector `json:"alertmanagerConfigSelector,omitempty"`
+	// If true, the Pods mount the generated Secret
+	// `alertmanager-<name>-generated`, which the operator only updates with
+	// configurations passing validation. Pods keep running the last valid
+	// configuration while the `ConfigValid` condition reports the error.
+	// Implied by alertmanagerConfigSelector.
+	ValidatedConfigOnly bool `json:"validatedConfigOnly,omitempty"`
+	// Additional peers the Alertmanager instances join, e.g. the instances
+	// of another Kubernetes cluster forming a single high availability
+	// cluster. Peers are given as host:port. The instances of the
+	// Alertmanager itself are always joined.
+	AdditionalPeers []string `json:"additionalPeers,omitempty"`
+	// ConfigMaps is a list of ConfigMaps in the same namespace as the
+	// Alertmanager object, which shall be mounted into the Alertmanager Pods,
+	// e.g. to provide notification templates.
+	// The ConfigMaps are mounted into /etc/alertmanager/configmaps/<configmap-name>.
+	// Changes to their content reload the Alertmanager.
+	ConfigMaps []string `json:"configMaps,omitempty"`
+	// Secrets is a list of Secrets in the same namespace as the Alertmanager
+	// object, which shall be mounted into the Alertmanager Pods.
+	// The Secrets are mounted into /etc/alertmanager/secrets/<secret-name>.
+	// Changes to their content reload the Alertmanager.
+	Secrets []string `json:"secrets,omitempty"`
 }
 
 // A list of Alertmanagers.
@@ -340,6 +728,220 @@ type AlertmanagerStatus struct {
 	AvailableReplicas int32 `json:"availableReplicas"`
 	// Total number of unavailable pods targeted by this Alertmanager cluster.
 	UnavailableReplicas int32 `json:"unavailableReplicas"`
+	// Whether changes of the spec can't be applied to the StatefulSet in
+	// place. The StatefulSet is only recreated to apply them if the
+	// Alertmanager object has the `monitoring.coreos.com/recreate-statefulset`
+	// annotation set to "true".
+	RecreatePending bool `json:"recreatePending,omitempty"`
+	// Conditions recorded by the operator.
+	Conditions []Condition `json:"conditions,omitempty"`
+	// PVCs whose volume is being expanded to the requested storage size.
+	VolumeExpansions []VolumeExpansion `json:"volumeExpansions,omitempty"`
+}
+
+// AlertmanagerConfig defines routes, receivers and inhibit rules for the
+// alerts of its namespace, which are merged into the configuration of the
+// Alertmanagers selecting it.
+type AlertmanagerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object’s metadata. More info:
+	// http://releases.k8s.io/HEAD/docs/devel/api-conventions.md#metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the routes, receivers and inhibit rules.
+	Spec AlertmanagerConfigSpec `json:"spec"`
+}
+
+// AlertmanagerConfigSpec is a specification of the Alertmanager configuration
+// of a namespace. It only applies to alerts with the `namespace` label set to
+// the namespace of the AlertmanagerConfig.
+type AlertmanagerConfigSpec struct {
+	// Routes of the alerts of the namespace. The routes of all
+	// AlertmanagerConfigs of a namespace are nested under a route matching
+	// the namespace. Alerts matching none of them are sent to the receiver of
+	// the root route.
+	Routes []AlertmanagerRoute `json:"routes,omitempty"`
+	// Receivers referenced by the routes.
+	Receivers []AlertmanagerReceiver `json:"receivers,omitempty"`
+	// Inhibit rules applied to the alerts of the namespace.
+	InhibitRules []AlertmanagerInhibitRule `json:"inhibitRules,omitempty"`
+}
+
+// AlertmanagerRoute defines a node of the routing tree.
+type AlertmanagerRoute struct {
+	// Name of a receiver of the same AlertmanagerConfig. Inherited from the
+	// parent route if unset.
+	Receiver string `json:"receiver,omitempty"`
+	// Labels by which alerts are grouped.
+	GroupBy []string `json:"groupBy,omitempty"`
+	// Labels an alert must have to match the route.
+	Match map[string]string `json:"match,omitempty"`
+	// Regular expressions the labels of an alert must match to match the
+	// route.
+	MatchRE map[string]string `json:"matchRE,omitempty"`
+	// Whether alerts matching the route are matched against the following
+	// sibling routes as well.
+	Continue bool `json:"continue,omitempty"`
+	// How long to wait before sending the notification for a new group, e.g.
+	// 30s.
+	GroupWait string `json:"groupWait,omitempty"`
+	// How long to wait before notifying about new alerts of a group, e.g. 5m.
+	GroupInterval string `json:"groupInterval,omitempty"`
+	// How long to wait before repeating a notification, e.g. 4h.
+	RepeatInterval string `json:"repeatInterval,omitempty"`
+	// Child routes.
+	Routes []AlertmanagerRoute `json:"routes,omitempty"`
+}
+
+// AlertmanagerReceiver defines the notification integrations of a receiver.
+// Secrets referenced by them are read from the namespace of the
+// AlertmanagerConfig.
+type AlertmanagerReceiver struct {
+	// Name of the receiver, unique within the AlertmanagerConfig.
+	Name string `json:"name"`
+	// Webhooks to notify.
+	WebhookConfigs []WebhookConfig `json:"webhookConfigs,omitempty"`
+	// Email addresses to notify.
+	EmailConfigs []EmailConfig `json:"emailConfigs,omitempty"`
+	// Slack channels to notify.
+	SlackConfigs []SlackConfig `json:"slackConfigs,omitempty"`
+	// PagerDuty services to notify.
+	PagerDutyConfigs []PagerDutyConfig `json:"pagerDutyConfigs,omitempty"`
+}
+
+// WebhookConfig configures notifications through a webhook.
+type WebhookConfig struct {
+	// Whether to notify about resolved alerts.
+	SendResolved *bool `json:"sendResolved,omitempty"`
+	// URL to send the notifications to.
+	URL string `json:"url"`
+}
+
+// EmailConfig configures notifications through email.
+type EmailConfig struct {
+	// Whether to notify about resolved alerts.
+	SendResolved *bool `json:"sendResolved,omitempty"`
+	// Email address to notify.
+	To string `json:"to"`
+	// Sender address. Defaults to the global setting.
+	From string `json:"from,omitempty"`
+	// SMTP host and port. Defaults to the global setting.
+	Smarthost string `json:"smarthost,omitempty"`
+	// Username for SMTP authentication.
+	AuthUsername string `json:"authUsername,omitempty"`
+	// Secret key holding the password for SMTP authentication.
+	AuthPassword *v1.SecretKeySelector `json:"authPassword,omitempty"`
+	// Whether STARTTLS is required. Defaults to the global setting.
+	RequireTLS *bool `json:"requireTLS,omitempty"`
+}
+
+// SlackConfig configures notifications through Slack.
+type SlackConfig struct {
+	// Whether to notify about resolved alerts.
+	SendResolved *bool `json:"sendResolved,omitempty"`
+	// Secret key holding the URL of the Slack incoming webhook.
+	APIURL *v1.SecretKeySelector `json:"apiURL,omitempty"`
+	// Channel or user to send notifications to.
+	Channel string `json:"channel,omitempty"`
+	// Username of the notifications.
+	Username string `json:"username,omitempty"`
+	// Title of the notifications as a template.
+	Title string `json:"title,omitempty"`
+	// Text of the notifications as a template.
+	Text string `json:"text,omitempty"`
+}
+
+// PagerDutyConfig configures notifications through PagerDuty.
+type PagerDutyConfig struct {
+	// Whether to notify about resolved alerts.
+	SendResolved *bool `json:"sendResolved,omitempty"`
+	// Secret key holding the PagerDuty service integration key.
+	ServiceKey *v1.SecretKeySelector `json:"serviceKey"`
+	// URL of the PagerDuty API. Defaults to the global setting.
+	URL string `json:"url,omitempty"`
+}
+
+// AlertmanagerInhibitRule mutes alerts matching the target matchers while an
+// alert matching the source matchers fires.
+type AlertmanagerInhibitRule struct {
+	// Labels of the muting alerts.
+	SourceMatch map[string]string `json:"sourceMatch,omitempty"`
+	// Regular expressions matching the labels of the muting alerts.
+	SourceMatchRE map[string]string `json:"sourceMatchRE,omitempty"`
+	// Labels of the muted alerts.
+	TargetMatch map[string]string `json:"targetMatch,omitempty"`
+	// Regular expressions matching the labels of the muted alerts.
+	TargetMatchRE map[string]string `json:"targetMatchRE,omitempty"`
+	// Labels that must have equal values in the source and target alert.
+	Equal []string `json:"equal,omitempty"`
+}
+
+// A list of AlertmanagerConfigs.
+type AlertmanagerConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of AlertmanagerConfigs
+	Items []*AlertmanagerConfig `json:"items"`
+}
+
+// Silence mutes the alerts matching its matchers in an Alertmanager for a
+// period of time.
+type Silence struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object’s metadata. More info:
+	// https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the desired silence.
+	Spec SilenceSpec `json:"spec"`
+	// Most recent observed status of the silence. Read-only.
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#spec-and-status
+	Status *SilenceStatus `json:"status,omitempty"`
+}
+
+// SilenceSpec is a specification of a silence of an Alertmanager.
+type SilenceSpec struct {
+	// Name of the Alertmanager object in the same namespace the silence is
+	// created in.
+	Alertmanager string `json:"alertmanager"`
+	// Matchers selecting the silenced alerts. Alerts must match all of them.
+	Matchers []SilenceMatcher `json:"matchers"`
+	// Time the silence starts. Defaults to the time the silence is created.
+	StartsAt *metav1.Time `json:"startsAt,omitempty"`
+	// Time the silence ends.
+	EndsAt metav1.Time `json:"endsAt"`
+	// Author of the silence. Defaults to "prometheus-operator".
+	CreatedBy string `json:"createdBy,omitempty"`
+	// Reason for the silence.
+	Comment string `json:"comment"`
+}
+
+// SilenceMatcher matches the value of a label of alerts.
+type SilenceMatcher struct {
+	// Name of the label.
+	Name string `json:"name"`
+	// Value of the label, or a regular expression matching it.
+	Value string `json:"value"`
+	// Whether the value is a regular expression.
+	IsRegex bool `json:"isRegex,omitempty"`
+}
+
+// SilenceStatus is the status of a silence of an Alertmanager.
+type SilenceStatus struct {
+	// Name of the Alertmanager object the silence was created in.
+	Alertmanager string `json:"alertmanager,omitempty"`
+	// ID of the silence in the Alertmanager.
+	SilenceID string `json:"silenceID,omitempty"`
+}
+
+// A list of Silences.
+type SilenceList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of Silences
+	Items []*Silence `json:"items"`
 }
 
 // A selector for selecting namespaces either selecting all namespaces or a
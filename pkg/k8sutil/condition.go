@@ -0,0 +1,194 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// SetCondition returns the conditions with the condition of the same type
+// replaced by c. The transition time is kept if the status is unchanged. The
+// conditions are returned as is, along with false, if they already contain an
+// equal condition.
+func SetCondition(conds []v1alpha1.Condition, c v1alpha1.Condition) ([]v1alpha1.Condition, bool) {
+	c.LastTransitionTime = metav1.Now()
+	res := make([]v1alpha1.Condition, 0, len(conds)+1)
+	found := false
+	for _, cur := range conds {
+		if cur.Type != c.Type {
+			res = append(res, cur)
+			continue
+		}
+		if cur.Status == c.Status && cur.Reason == c.Reason && cur.Message == c.Message {
+			return conds, false
+		}
+		if cur.Status == c.Status {
+			c.LastTransitionTime = cur.LastTransitionTime
+		}
+		found = true
+		res = append(res, c)
+	}
+	if !found {
+		res = append(res, c)
+	}
+	return res, true
+}
+
+// VersionSupportedCondition returns the ConditionVersionSupported condition
+// for the error of looking up the version, which is nil if it is supported.
+func VersionSupportedCondition(err error) v1alpha1.Condition {
+	if err != nil {
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionVersionSupported,
+			Status:  v1.ConditionFalse,
+			Reason:  "UnsupportedVersion",
+			Message: err.Error(),
+		}
+	}
+	return v1alpha1.Condition{
+		Type:   v1alpha1.ConditionVersionSupported,
+		Status: v1.ConditionTrue,
+	}
+}
+
+// ConfigValidCondition returns the ConditionConfigValid condition for the
+// error of validating a configuration, which is nil if it is valid.
+func ConfigValidCondition(err error) v1alpha1.Condition {
+	if err != nil {
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionConfigValid,
+			Status:  v1.ConditionFalse,
+			Reason:  "InvalidConfig",
+			Message: err.Error(),
+		}
+	}
+	return v1alpha1.Condition{
+		Type:    v1alpha1.ConditionConfigValid,
+		Status:  v1.ConditionTrue,
+		Reason:  "ValidConfig",
+		Message: "configuration is valid",
+	}
+}
+
+// ConfigProvidedCondition returns the ConditionConfigProvided condition for
+// the config Secret of the given name, which holds the default configuration
+// of the operator unless provided.
+func ConfigProvidedCondition(secret string, provided bool) v1alpha1.Condition {
+	if !provided {
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionConfigProvided,
+			Status:  v1.ConditionFalse,
+			Reason:  "DefaultConfig",
+			Message: fmt.Sprintf("config secret %q holds the default configuration, which drops all alerts", secret),
+		}
+	}
+	return v1alpha1.Condition{
+		Type:    v1alpha1.ConditionConfigProvided,
+		Status:  v1.ConditionTrue,
+		Reason:  "ConfigProvided",
+		Message: fmt.Sprintf("config secret %q is provided", secret),
+	}
+}
+
+// AlertmanagerConfigsConvertedCondition returns the
+// ConditionAlertmanagerConfigsConverted condition for the errors of the
+// AlertmanagerConfigs that could not be converted, keyed by their namespace
+// and name.
+func AlertmanagerConfigsConvertedCondition(errs map[string]error) v1alpha1.Condition {
+	if len(errs) > 0 {
+		keys := make([]string, 0, len(errs))
+		for k := range errs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		msgs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", k, errs[k]))
+		}
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionAlertmanagerConfigsConverted,
+			Status:  v1.ConditionFalse,
+			Reason:  "ConversionFailed",
+			Message: "skipped AlertmanagerConfigs " + strings.Join(msgs, "; "),
+		}
+	}
+	return v1alpha1.Condition{
+		Type:   v1alpha1.ConditionAlertmanagerConfigsConverted,
+		Status: v1.ConditionTrue,
+	}
+}
+
+// VolumesExpandedCondition returns the ConditionVolumesExpanded condition for
+// the claims not yet expanded to their requested size and the error of
+// expanding the claims, which is nil if all could be expanded.
+func VolumesExpandedCondition(pending []v1alpha1.VolumeExpansion, err error) v1alpha1.Condition {
+	if err != nil {
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionVolumesExpanded,
+			Status:  v1.ConditionFalse,
+			Reason:  "ExpansionFailed",
+			Message: err.Error(),
+		}
+	}
+	if len(pending) > 0 {
+		claims := make([]string, 0, len(pending))
+		for _, e := range pending {
+			claims = append(claims, fmt.Sprintf("%s (%s of %s)", e.Claim, e.Capacity, e.Requested))
+		}
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionVolumesExpanded,
+			Status:  v1.ConditionFalse,
+			Reason:  "ExpansionPending",
+			Message: "persistent volume claims not yet expanded: " + strings.Join(claims, ", "),
+		}
+	}
+	return v1alpha1.Condition{
+		Type:   v1alpha1.ConditionVolumesExpanded,
+		Status: v1.ConditionTrue,
+	}
+}
+
+// SnapshotsPrunedCondition returns the ConditionSnapshotsPruned condition for
+// the error of the last prune Job, which is nil if it succeeded.
+func SnapshotsPrunedCondition(err error) v1alpha1.Condition {
+	if err != nil {
+		return v1alpha1.Condition{
+			Type:    v1alpha1.ConditionSnapshotsPruned,
+			Status:  v1.ConditionFalse,
+			Reason:  "PruneFailed",
+			Message: err.Error(),
+		}
+	}
+	return v1alpha1.Condition{
+		Type:   v1alpha1.ConditionSnapshotsPruned,
+		Status: v1.ConditionTrue,
+	}
+}
+
+// HasCondition checks whether the conditions contain one of the type.
+func HasCondition(conds []v1alpha1.Condition, t v1alpha1.ConditionType) bool {
+	for _, c := range conds {
+		if c.Type == t {
+			return true
+		}
+	}
+	return false
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,7 +26,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	policyv1beta1 "k8s.io/client-go/kubernetes/typed/policy/v1beta1"
 	"k8s.io/client-go/pkg/api/v1"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
 	"k8s.io/client-go/rest"
 )
 
@@ -114,6 +117,9 @@ func IsResourceNotFoundError(err error) bool {
 	return false
 }
 
+// CreateOrUpdateService creates the Service or updates an existing one. If
+// the Service is marked as created by the operator, an existing Service
+// lacking the mark was created by a user and is not updated.
 func CreateOrUpdateService(sclient clientv1.ServiceInterface, svc *v1.Service) error {
 	service, err := sclient.Get(svc.Name, metav1.GetOptions{})
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -126,7 +132,22 @@ func CreateOrUpdateService(sclient clientv1.ServiceInterface, svc *v1.Service) e
 			return errors.Wrap(err, "creating service object failed")
 		}
 	} else {
+		if IsManaged(svc) && !IsManaged(service) {
+			return fmt.Errorf("service %s exists and is not managed by the operator", svc.Name)
+		}
 		svc.ResourceVersion = service.ResourceVersion
+		// The cluster IP and node ports are allocated by the API server and
+		// must be kept on updates.
+		if svc.Spec.ClusterIP == "" {
+			svc.Spec.ClusterIP = service.Spec.ClusterIP
+		}
+		for i, port := range svc.Spec.Ports {
+			for _, cur := range service.Spec.Ports {
+				if port.NodePort == 0 && port.Port == cur.Port {
+					svc.Spec.Ports[i].NodePort = cur.NodePort
+				}
+			}
+		}
 		_, err := sclient.Update(svc)
 		if err != nil && !apierrors.IsNotFound(err) {
 			return errors.Wrap(err, "updating service object failed")
@@ -157,3 +178,69 @@ func CreateOrUpdateEndpoints(eclient clientv1.EndpointsInterface, eps *v1.Endpoi
 
 	return nil
 }
+
+// SyncPodDisruptionBudget makes pdb the only PodDisruptionBudget created by
+// the operator among those matching the list options. If pdb is nil, all of
+// them are deleted.
+//
+// As the spec of PodDisruptionBudgets is immutable, an outdated one is
+// replaced by creating the new PodDisruptionBudget under a generated name
+// before deleting it, so that the pods are never left without a budget.
+func SyncPodDisruptionBudget(pclient policyv1beta1.PodDisruptionBudgetInterface, opts metav1.ListOptions, pdb *policy.PodDisruptionBudget) error {
+	return syncPodDisruptionBudget(pclient, opts, pdb, func(metav1.Object) bool { return true })
+}
+
+func syncPodDisruptionBudget(pclient policyv1beta1.PodDisruptionBudgetInterface, opts metav1.ListOptions, pdb *policy.PodDisruptionBudget, owned func(metav1.Object) bool) error {
+	list, err := pclient.List(opts)
+	if err != nil {
+		// Without a requested budget there is nothing to clean up unless
+		// the operator was allowed to create one before.
+		if pdb == nil && apierrors.IsForbidden(err) {
+			return nil
+		}
+		return errors.Wrap(err, "listing pod disruption budgets failed")
+	}
+
+	var outdated []string
+	found, nameTaken := false, false
+	for _, cur := range list.Items {
+		if !IsManaged(&cur) || !owned(&cur) {
+			if pdb != nil && cur.Name == pdb.Name {
+				return fmt.Errorf("pod disruption budget %s exists and is not managed by the operator", cur.Name)
+			}
+			continue
+		}
+		if pdb != nil && cur.Name == pdb.Name {
+			nameTaken = true
+		}
+		if pdb != nil && !found && reflect.DeepEqual(cur.Spec, pdb.Spec) && reflect.DeepEqual(cur.Labels, pdb.Labels) {
+			found = true
+			continue
+		}
+		outdated = append(outdated, cur.Name)
+	}
+
+	if pdb != nil && !found {
+		if nameTaken {
+			pdb.GenerateName = pdb.Name + "-"
+			pdb.Name = ""
+		}
+		if _, err := pclient.Create(pdb); err != nil {
+			return errors.Wrap(err, "creating pod disruption budget failed")
+		}
+	}
+
+	for _, name := range outdated {
+		if err := pclient.Delete(name, nil); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, "deleting outdated pod disruption budget failed")
+		}
+	}
+	return nil
+}
+
+// DeletePodDisruptionBudgets deletes the PodDisruptionBudgets created by the
+// operator among those matching the list options. PodDisruptionBudgets for
+// which owned returns false are kept.
+func DeletePodDisruptionBudgets(pclient policyv1beta1.PodDisruptionBudgetInterface, opts metav1.ListOptions, owned func(metav1.Object) bool) error {
+	return syncPodDisruptionBudget(pclient, opts, nil, owned)
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
+)
+
+// MakePodDisruptionBudget returns the PodDisruptionBudget for the pods
+// matching the labels. It returns nil if no PodDisruptionBudget is requested
+// or there are not multiple replicas.
+//
+// The vendored policy/v1beta1 API lacks maxUnavailable, so it is converted
+// into the equivalent minAvailable for the number of replicas.
+func MakePodDisruptionBudget(name string, labels map[string]string, replicas int32, spec *v1alpha1.PodDisruptionBudgetSpec) (*policy.PodDisruptionBudget, error) {
+	if spec == nil || replicas <= 1 {
+		return nil, nil
+	}
+	if spec.MinAvailable != nil && spec.MaxUnavailable != nil {
+		return nil, fmt.Errorf("only one of minAvailable and maxUnavailable may be set")
+	}
+
+	var minAvailable intstr.IntOrString
+	if spec.MinAvailable != nil {
+		minAvailable = *spec.MinAvailable
+	} else {
+		maxUnavailable := intstr.FromInt(1)
+		if spec.MaxUnavailable != nil {
+			maxUnavailable = *spec.MaxUnavailable
+		}
+		n, err := intstr.GetValueFromIntOrPercent(&maxUnavailable, int(replicas), true)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid maxUnavailable")
+		}
+		if n > int(replicas) {
+			n = int(replicas)
+		}
+		minAvailable = intstr.FromInt(int(replicas) - n)
+	}
+
+	return &policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: managedLabels(labels),
+		},
+		Spec: policy.PodDisruptionBudgetSpec{
+			MinAvailable: minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"testing"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestMakePodDisruptionBudget(t *testing.T) {
+	labels := map[string]string{"app": "prometheus", "prometheus": "k8s"}
+	percent := intstr.FromString("50%")
+	one := intstr.FromInt(1)
+
+	for _, c := range []struct {
+		replicas int32
+		spec     *v1alpha1.PodDisruptionBudgetSpec
+		expected *intstr.IntOrString
+	}{
+		{replicas: 3, spec: nil, expected: nil},
+		{replicas: 1, spec: &v1alpha1.PodDisruptionBudgetSpec{}, expected: nil},
+		{replicas: 3, spec: &v1alpha1.PodDisruptionBudgetSpec{}, expected: &intstr.IntOrString{IntVal: 2}},
+		{replicas: 3, spec: &v1alpha1.PodDisruptionBudgetSpec{MaxUnavailable: &percent}, expected: &intstr.IntOrString{IntVal: 1}},
+		{replicas: 3, spec: &v1alpha1.PodDisruptionBudgetSpec{MinAvailable: &percent}, expected: &percent},
+		{replicas: 2, spec: &v1alpha1.PodDisruptionBudgetSpec{MinAvailable: &one}, expected: &one},
+	} {
+		pdb, err := MakePodDisruptionBudget("prometheus-k8s", labels, c.replicas, c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.expected == nil {
+			if pdb != nil {
+				t.Fatalf("expected no pod disruption budget for %d replicas and spec %v", c.replicas, c.spec)
+			}
+			continue
+		}
+		if pdb.Spec.MinAvailable != *c.expected {
+			t.Fatalf("expected minAvailable %s, got %s", c.expected.String(), pdb.Spec.MinAvailable.String())
+		}
+	}
+
+	if _, err := MakePodDisruptionBudget("prometheus-k8s", labels, 3, &v1alpha1.PodDisruptionBudgetSpec{
+		MinAvailable:   &one,
+		MaxUnavailable: &one,
+	}); err == nil {
+		t.Fatal("expected error if both minAvailable and maxUnavailable are set")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// PodTemplateHashAnnotation holds the hash of the generated pod template on a
+// StatefulSet and on the pods created from it.
+const PodTemplateHashAnnotation = "prometheus-operator-pod-template-hash"
+
+// SetPodTemplateHash annotates the StatefulSet and its pod template with the
+// hash of the pod template.
+func SetPodTemplateHash(sset *v1beta1.StatefulSet) error {
+	h, err := PodTemplateHash(sset.Spec.Template)
+	if err != nil {
+		return err
+	}
+
+	sset.Annotations = withAnnotation(sset.Annotations, PodTemplateHashAnnotation, h)
+	sset.Spec.Template.Annotations = withAnnotation(sset.Spec.Template.Annotations, PodTemplateHashAnnotation, h)
+	return nil
+}
+
+// PodTemplateHash returns a hash of the pod template, ignoring a previously
+// set hash annotation.
+func PodTemplateHash(tmpl v1.PodTemplateSpec) (string, error) {
+	annotations := map[string]string{}
+	for k, v := range tmpl.Annotations {
+		if k != PodTemplateHashAnnotation {
+			annotations[k] = v
+		}
+	}
+	tmpl.Annotations = annotations
+
+	b, err := json.Marshal(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling pod template failed")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+// PodNeedsUpdate checks whether the pod was created from a pod template other
+// than tmpl.
+func PodNeedsUpdate(pod *v1.Pod, tmpl v1.PodTemplateSpec) bool {
+	return pod.Annotations[PodTemplateHashAnnotation] != tmpl.Annotations[PodTemplateHashAnnotation]
+}
+
+// withAnnotation returns a copy of annotations with the key set to value.
+func withAnnotation(annotations map[string]string, key, value string) map[string]string {
+	res := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		res[k] = v
+	}
+	res[key] = value
+	return res
+}
+
+// containerListMergeKeys are the keys by which the lists of a container are
+// merged, as defined by the strategic merge patch of the Kubernetes API.
+var containerListMergeKeys = map[string]string{
+	"env":          "name",
+	"ports":        "containerPort",
+	"volumeMounts": "mountPath",
+}
+
+// CustomizePodTemplate merges the user provided parts of a pod template into
+// the generated template. Labels of the generated template take precedence
+// over the ones of the metadata, containers are merged with MergeContainers.
+func CustomizePodTemplate(tmpl *v1.PodTemplateSpec, meta *metav1.ObjectMeta, securityContext *v1.PodSecurityContext, initContainers, containers []v1.Container, volumes []v1.Volume) error {
+	if meta != nil {
+		labels := map[string]string{}
+		for k, v := range meta.Labels {
+			labels[k] = v
+		}
+		for k, v := range tmpl.Labels {
+			labels[k] = v
+		}
+		tmpl.Labels = labels
+
+		annotations := map[string]string{}
+		for k, v := range meta.Annotations {
+			annotations[k] = v
+		}
+		for k, v := range tmpl.Annotations {
+			annotations[k] = v
+		}
+		if len(annotations) > 0 {
+			tmpl.Annotations = annotations
+		}
+	}
+
+	if securityContext != nil {
+		tmpl.Spec.SecurityContext = securityContext
+	}
+	tmpl.Spec.InitContainers = append(tmpl.Spec.InitContainers, initContainers...)
+	tmpl.Spec.Volumes = append(tmpl.Spec.Volumes, volumes...)
+
+	merged, err := MergeContainers(tmpl.Spec.Containers, containers)
+	if err != nil {
+		return err
+	}
+	tmpl.Spec.Containers = merged
+	return nil
+}
+
+// MergeContainers merges the patches into the base containers. A patch with
+// the name of a base container is merged over it, following the strategic
+// merge semantics of the Kubernetes API: environment variables, ports and
+// volume mounts are merged by their keys, all other fields set in the patch
+// replace the ones of the base container. Other patches are appended.
+func MergeContainers(base, patches []v1.Container) ([]v1.Container, error) {
+	res := make([]v1.Container, len(base))
+	copy(res, base)
+
+	for _, patch := range patches {
+		i := 0
+		for ; i < len(res); i++ {
+			if res[i].Name == patch.Name {
+				break
+			}
+		}
+		if i == len(res) {
+			res = append(res, patch)
+			continue
+		}
+
+		merged, err := mergeContainer(res[i], patch)
+		if err != nil {
+			return nil, errors.Wrapf(err, "merging container %q failed", patch.Name)
+		}
+		res[i] = merged
+	}
+	return res, nil
+}
+
+func mergeContainer(base, patch v1.Container) (v1.Container, error) {
+	var b, p map[string]interface{}
+	if err := roundTrip(base, &b); err != nil {
+		return v1.Container{}, err
+	}
+	if err := roundTrip(patch, &p); err != nil {
+		return v1.Container{}, err
+	}
+
+	for k, v := range p {
+		key, ok := containerListMergeKeys[k]
+		bl, bok := b[k].([]interface{})
+		pl, pok := v.([]interface{})
+		if ok && bok && pok {
+			b[k] = mergeList(bl, pl, key)
+			continue
+		}
+		b[k] = mergeValue(b[k], v)
+	}
+
+	var res v1.Container
+	return res, roundTrip(b, &res)
+}
+
+// mergeList merges the items of the patch into the base list by the value of
+// key, appending items not present in the base.
+func mergeList(base, patch []interface{}, key string) []interface{} {
+	res := make([]interface{}, len(base))
+	copy(res, base)
+
+	for _, pi := range patch {
+		pm, ok := pi.(map[string]interface{})
+		if !ok {
+			res = append(res, pi)
+			continue
+		}
+		found := false
+		for i, bi := range res {
+			if bm, ok := bi.(map[string]interface{}); ok && reflect.DeepEqual(bm[key], pm[key]) {
+				res[i] = mergeValue(bm, pm)
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, pm)
+		}
+	}
+	return res
+}
+
+// mergeValue merges maps recursively. All other patch values replace the
+// base value.
+func mergeValue(base, patch interface{}) interface{} {
+	bm, bok := base.(map[string]interface{})
+	pm, pok := patch.(map[string]interface{})
+	if !bok || !pok {
+		return patch
+	}
+	res := map[string]interface{}{}
+	for k, v := range bm {
+		res[k] = v
+	}
+	for k, v := range pm {
+		res[k] = mergeValue(res[k], v)
+	}
+	return res
+}
+
+func roundTrip(in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestMergeContainers(t *testing.T) {
+	base := []v1.Container{
+		{
+			Name:  "prometheus",
+			Image: "quay.io/prometheus/prometheus:v1.7.1",
+			Args:  []string{"-config.file=/etc/prometheus/config/prometheus.yaml"},
+			Env:   []v1.EnvVar{{Name: "A", Value: "a"}},
+			Ports: []v1.ContainerPort{{Name: "web", ContainerPort: 9090}},
+			VolumeMounts: []v1.VolumeMount{
+				{Name: "config", MountPath: "/etc/prometheus/config"},
+			},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")},
+			},
+		},
+	}
+	patches := []v1.Container{
+		{
+			Name: "prometheus",
+			Env:  []v1.EnvVar{{Name: "A", Value: "b"}, {Name: "B", Value: "b"}},
+			VolumeMounts: []v1.VolumeMount{
+				{Name: "config", MountPath: "/etc/prometheus/config", ReadOnly: true},
+				{Name: "certs", MountPath: "/etc/prometheus/certs"},
+			},
+		},
+		{
+			Name:  "oauth-proxy",
+			Image: "openshift/oauth-proxy:v1.0.0",
+		},
+	}
+
+	res, err := MergeContainers(base, patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[1].Name != "oauth-proxy" {
+		t.Fatalf("expected sidecar to be appended, got %v", res)
+	}
+
+	c := res[0]
+	if c.Image != base[0].Image || !reflect.DeepEqual(c.Args, base[0].Args) || !reflect.DeepEqual(c.Ports, base[0].Ports) {
+		t.Fatalf("unset fields of the patch must not change the container, got %v", c)
+	}
+	if c.Resources.Requests.Memory().Cmp(resource.MustParse("2Gi")) != 0 {
+		t.Fatalf("unexpected resources %v", c.Resources)
+	}
+	expectedEnv := []v1.EnvVar{{Name: "A", Value: "b"}, {Name: "B", Value: "b"}}
+	if !reflect.DeepEqual(c.Env, expectedEnv) {
+		t.Fatalf("expected env %v, got %v", expectedEnv, c.Env)
+	}
+	expectedMounts := []v1.VolumeMount{
+		{Name: "config", MountPath: "/etc/prometheus/config", ReadOnly: true},
+		{Name: "certs", MountPath: "/etc/prometheus/certs"},
+	}
+	if !reflect.DeepEqual(c.VolumeMounts, expectedMounts) {
+		t.Fatalf("expected volume mounts %v, got %v", expectedMounts, c.VolumeMounts)
+	}
+}
+
+func TestCustomizePodTemplateLabels(t *testing.T) {
+	tmpl := &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "prometheus"},
+		},
+	}
+	meta := &metav1.ObjectMeta{
+		Labels:      map[string]string{"app": "other", "team": "frontend"},
+		Annotations: map[string]string{"example.com/scrape": "false"},
+	}
+
+	if err := CustomizePodTemplate(tmpl, meta, nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"app": "prometheus", "team": "frontend"}
+	if !reflect.DeepEqual(tmpl.Labels, expected) {
+		t.Fatalf("expected labels %v, got %v", expected, tmpl.Labels)
+	}
+	if tmpl.Annotations["example.com/scrape"] != "false" {
+		t.Fatalf("expected annotation to be added, got %v", tmpl.Annotations)
+	}
+}
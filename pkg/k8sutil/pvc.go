@@ -0,0 +1,256 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	// PVCRetentionPolicyAnnotation holds the PVC retention policy of the
+	// Prometheus or Alertmanager object on its StatefulSet, as the object is
+	// gone once the StatefulSet is destroyed.
+	PVCRetentionPolicyAnnotation = "prometheus-operator-pvc-retention-policy"
+
+	betaStorageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+)
+
+// SetPVCRetentionPolicy records the PVC retention policy on the StatefulSet.
+func SetPVCRetentionPolicy(sset *v1beta1.StatefulSet, policy v1alpha1.PVCRetentionPolicy) {
+	if policy == v1alpha1.PVCRetentionPolicyDelete {
+		sset.Annotations = withAnnotation(sset.Annotations, PVCRetentionPolicyAnnotation, string(policy))
+		return
+	}
+	sset.Annotations = withoutAnnotation(sset.Annotations, PVCRetentionPolicyAnnotation)
+}
+
+// DeleteClaimsOnDestroy checks whether the persistent volume claims of the
+// StatefulSet are deleted along with it.
+func DeleteClaimsOnDestroy(sset *v1beta1.StatefulSet) bool {
+	return sset.Annotations[PVCRetentionPolicyAnnotation] == string(v1alpha1.PVCRetentionPolicyDelete)
+}
+
+// withoutAnnotation returns a copy of the annotations without the key.
+func withoutAnnotation(annotations map[string]string, key string) map[string]string {
+	if _, ok := annotations[key]; !ok {
+		return annotations
+	}
+	res := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		if k != key {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// isStatefulSetClaim checks whether the claim was created by the StatefulSet
+// controller for a pod of the StatefulSet from the volume claim template,
+// which names it <template>-<statefulset>-<ordinal>.
+func isStatefulSetClaim(claim, template, sset string) bool {
+	prefix := template + "-" + sset + "-"
+	if !strings.HasPrefix(claim, prefix) {
+		return false
+	}
+	_, err := strconv.Atoi(claim[len(prefix):])
+	return err == nil
+}
+
+// StatefulSetClaims returns the persistent volume claims created for the pods
+// of the StatefulSet from the volume claim template. The StatefulSet
+// controller labels them with the labels of the StatefulSet's selector.
+func StatefulSetClaims(pclient corev1.PersistentVolumeClaimInterface, sset *v1beta1.StatefulSet, template string) ([]v1.PersistentVolumeClaim, error) {
+	opts := metav1.ListOptions{}
+	if sset.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sset.Spec.Selector)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing statefulset selector failed")
+		}
+		opts.LabelSelector = selector.String()
+	}
+	list, err := pclient.List(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing persistent volume claims failed")
+	}
+	var res []v1.PersistentVolumeClaim
+	for _, c := range list.Items {
+		if isStatefulSetClaim(c.Name, template, sset.Name) {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+// DeleteClaims deletes the persistent volume claims of the pods of the
+// StatefulSet.
+func DeleteClaims(pclient corev1.PersistentVolumeClaimInterface, sset *v1beta1.StatefulSet) error {
+	for _, t := range sset.Spec.VolumeClaimTemplates {
+		claims, err := StatefulSetClaims(pclient, sset, t.Name)
+		if err != nil {
+			return err
+		}
+		for _, c := range claims {
+			if err := pclient.Delete(c.Name, nil); err != nil && !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "deleting persistent volume claim %s failed", c.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// KeepExpandedClaimTemplates returns the old volume claim templates of a
+// StatefulSet if the desired ones only differ in increased storage requests.
+// The volume claim templates of a StatefulSet are immutable, such a change is
+// applied to the existing claims by ExpandClaims instead.
+func KeepExpandedClaimTemplates(old, desired []v1.PersistentVolumeClaim) []v1.PersistentVolumeClaim {
+	if len(old) != len(desired) {
+		return desired
+	}
+	for i := range desired {
+		o, d := old[i], desired[i]
+		oldSize, desiredSize := o.Spec.Resources.Requests[v1.ResourceStorage], d.Spec.Resources.Requests[v1.ResourceStorage]
+		if desiredSize.Cmp(oldSize) < 0 {
+			return desired
+		}
+		d.Spec.Resources.Requests = withStorageRequest(d.Spec.Resources.Requests, oldSize)
+		if o.Name != d.Name || !reflect.DeepEqual(o.Spec, d.Spec) {
+			return desired
+		}
+	}
+	return old
+}
+
+func withStorageRequest(requests v1.ResourceList, size resource.Quantity) v1.ResourceList {
+	res := make(v1.ResourceList, len(requests))
+	for k, v := range requests {
+		res[k] = v
+	}
+	res[v1.ResourceStorage] = size
+	return res
+}
+
+// ExpandClaims raises the storage requests of the persistent volume claims of
+// the StatefulSet to the ones of the desired volume claim templates. Claims
+// are only expanded if their StorageClass allows volume expansion.
+func ExpandClaims(kclient kubernetes.Interface, sset *v1beta1.StatefulSet, templates []v1.PersistentVolumeClaim) error {
+	pclient := kclient.CoreV1().PersistentVolumeClaims(sset.Namespace)
+	expandable := map[string]bool{}
+
+	for _, t := range templates {
+		want, ok := t.Spec.Resources.Requests[v1.ResourceStorage]
+		if !ok {
+			continue
+		}
+		claims, err := StatefulSetClaims(pclient, sset, t.Name)
+		if err != nil {
+			return err
+		}
+		for _, c := range claims {
+			cur := c.Spec.Resources.Requests[v1.ResourceStorage]
+			if want.Cmp(cur) <= 0 {
+				continue
+			}
+
+			class := claimStorageClass(c)
+			allowed, ok := expandable[class]
+			if !ok {
+				if allowed, err = allowsVolumeExpansion(kclient, class); err != nil {
+					return err
+				}
+				expandable[class] = allowed
+			}
+			if !allowed {
+				return fmt.Errorf("storage class %q of persistent volume claim %s does not allow volume expansion", class, c.Name)
+			}
+
+			patch := fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q}}}}`, want.String())
+			if _, err := pclient.Patch(c.Name, types.MergePatchType, []byte(patch)); err != nil {
+				return errors.Wrapf(err, "expanding persistent volume claim %s failed", c.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// claimStorageClass returns the name of the StorageClass of the claim.
+func claimStorageClass(c v1.PersistentVolumeClaim) string {
+	if c.Spec.StorageClassName != nil {
+		return *c.Spec.StorageClassName
+	}
+	return c.Annotations[betaStorageClassAnnotation]
+}
+
+// allowsVolumeExpansion checks whether the StorageClass allows expanding
+// volumes. The vendored storage types lack the field, so the StorageClass is
+// decoded from its raw representation.
+func allowsVolumeExpansion(kclient kubernetes.Interface, class string) (bool, error) {
+	if class == "" {
+		return false, nil
+	}
+	b, err := kclient.StorageV1().RESTClient().Get().
+		AbsPath("/apis/storage.k8s.io/v1/storageclasses", class).
+		Do().
+		Raw()
+	if err != nil {
+		return false, errors.Wrapf(err, "retrieving storage class %q failed", class)
+	}
+	var sc struct {
+		AllowVolumeExpansion bool `json:"allowVolumeExpansion"`
+	}
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return false, errors.Wrapf(err, "decoding storage class %q failed", class)
+	}
+	return sc.AllowVolumeExpansion, nil
+}
+
+// ClaimExpansions returns the persistent volume claims of the StatefulSet whose
+// volume is not yet expanded to the requested size.
+func ClaimExpansions(pclient corev1.PersistentVolumeClaimInterface, sset *v1beta1.StatefulSet) ([]v1alpha1.VolumeExpansion, error) {
+	var res []v1alpha1.VolumeExpansion
+	for _, t := range sset.Spec.VolumeClaimTemplates {
+		claims, err := StatefulSetClaims(pclient, sset, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range claims {
+			requested := c.Spec.Resources.Requests[v1.ResourceStorage]
+			capacity, ok := c.Status.Capacity[v1.ResourceStorage]
+			if !ok || capacity.Cmp(requested) >= 0 {
+				continue
+			}
+			res = append(res, v1alpha1.VolumeExpansion{
+				Claim:     c.Name,
+				Requested: requested.String(),
+				Capacity:  capacity.String(),
+			})
+		}
+	}
+	return res, nil
+}
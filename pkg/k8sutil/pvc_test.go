@@ -0,0 +1,93 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+func makeClaimTemplate(name, size string) v1.PersistentVolumeClaim {
+	return v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func TestIsStatefulSetClaim(t *testing.T) {
+	for _, c := range []struct {
+		claim    string
+		expected bool
+	}{
+		{claim: "prometheus-k8s-db-prometheus-k8s-0", expected: true},
+		{claim: "prometheus-k8s-db-prometheus-k8s-12", expected: true},
+		{claim: "prometheus-k8s-db-prometheus-k8s-other-0", expected: false},
+		{claim: "prometheus-k8s-db-prometheus-k8s-", expected: false},
+		{claim: "data", expected: false},
+	} {
+		if res := isStatefulSetClaim(c.claim, "prometheus-k8s-db", "prometheus-k8s"); res != c.expected {
+			t.Fatalf("claim %s: expected %t, got %t", c.claim, c.expected, res)
+		}
+	}
+}
+
+func TestKeepExpandedClaimTemplates(t *testing.T) {
+	old := []v1.PersistentVolumeClaim{makeClaimTemplate("prometheus-k8s-db", "10Gi")}
+
+	expanded := []v1.PersistentVolumeClaim{makeClaimTemplate("prometheus-k8s-db", "20Gi")}
+	if res := KeepExpandedClaimTemplates(old, expanded); !reflect.DeepEqual(res, old) {
+		t.Fatal("expected the old templates to be kept when only the storage request is increased")
+	}
+
+	shrunk := []v1.PersistentVolumeClaim{makeClaimTemplate("prometheus-k8s-db", "5Gi")}
+	if res := KeepExpandedClaimTemplates(old, shrunk); !reflect.DeepEqual(res, shrunk) {
+		t.Fatal("expected the desired templates when the storage request is decreased")
+	}
+
+	changed := []v1.PersistentVolumeClaim{makeClaimTemplate("prometheus-k8s-db", "20Gi")}
+	class := "fast"
+	changed[0].Spec.StorageClassName = &class
+	if res := KeepExpandedClaimTemplates(old, changed); !reflect.DeepEqual(res, changed) {
+		t.Fatal("expected the desired templates when other fields change")
+	}
+}
+
+func TestSetPVCRetentionPolicy(t *testing.T) {
+	sset := &v1beta1.StatefulSet{}
+
+	SetPVCRetentionPolicy(sset, v1alpha1.PVCRetentionPolicyDelete)
+	if !DeleteClaimsOnDestroy(sset) {
+		t.Fatal("expected claims to be deleted")
+	}
+
+	SetPVCRetentionPolicy(sset, v1alpha1.PVCRetentionPolicyRetain)
+	if DeleteClaimsOnDestroy(sset) {
+		t.Fatal("expected claims to be retained")
+	}
+	if _, ok := sset.Annotations[PVCRetentionPolicyAnnotation]; ok {
+		t.Fatal("expected the annotation to be removed")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const defaultReadinessGateTimeout = 3 * time.Second
+
+// RollingUpdate selects the outdated Pods of a StatefulSet to delete next, so
+// that the StatefulSet controller recreates them with the current template.
+type RollingUpdate struct {
+	Strategy v1alpha1.UpdateStrategy
+	// Probe checks whether an updated Pod serves requests. It is only called
+	// if a readiness gate is configured.
+	Probe func(pod v1.Pod) error
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewRollingUpdate returns a RollingUpdate for the strategy. If the strategy
+// has a readiness gate, Pods are probed with a GET request for the path of the
+// gate, or defaultPath if it is unset, on the given port.
+func NewRollingUpdate(s *v1alpha1.UpdateStrategy, port int, defaultPath string) *RollingUpdate {
+	u := &RollingUpdate{Now: time.Now}
+	if s == nil {
+		return u
+	}
+	u.Strategy = *s
+
+	if g := s.ReadinessGate; g != nil {
+		path := g.Path
+		if path == "" {
+			path = defaultPath
+		}
+		timeout := defaultReadinessGateTimeout
+		if g.TimeoutSeconds > 0 {
+			timeout = time.Duration(g.TimeoutSeconds) * time.Second
+		}
+		u.Probe = httpProbe(&http.Client{Timeout: timeout}, port, path)
+	}
+	return u
+}
+
+func httpProbe(client *http.Client, port int, path string) func(v1.Pod) error {
+	return func(pod v1.Pod) error {
+		if pod.Status.PodIP == "" {
+			return fmt.Errorf("pod has no IP")
+		}
+		u := "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)) + path
+
+		resp, err := client.Get(u)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("GET %s returned status %d", path, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Next returns the outdated Pods to delete next, given the desired number of
+// replicas, all Pods of the StatefulSet and the outdated ones among them.
+//
+// Replicas that are not ready, not yet ready for the minimum ready duration,
+// failing the readiness gate or missing from pods altogether, e.g. because
+// they were just deleted, count as unavailable.
+//
+// If no Pod may be deleted yet because updated Pods have not been ready for
+// the minimum ready duration, the duration after which to retry is returned.
+// If Pods are unavailable for other reasons, an error is returned.
+func (u *RollingUpdate) Next(replicas int, pods, oldPods []v1.Pod) ([]v1.Pod, time.Duration, error) {
+	if len(oldPods) == 0 {
+		return nil, 0, nil
+	}
+
+	old := map[string]struct{}{}
+	for _, p := range oldPods {
+		old[p.Name] = struct{}{}
+	}
+
+	var (
+		available  int
+		retryAfter time.Duration
+		lastErr    error
+		minReady   = time.Duration(u.Strategy.MinReadySeconds) * time.Second
+	)
+	for _, p := range pods {
+		if ready, _ := PodRunningAndReady(p); !ready {
+			continue
+		}
+		if _, ok := old[p.Name]; ok {
+			available++
+			continue
+		}
+		if d := minReady - u.Now().Sub(readySince(p)); d > 0 {
+			if retryAfter == 0 || d < retryAfter {
+				retryAfter = d
+			}
+			continue
+		}
+		if u.Probe != nil {
+			if err := u.Probe(p); err != nil {
+				lastErr = fmt.Errorf("readiness gate of pod %s failed: %s", p.Name, err)
+				continue
+			}
+		}
+		available++
+	}
+	unavailable := replicas - available
+	if unavailable < 0 {
+		unavailable = 0
+	}
+
+	maxUnavailable := 1
+	if u.Strategy.MaxUnavailable != nil && *u.Strategy.MaxUnavailable > 1 {
+		maxUnavailable = int(*u.Strategy.MaxUnavailable)
+	}
+	n := maxUnavailable - unavailable
+	if n <= 0 {
+		if lastErr != nil {
+			return nil, 0, lastErr
+		}
+		if retryAfter > 0 {
+			return nil, retryAfter, nil
+		}
+		return nil, 0, fmt.Errorf("waiting for %d unavailable pods to become available", unavailable)
+	}
+	if n > len(oldPods) {
+		n = len(oldPods)
+	}
+
+	res := make([]v1.Pod, len(oldPods))
+	copy(res, oldPods)
+	sortPods(res, u.Strategy.Order)
+
+	return res[:n], 0, nil
+}
+
+// readySince returns the time the Pod last became ready.
+func readySince(pod v1.Pod) time.Time {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.LastTransitionTime.Time
+		}
+	}
+	return time.Time{}
+}
+
+func sortPods(pods []v1.Pod, order v1alpha1.UpdateOrder) {
+	switch order {
+	case v1alpha1.UpdateOrderOrdinal:
+		sort.SliceStable(pods, func(i, j int) bool {
+			return podOrdinal(pods[i]) > podOrdinal(pods[j])
+		})
+	default:
+		sort.SliceStable(pods, func(i, j int) bool {
+			return pods[i].CreationTimestamp.Before(pods[j].CreationTimestamp)
+		})
+	}
+}
+
+// podOrdinal returns the ordinal of a Pod created by a StatefulSet, which is
+// the suffix of its name, or -1 if it has none.
+func podOrdinal(pod v1.Pod) int {
+	i := strings.LastIndex(pod.Name, "-")
+	if i < 0 {
+		return -1
+	}
+	o, err := strconv.Atoi(pod.Name[i+1:])
+	if err != nil {
+		return -1
+	}
+	return o
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var now = time.Date(2017, 8, 1, 12, 0, 0, 0, time.UTC)
+
+func makePod(name string, created time.Duration, ready bool, readySince time.Duration) v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-created)),
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			Conditions: []v1.PodCondition{{
+				Type:               v1.PodReady,
+				Status:             status,
+				LastTransitionTime: metav1.NewTime(now.Add(-readySince)),
+			}},
+		},
+	}
+}
+
+func podNames(pods []v1.Pod) []string {
+	names := []string{}
+	for _, p := range pods {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestRollingUpdateOrder(t *testing.T) {
+	oldPods := []v1.Pod{
+		makePod("prometheus-k8s-0", time.Hour, true, time.Hour),
+		makePod("prometheus-k8s-1", 2*time.Hour, true, time.Hour),
+		makePod("prometheus-k8s-2", 30*time.Minute, true, time.Hour),
+	}
+
+	for _, c := range []struct {
+		order    v1alpha1.UpdateOrder
+		expected []string
+	}{
+		{order: "", expected: []string{"prometheus-k8s-1"}},
+		{order: v1alpha1.UpdateOrderPodAge, expected: []string{"prometheus-k8s-1"}},
+		{order: v1alpha1.UpdateOrderOrdinal, expected: []string{"prometheus-k8s-2"}},
+	} {
+		u := &RollingUpdate{
+			Strategy: v1alpha1.UpdateStrategy{Order: c.order},
+			Now:      func() time.Time { return now },
+		}
+		next, _, err := u.Next(3, oldPods, oldPods)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := podNames(next); !reflect.DeepEqual(names, c.expected) {
+			t.Fatalf("order %q: expected %v, got %v", c.order, c.expected, names)
+		}
+	}
+}
+
+func TestRollingUpdateMaxUnavailable(t *testing.T) {
+	maxUnavailable := int32(3)
+	pods := []v1.Pod{
+		makePod("prometheus-k8s-0", time.Hour, true, time.Hour),
+		makePod("prometheus-k8s-1", time.Hour, true, time.Hour),
+		makePod("prometheus-k8s-2", time.Hour, true, time.Hour),
+		makePod("prometheus-k8s-3", time.Minute, false, time.Minute),
+	}
+	u := &RollingUpdate{
+		Strategy: v1alpha1.UpdateStrategy{MaxUnavailable: &maxUnavailable, Order: v1alpha1.UpdateOrderOrdinal},
+		Now:      func() time.Time { return now },
+	}
+
+	next, _, err := u.Next(4, pods, pods[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := podNames(next); !reflect.DeepEqual(names, []string{"prometheus-k8s-2", "prometheus-k8s-1"}) {
+		t.Fatalf("unexpected pods %v", names)
+	}
+
+	u.Strategy.MaxUnavailable = nil
+	if _, _, err := u.Next(4, pods, pods[:3]); err == nil {
+		t.Fatal("expected error while pods are unavailable")
+	}
+}
+
+func TestRollingUpdateMinReadySeconds(t *testing.T) {
+	pods := []v1.Pod{
+		makePod("alertmanager-main-0", time.Hour, true, time.Hour),
+		makePod("alertmanager-main-1", time.Minute, true, 20*time.Second),
+	}
+	u := &RollingUpdate{
+		Strategy: v1alpha1.UpdateStrategy{MinReadySeconds: 60},
+		Now:      func() time.Time { return now },
+	}
+
+	next, retryAfter, err := u.Next(2, pods, pods[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 0 {
+		t.Fatalf("expected no pods to be updated, got %v", podNames(next))
+	}
+	if retryAfter != 40*time.Second {
+		t.Fatalf("expected retry after 40s, got %s", retryAfter)
+	}
+
+	u.Strategy.MinReadySeconds = 10
+	next, _, err = u.Next(2, pods, pods[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := podNames(next); !reflect.DeepEqual(names, []string{"alertmanager-main-0"}) {
+		t.Fatalf("unexpected pods %v", names)
+	}
+}
+
+func TestRollingUpdateReadinessGate(t *testing.T) {
+	pods := []v1.Pod{
+		makePod("alertmanager-main-0", time.Hour, true, time.Hour),
+		makePod("alertmanager-main-1", time.Minute, true, time.Minute),
+	}
+	probed := []string{}
+	u := &RollingUpdate{
+		Probe: func(pod v1.Pod) error {
+			probed = append(probed, pod.Name)
+			return fmt.Errorf("not ready")
+		},
+		Now: func() time.Time { return now },
+	}
+
+	if _, _, err := u.Next(2, pods, pods[:1]); err == nil {
+		t.Fatal("expected error for failing readiness gate")
+	}
+	if !reflect.DeepEqual(probed, []string{"alertmanager-main-1"}) {
+		t.Fatalf("expected only the updated pod to be probed, got %v", probed)
+	}
+}
+
+func TestRollingUpdateMissingPod(t *testing.T) {
+	// prometheus-k8s-1 was just deleted and is not recreated yet.
+	pods := []v1.Pod{
+		makePod("prometheus-k8s-0", time.Hour, true, time.Hour),
+		makePod("prometheus-k8s-2", time.Hour, true, time.Hour),
+	}
+	u := &RollingUpdate{Now: func() time.Time { return now }}
+
+	if next, _, err := u.Next(3, pods, pods); err == nil {
+		t.Fatalf("expected error while a pod is missing, got %v", podNames(next))
+	}
+
+	maxUnavailable := int32(2)
+	u.Strategy.MaxUnavailable = &maxUnavailable
+	next, _, err := u.Next(3, pods, pods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 1 {
+		t.Fatalf("expected one pod to be updated, got %v", podNames(next))
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// CreateOrUpdateSecret creates the Secret or updates an existing one, marking
+// it as created by the operator. Existing Secrets not created by the operator
+// are not updated.
+func CreateOrUpdateSecret(sclient clientv1.SecretInterface, s *v1.Secret) error {
+	s.Labels = managedLabels(s.Labels)
+
+	cur, err := sclient.Get(s.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving secret failed")
+	}
+
+	if apierrors.IsNotFound(err) {
+		if _, err := sclient.Create(s); err != nil {
+			return errors.Wrap(err, "creating secret failed")
+		}
+		return nil
+	}
+
+	if !IsManaged(cur) {
+		return fmt.Errorf("secret %s exists and is not managed by the operator", s.Name)
+	}
+	if reflect.DeepEqual(cur.Data, s.Data) && reflect.DeepEqual(cur.Labels, s.Labels) {
+		return nil
+	}
+	s.ResourceVersion = cur.ResourceVersion
+	if _, err := sclient.Update(s); err != nil {
+		return errors.Wrap(err, "updating secret failed")
+	}
+	return nil
+}
+
+// CreateSecret creates the Secret marked as created by the operator, unless a
+// Secret of the name exists. The existing or created Secret is returned.
+func CreateSecret(sclient clientv1.SecretInterface, s *v1.Secret) (*v1.Secret, error) {
+	s.Labels = managedLabels(s.Labels)
+
+	res, err := sclient.Create(s)
+	if apierrors.IsAlreadyExists(err) {
+		res, err = sclient.Get(s.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving secret failed")
+		}
+		return res, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "creating secret failed")
+	}
+	return res, nil
+}
+
+// DeleteSecret deletes the Secret if it exists and was created by the
+// operator.
+func DeleteSecret(sclient clientv1.SecretInterface, name string) error {
+	s, err := sclient.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving secret failed")
+	}
+	if !IsManaged(s) {
+		return nil
+	}
+	if err := sclient.Delete(name, nil); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "deleting secret failed")
+	}
+	return nil
+}
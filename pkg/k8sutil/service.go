@@ -0,0 +1,211 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	extensionsv1beta1 "k8s.io/client-go/kubernetes/typed/extensions/v1beta1"
+	"k8s.io/client-go/pkg/api/v1"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// managedByLabel marks Services, Ingresses and Secrets created for a single
+// Prometheus or Alertmanager. Only objects carrying it are deleted by the
+// operator, so that user created objects with the same name are retained.
+const managedByLabel = "managed-by"
+
+const managedByLabelValue = "prometheus-operator"
+
+// MakeService returns the Service selecting the pods matching the labels,
+// exposing the given port named web unless the spec defines other ports.
+func MakeService(name string, labels map[string]string, port int32, spec *v1alpha1.ServiceSpec) *v1.Service {
+	if spec == nil {
+		spec = &v1alpha1.ServiceSpec{}
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      managedLabels(labels),
+			Annotations: spec.Annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Type:     spec.Type,
+			Ports:    append([]v1.ServicePort(nil), spec.Ports...),
+			Selector: labels,
+		},
+	}
+	if svc.Spec.Type == "" {
+		svc.Spec.Type = v1.ServiceTypeClusterIP
+	}
+	if len(svc.Spec.Ports) == 0 {
+		svc.Spec.Ports = []v1.ServicePort{
+			{
+				Name:       "web",
+				Port:       port,
+				TargetPort: intstr.FromString("web"),
+			},
+		}
+	}
+	return svc
+}
+
+// MakeIngress returns the Ingress routing the external URL to the port named
+// web of the Service. The path is the one of the external URL, or the route
+// prefix if the external URL has no path.
+func MakeIngress(name string, labels map[string]string, externalURL, routePrefix string, spec *v1alpha1.IngressSpec) (*extensions.Ingress, error) {
+	u, err := url.Parse(externalURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing external URL failed")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("an Ingress requires an external URL with a host")
+	}
+
+	p := u.Path
+	if p == "" {
+		p = routePrefix
+	}
+	if p == "" {
+		p = "/"
+	}
+
+	ing := &extensions.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      managedLabels(labels),
+			Annotations: spec.Annotations,
+		},
+		Spec: extensions.IngressSpec{
+			Rules: []extensions.IngressRule{
+				{
+					Host: u.Hostname(),
+					IngressRuleValue: extensions.IngressRuleValue{
+						HTTP: &extensions.HTTPIngressRuleValue{
+							Paths: []extensions.HTTPIngressPath{
+								{
+									Path: p,
+									Backend: extensions.IngressBackend{
+										ServiceName: name,
+										ServicePort: intstr.FromString("web"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ing.Spec.TLS = []extensions.IngressTLS{
+			{
+				Hosts:      []string{u.Hostname()},
+				SecretName: spec.TLSSecretName,
+			},
+		}
+	}
+	return ing, nil
+}
+
+func managedLabels(labels map[string]string) map[string]string {
+	res := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		res[k] = v
+	}
+	res[managedByLabel] = managedByLabelValue
+	return res
+}
+
+// IsManaged checks whether the object was created by the operator.
+func IsManaged(o metav1.Object) bool {
+	return o.GetLabels()[managedByLabel] == managedByLabelValue
+}
+
+// DeleteService deletes the Service if it exists and was created by the
+// operator. Services for which owned returns false, e.g. those of another
+// operator instance, are kept.
+func DeleteService(sclient clientv1.ServiceInterface, name string, owned func(metav1.Object) bool) error {
+	svc, err := sclient.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving service object failed")
+	}
+	if !IsManaged(svc) || !owned(svc) {
+		return nil
+	}
+	if err := sclient.Delete(name, nil); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "deleting service object failed")
+	}
+	return nil
+}
+
+// CreateOrUpdateIngress creates the Ingress or updates an existing one that
+// was created by the operator.
+func CreateOrUpdateIngress(iclient extensionsv1beta1.IngressInterface, ing *extensions.Ingress) error {
+	cur, err := iclient.Get(ing.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving ingress failed")
+	}
+
+	if apierrors.IsNotFound(err) {
+		if _, err := iclient.Create(ing); err != nil {
+			return errors.Wrap(err, "creating ingress failed")
+		}
+		return nil
+	}
+
+	if !IsManaged(cur) {
+		return fmt.Errorf("ingress %s exists and is not managed by the operator", ing.Name)
+	}
+	if reflect.DeepEqual(cur.Spec, ing.Spec) && reflect.DeepEqual(cur.Labels, ing.Labels) && reflect.DeepEqual(cur.Annotations, ing.Annotations) {
+		return nil
+	}
+	ing.ResourceVersion = cur.ResourceVersion
+	if _, err := iclient.Update(ing); err != nil {
+		return errors.Wrap(err, "updating ingress failed")
+	}
+	return nil
+}
+
+// DeleteIngress deletes the Ingress if it exists and was created by the
+// operator. Ingresses for which owned returns false are kept.
+func DeleteIngress(iclient extensionsv1beta1.IngressInterface, name string, owned func(metav1.Object) bool) error {
+	ing, err := iclient.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving ingress failed")
+	}
+	if !IsManaged(ing) || !owned(ing) {
+		return nil
+	}
+	if err := iclient.Delete(name, nil); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "deleting ingress failed")
+	}
+	return nil
+}
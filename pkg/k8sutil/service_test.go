@@ -0,0 +1,69 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"testing"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestMakeServiceDefaults(t *testing.T) {
+	labels := map[string]string{"app": "prometheus", "prometheus": "k8s"}
+	svc := MakeService("prometheus-k8s", labels, 9090, nil)
+
+	if svc.Spec.Type != v1.ServiceTypeClusterIP {
+		t.Fatalf("expected type ClusterIP, got %s", svc.Spec.Type)
+	}
+	if len(svc.Spec.Ports) != 1 || svc.Spec.Ports[0].Name != "web" || svc.Spec.Ports[0].Port != 9090 {
+		t.Fatalf("unexpected ports %v", svc.Spec.Ports)
+	}
+	if svc.Spec.Selector["prometheus"] != "k8s" || svc.Spec.Selector[managedByLabel] != "" {
+		t.Fatalf("unexpected selector %v", svc.Spec.Selector)
+	}
+	if !IsManaged(svc) {
+		t.Fatal("expected service to be marked as managed")
+	}
+}
+
+func TestMakeIngressPath(t *testing.T) {
+	for _, c := range []struct {
+		externalURL string
+		routePrefix string
+		host        string
+		path        string
+	}{
+		{externalURL: "https://prometheus.example.com", host: "prometheus.example.com", path: "/"},
+		{externalURL: "https://prometheus.example.com:8443", routePrefix: "/prometheus", host: "prometheus.example.com", path: "/prometheus"},
+		{externalURL: "https://example.com/monitoring/prometheus", routePrefix: "/", host: "example.com", path: "/monitoring/prometheus"},
+	} {
+		ing, err := MakeIngress("prometheus-k8s", nil, c.externalURL, c.routePrefix, &v1alpha1.IngressSpec{TLSSecretName: "tls"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := ing.Spec.Rules[0]
+		if rule.Host != c.host || rule.HTTP.Paths[0].Path != c.path {
+			t.Fatalf("%s: expected %s%s, got %s%s", c.externalURL, c.host, c.path, rule.Host, rule.HTTP.Paths[0].Path)
+		}
+		if ing.Spec.TLS[0].Hosts[0] != c.host {
+			t.Fatalf("%s: unexpected TLS hosts %v", c.externalURL, ing.Spec.TLS[0].Hosts)
+		}
+	}
+
+	if _, err := MakeIngress("prometheus-k8s", nil, "", "", &v1alpha1.IngressSpec{}); err == nil {
+		t.Fatal("expected error for missing external URL")
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	appsv1beta1 "k8s.io/client-go/kubernetes/typed/apps/v1beta1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// RecreateStatefulSetAnnotation on a Prometheus or Alertmanager object
+	// set to "true" allows the operator to recreate its StatefulSet, if the
+	// StatefulSet can't be updated in place.
+	RecreateStatefulSetAnnotation = "monitoring.coreos.com/recreate-statefulset"
+	// RecreatePendingAnnotation is set on a StatefulSet that must be recreated
+	// to apply the desired spec, while recreation is not allowed.
+	RecreatePendingAnnotation = "prometheus-operator-recreate-pending"
+	// PartitionAnnotation holds the partition of the RollingUpdate strategy
+	// of a StatefulSet. The vendored apps/v1beta1 types lack the update
+	// strategy, so it is kept in the annotation and added when writing the
+	// StatefulSet.
+	PartitionAnnotation = "prometheus-operator-partition"
+)
+
+// RecreateStatefulSetAllowed checks whether the StatefulSet of the object may
+// be recreated.
+func RecreateStatefulSetAllowed(o metav1.Object) bool {
+	return o.GetAnnotations()[RecreateStatefulSetAnnotation] == "true"
+}
+
+// SetRecreatePending sets or removes the RecreatePendingAnnotation of the
+// StatefulSet.
+func SetRecreatePending(sset *v1beta1.StatefulSet, pending bool) {
+	if pending {
+		sset.Annotations = withAnnotation(sset.Annotations, RecreatePendingAnnotation, "true")
+		return
+	}
+	sset.Annotations = withoutAnnotation(sset.Annotations, RecreatePendingAnnotation)
+}
+
+// IsRecreatePending checks whether the StatefulSet must be recreated to apply
+// the desired spec.
+func IsRecreatePending(sset *v1beta1.StatefulSet) bool {
+	return sset.Annotations[RecreatePendingAnnotation] == "true"
+}
+
+// UpdateStatefulSet updates the StatefulSet old to the desired spec. If the
+// update is rejected as the changed fields are immutable and recreate is set,
+// the StatefulSet is deleted without deleting its pods, so that it can be
+// created again with the desired spec. The pods are then replaced by the
+// rolling update and the persistent volume claims of the StatefulSet are
+// reused. Otherwise the StatefulSet is marked with RecreatePendingAnnotation.
+//
+// It returns true if the StatefulSet is being deleted.
+func UpdateStatefulSet(sclient appsv1beta1.StatefulSetInterface, old, desired *v1beta1.StatefulSet, recreate bool) (bool, error) {
+	if old.DeletionTimestamp != nil {
+		return true, fmt.Errorf("waiting for deletion of statefulset %s to complete", old.Name)
+	}
+
+	_, err := sclient.Update(desired)
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsInvalid(err) {
+		return false, errors.Wrap(err, "updating statefulset failed")
+	}
+
+	if !recreate {
+		if !IsRecreatePending(old) {
+			pending := *old
+			SetRecreatePending(&pending, true)
+			if _, uerr := sclient.Update(&pending); uerr != nil {
+				return false, errors.Wrap(uerr, "marking statefulset for recreation failed")
+			}
+		}
+		return false, errors.Wrapf(err, "statefulset can't be updated in place, set the %s annotation to \"true\" to allow recreating it", RecreateStatefulSetAnnotation)
+	}
+
+	orphan := true
+	if err := sclient.Delete(old.Name, &metav1.DeleteOptions{OrphanDependents: &orphan}); err != nil && !apierrors.IsNotFound(err) {
+		return false, errors.Wrap(err, "deleting statefulset for recreation failed")
+	}
+	return true, nil
+}
+
+// RollingUpdateGroupVersion returns the newest group version of the
+// StatefulSet API that supports the RollingUpdate strategy, or an empty string
+// if the cluster does not support rolling updates of StatefulSets.
+func RollingUpdateGroupVersion(d discovery.DiscoveryInterface) (string, error) {
+	groups, err := d.ServerGroups()
+	if err != nil {
+		return "", errors.Wrap(err, "retrieving API groups failed")
+	}
+	served := map[string]bool{}
+	for _, g := range groups.Groups {
+		for _, v := range g.Versions {
+			served[v.GroupVersion] = true
+		}
+	}
+	for _, gv := range []string{"apps/v1", "apps/v1beta2"} {
+		if served[gv] {
+			return gv, nil
+		}
+	}
+
+	// apps/v1beta1 supports the update strategy starting with Kubernetes 1.7.
+	v, err := d.ServerVersion()
+	if err != nil {
+		return "", errors.Wrap(err, "retrieving server version failed")
+	}
+	major, _ := strconv.Atoi(v.Major)
+	minor, _ := strconv.Atoi(strings.TrimRight(v.Minor, "+"))
+	if served["apps/v1beta1"] && (major > 1 || major == 1 && minor >= 7) {
+		return "apps/v1beta1", nil
+	}
+	return "", nil
+}
+
+// Partition returns the partition of the RollingUpdate strategy of the
+// StatefulSet. Pods with an ordinal lower than the partition are not updated.
+func Partition(sset *v1beta1.StatefulSet) int32 {
+	p, err := strconv.Atoi(sset.Annotations[PartitionAnnotation])
+	if err != nil {
+		return 0
+	}
+	return int32(p)
+}
+
+// SetPartition sets the partition of the RollingUpdate strategy of the
+// StatefulSet.
+func SetPartition(sset *v1beta1.StatefulSet, partition int32) {
+	sset.Annotations = withAnnotation(sset.Annotations, PartitionAnnotation, strconv.Itoa(int(partition)))
+}
+
+// StatefulSets returns a client for the StatefulSets of the namespace. If
+// groupVersion is set, StatefulSets are created and updated through it with
+// the RollingUpdate strategy and the partition of PartitionAnnotation.
+// Otherwise StatefulSets use the default OnDelete strategy of apps/v1beta1.
+func StatefulSets(kclient kubernetes.Interface, ns string, groupVersion string) appsv1beta1.StatefulSetInterface {
+	sclient := kclient.AppsV1beta1().StatefulSets(ns)
+	if groupVersion == "" {
+		return sclient
+	}
+	return &rollingUpdateStatefulSets{
+		StatefulSetInterface: sclient,
+		client:               kclient.AppsV1beta1().RESTClient(),
+		namespace:            ns,
+		groupVersion:         groupVersion,
+	}
+}
+
+type rollingUpdateStatefulSets struct {
+	appsv1beta1.StatefulSetInterface
+	client       rest.Interface
+	namespace    string
+	groupVersion string
+}
+
+func (s *rollingUpdateStatefulSets) Create(sset *v1beta1.StatefulSet) (*v1beta1.StatefulSet, error) {
+	return s.write(s.client.Post(), sset)
+}
+
+func (s *rollingUpdateStatefulSets) Update(sset *v1beta1.StatefulSet) (*v1beta1.StatefulSet, error) {
+	return s.write(s.client.Put().Name(sset.Name), sset)
+}
+
+func (s *rollingUpdateStatefulSets) write(req *rest.Request, sset *v1beta1.StatefulSet) (*v1beta1.StatefulSet, error) {
+	b, err := json.Marshal(sset)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	obj["apiVersion"] = s.groupVersion
+	obj["kind"] = "StatefulSet"
+	obj["spec"].(map[string]interface{})["updateStrategy"] = map[string]interface{}{
+		"type": "RollingUpdate",
+		"rollingUpdate": map[string]interface{}{
+			"partition": Partition(sset),
+		},
+	}
+	if b, err = json.Marshal(obj); err != nil {
+		return nil, err
+	}
+
+	b, err = req.AbsPath("/apis", s.groupVersion).
+		Namespace(s.namespace).
+		Resource("statefulsets").
+		SetHeader("Content-Type", "application/json").
+		Body(b).
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	res := &v1beta1.StatefulSet{}
+	return res, json.Unmarshal(b, res)
+}
+
+// LowerPartition lowers the partition of the StatefulSet, so that the
+// StatefulSet controller updates the given pods.
+func LowerPartition(sclient appsv1beta1.StatefulSetInterface, sset *v1beta1.StatefulSet, pods []v1.Pod) error {
+	partition := Partition(sset)
+	for _, p := range pods {
+		if o := podOrdinal(p); o >= 0 && int32(o) < partition {
+			partition = int32(o)
+		}
+	}
+	if partition == Partition(sset) {
+		return nil
+	}
+
+	update := *sset
+	SetPartition(&update, partition)
+	if _, err := sclient.Update(&update); err != nil {
+		return errors.Wrap(err, "updating statefulset partition failed")
+	}
+	return nil
+}
+
+// HoldPodTemplateChange sets the partition of the desired StatefulSet to its
+// number of replicas if its pod template differs from the one of the old
+// StatefulSet. The StatefulSet controller then only updates the pods once the
+// operator lowers the partition according to the update strategy.
+func HoldPodTemplateChange(old, desired *v1beta1.StatefulSet) {
+	if old.Spec.Template.Annotations[PodTemplateHashAnnotation] == desired.Spec.Template.Annotations[PodTemplateHashAnnotation] {
+		return
+	}
+	replicas := int32(1)
+	if desired.Spec.Replicas != nil {
+		replicas = *desired.Spec.Replicas
+	}
+	SetPartition(desired, replicas)
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+func makeStatefulSet(image string, replicas int32) *v1beta1.StatefulSet {
+	sset := &v1beta1.StatefulSet{
+		Spec: v1beta1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "prometheus", Image: image}},
+				},
+			},
+		},
+	}
+	if err := SetPodTemplateHash(sset); err != nil {
+		panic(err)
+	}
+	return sset
+}
+
+func TestHoldPodTemplateChange(t *testing.T) {
+	old := makeStatefulSet("quay.io/prometheus/prometheus:v1.7.0", 3)
+	SetPartition(old, 0)
+
+	desired := makeStatefulSet("quay.io/prometheus/prometheus:v1.7.0", 3)
+	desired.Annotations = old.Annotations
+	HoldPodTemplateChange(old, desired)
+	if p := Partition(desired); p != 0 {
+		t.Fatalf("expected partition 0 for unchanged pod template, got %d", p)
+	}
+
+	desired = makeStatefulSet("quay.io/prometheus/prometheus:v1.7.1", 3)
+	HoldPodTemplateChange(old, desired)
+	if p := Partition(desired); p != 3 {
+		t.Fatalf("expected partition 3 for changed pod template, got %d", p)
+	}
+	if p := Partition(old); p != 0 {
+		t.Fatalf("old StatefulSet must not be modified, got partition %d", p)
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leaderelection implements leader election among replicas of the
+// operator based on a lock stored in a ConfigMap.
+//
+// The lock record and the acquire/renew semantics follow the leader election
+// of the Kubernetes controller-manager, so that the lock can be inspected with
+// the same tooling.
+package leaderelection
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// LeaderAnnotationKey is the annotation of the lock ConfigMap holding the
+// LeaderElectionRecord.
+const LeaderAnnotationKey = "control-plane.alpha.kubernetes.io/leader"
+
+// LeaderElectionRecord is the record stored in the lock ConfigMap.
+type LeaderElectionRecord struct {
+	HolderIdentity       string      `json:"holderIdentity"`
+	LeaseDurationSeconds int         `json:"leaseDurationSeconds"`
+	AcquireTime          metav1.Time `json:"acquireTime"`
+	RenewTime            metav1.Time `json:"renewTime"`
+	LeaderTransitions    int         `json:"leaderTransitions"`
+}
+
+// Config defines configuration parameters for the LeaderElector.
+type Config struct {
+	// Namespace and Name of the lock ConfigMap.
+	Namespace string
+	Name      string
+	// Identity of this replica, typically the Pod name.
+	Identity string
+	// LeaseDuration is the duration non-leaders wait before attempting to
+	// take over leadership of a leader that stopped renewing the lock.
+	LeaseDuration time.Duration
+	// RenewDeadline is the duration the leader retries renewing the lock
+	// before giving up leadership.
+	RenewDeadline time.Duration
+	// RetryPeriod is the duration between acquire and renew attempts.
+	RetryPeriod time.Duration
+}
+
+// LeaderElector runs leader election for a single lock.
+type LeaderElector struct {
+	kclient kubernetes.Interface
+	config  Config
+	logger  log.Logger
+
+	// observedRecord and observedTime track the last lock record seen and
+	// when it was seen, based on the local clock.
+	mtx            sync.Mutex
+	observedRecord LeaderElectionRecord
+	observedTime   time.Time
+
+	isLeader    prometheus.Gauge
+	transitions prometheus.Counter
+}
+
+// New creates a new LeaderElector.
+func New(kclient kubernetes.Interface, conf Config, logger log.Logger) (*LeaderElector, error) {
+	if conf.Namespace == "" || conf.Name == "" {
+		return nil, fmt.Errorf("leader election lock namespace and name must be set")
+	}
+	if conf.Identity == "" {
+		return nil, fmt.Errorf("leader election identity must be set")
+	}
+	if conf.LeaseDuration <= conf.RenewDeadline {
+		return nil, fmt.Errorf("lease duration must be greater than renew deadline")
+	}
+	if conf.RenewDeadline <= conf.RetryPeriod {
+		return nil, fmt.Errorf("renew deadline must be greater than retry period")
+	}
+
+	return &LeaderElector{
+		kclient: kclient,
+		config:  conf,
+		logger:  logger,
+		isLeader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "prometheus_operator_leader",
+			Help: "Whether this operator replica is the leader (1) or not (0).",
+		}),
+		transitions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_operator_leader_transitions_total",
+			Help: "Number of leadership transitions observed by this operator replica.",
+		}),
+	}, nil
+}
+
+func (le *LeaderElector) RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(le.isLeader, le.transitions)
+}
+
+// Run waits until leadership is acquired and then calls run with a channel
+// that is closed once leadership is lost. Run returns the error of run if
+// stopc is closed, or an error if leadership was lost. If stopc is closed or
+// run returns while leading, the lock is released, so that another replica
+// takes over, and the error of run is returned.
+func (le *LeaderElector) Run(stopc <-chan struct{}, run func(stopc <-chan struct{}) error) error {
+	if !le.acquire(stopc) {
+		return nil
+	}
+
+	le.logger.Log("msg", "acquired leadership", "identity", le.config.Identity)
+	le.isLeader.Set(1)
+	defer le.isLeader.Set(0)
+
+	var runErr error
+	leaderc := make(chan struct{})
+	runDone := make(chan struct{})
+	go func() {
+		runErr = run(leaderc)
+		close(runDone)
+	}()
+
+	err := le.renew(stopc, runDone)
+	close(leaderc)
+	<-runDone
+
+	if err != nil && err != errRunTerminated {
+		// Leadership was lost, so the lock isn't ours to release.
+		return err
+	}
+	// The lock is released whenever this replica stops while leading, e.g.
+	// on termination during a node drain, so that another replica takes
+	// over without waiting for the lease to expire.
+	le.release()
+	if err == nil {
+		return runErr
+	}
+	select {
+	case <-stopc:
+	default:
+		if runErr == nil {
+			runErr = errors.New("operator terminated unexpectedly while leading")
+		}
+	}
+	return runErr
+}
+
+// errRunTerminated is returned by renew if run returned while leading.
+var errRunTerminated = errors.New("run terminated")
+
+// acquire tries to acquire leadership until it succeeds or stopc is closed.
+func (le *LeaderElector) acquire(stopc <-chan struct{}) bool {
+	le.logger.Log("msg", "attempting to acquire leadership", "lock", le.config.Namespace+"/"+le.config.Name)
+
+	ticker := time.NewTicker(le.config.RetryPeriod)
+	defer ticker.Stop()
+
+	for {
+		if le.tryAcquireOrRenew() {
+			return true
+		}
+		select {
+		case <-stopc:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew keeps renewing the lock until stopc is closed, renewing did not
+// succeed within the renew deadline, or runDone is closed.
+func (le *LeaderElector) renew(stopc, runDone <-chan struct{}) error {
+	ticker := time.NewTicker(le.config.RetryPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopc:
+			return nil
+		case <-runDone:
+			le.logger.Log("msg", "stopped renewing leadership as the operator terminated", "identity", le.config.Identity)
+			return errRunTerminated
+		case <-ticker.C:
+		}
+
+		err := wait.PollImmediate(le.config.RetryPeriod, le.config.RenewDeadline, func() (bool, error) {
+			return le.tryAcquireOrRenew(), nil
+		})
+		if err != nil {
+			le.logger.Log("msg", "lost leadership", "identity", le.config.Identity)
+			return errors.New("leadership lost")
+		}
+	}
+}
+
+// release gives up the lock if this replica holds it, so that other replicas
+// can acquire it without waiting for the lease to expire.
+func (le *LeaderElector) release() {
+	cmClient := le.kclient.CoreV1().ConfigMaps(le.config.Namespace)
+	cm, err := cmClient.Get(le.config.Name, metav1.GetOptions{})
+	if err != nil {
+		le.logger.Log("msg", "retrieving leader election lock failed", "err", err)
+		return
+	}
+	r, err := getRecord(cm)
+	if err != nil || r.HolderIdentity != le.config.Identity {
+		return
+	}
+
+	r.HolderIdentity = ""
+	if err := setRecord(cm, r); err != nil {
+		le.logger.Log("msg", "encoding leader election record failed", "err", err)
+		return
+	}
+	if _, err := cmClient.Update(cm); err != nil {
+		le.logger.Log("msg", "releasing leader election lock failed", "err", err)
+		return
+	}
+	le.logger.Log("msg", "released leadership", "identity", le.config.Identity)
+}
+
+// tryAcquireOrRenew tries to acquire or renew the lock and returns whether
+// this replica holds the lock afterwards.
+func (le *LeaderElector) tryAcquireOrRenew() bool {
+	now := metav1.Now()
+	record := LeaderElectionRecord{
+		HolderIdentity:       le.config.Identity,
+		LeaseDurationSeconds: int(le.config.LeaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+
+	cmClient := le.kclient.CoreV1().ConfigMaps(le.config.Namespace)
+	cm, err := cmClient.Get(le.config.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			le.logger.Log("msg", "retrieving leader election lock failed", "err", err)
+			return false
+		}
+
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        le.config.Name,
+				Annotations: map[string]string{},
+			},
+		}
+		if err := setRecord(cm, record); err != nil {
+			le.logger.Log("msg", "encoding leader election record failed", "err", err)
+			return false
+		}
+		if _, err := cmClient.Create(cm); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				le.logger.Log("msg", "creating leader election lock failed", "err", err)
+			}
+			return false
+		}
+		le.observe(record)
+		return true
+	}
+
+	old, err := getRecord(cm)
+	if err != nil {
+		le.logger.Log("msg", "decoding leader election record failed", "err", err)
+		return false
+	}
+	le.observe(old)
+
+	le.mtx.Lock()
+	observedTime := le.observedTime
+	le.mtx.Unlock()
+
+	if old.HolderIdentity != "" && old.HolderIdentity != le.config.Identity &&
+		observedTime.Add(le.config.LeaseDuration).After(now.Time) {
+		return false
+	}
+
+	if old.HolderIdentity == le.config.Identity {
+		record.AcquireTime = old.AcquireTime
+		record.LeaderTransitions = old.LeaderTransitions
+	} else {
+		record.LeaderTransitions = old.LeaderTransitions + 1
+	}
+
+	if err := setRecord(cm, record); err != nil {
+		le.logger.Log("msg", "encoding leader election record failed", "err", err)
+		return false
+	}
+	// Updates are guarded by the resource version of the retrieved lock, so
+	// only one of several concurrent takeovers succeeds.
+	if _, err := cmClient.Update(cm); err != nil {
+		if !apierrors.IsConflict(err) {
+			le.logger.Log("msg", "updating leader election lock failed", "err", err)
+		}
+		return false
+	}
+	le.observe(record)
+	return true
+}
+
+// observe records the lock record seen and logs leadership transitions.
+func (le *LeaderElector) observe(r LeaderElectionRecord) {
+	le.mtx.Lock()
+	defer le.mtx.Unlock()
+
+	if reflect.DeepEqual(le.observedRecord, r) {
+		return
+	}
+	if le.observedRecord.HolderIdentity != r.HolderIdentity {
+		le.logger.Log("msg", "observed new leader", "leader", r.HolderIdentity, "previous", le.observedRecord.HolderIdentity)
+		// Taking over a released lock or the first record seen is not a
+		// transition.
+		if le.observedRecord.HolderIdentity != "" {
+			le.transitions.Inc()
+		}
+	}
+	le.observedRecord = r
+	le.observedTime = time.Now()
+}
+
+func getRecord(cm *v1.ConfigMap) (LeaderElectionRecord, error) {
+	var r LeaderElectionRecord
+	s, ok := cm.Annotations[LeaderAnnotationKey]
+	if !ok {
+		return r, nil
+	}
+	err := json.Unmarshal([]byte(s), &r)
+	return r, err
+}
+
+func setRecord(cm *v1.ConfigMap, r LeaderElectionRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[LeaderAnnotationKey] = string(b)
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/rest"
+)
+
+// lockServer serves the lock ConfigMap like the API server, including
+// conflict detection on updates.
+type lockServer struct {
+	mtx sync.Mutex
+	cm  *v1.ConfigMap
+	rv  int
+}
+
+func (s *lockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	status := func(code int, reason metav1.StatusReason) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(&metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Reason:   reason,
+			Code:     int32(code),
+		})
+	}
+
+	switch r.Method {
+	case "GET":
+		if s.cm == nil {
+			status(http.StatusNotFound, metav1.StatusReasonNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(s.cm)
+	case "POST", "PUT":
+		var cm v1.ConfigMap
+		if err := json.NewDecoder(r.Body).Decode(&cm); err != nil {
+			status(http.StatusBadRequest, metav1.StatusReasonBadRequest)
+			return
+		}
+		if r.Method == "POST" && s.cm != nil {
+			status(http.StatusConflict, metav1.StatusReasonAlreadyExists)
+			return
+		}
+		if r.Method == "PUT" && (s.cm == nil || cm.ResourceVersion != s.cm.ResourceVersion) {
+			status(http.StatusConflict, metav1.StatusReasonConflict)
+			return
+		}
+		s.rv++
+		cm.ResourceVersion = strconv.Itoa(s.rv)
+		s.cm = &cm
+		json.NewEncoder(w).Encode(s.cm)
+	}
+}
+
+func (s *lockServer) holder(t *testing.T) string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r, err := getRecord(s.cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.HolderIdentity
+}
+
+func newTestElector(t *testing.T, url, identity string) *LeaderElector {
+	kclient, err := kubernetes.NewForConfig(&rest.Config{Host: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	le, err := New(kclient, Config{
+		Namespace:     "monitoring",
+		Name:          "prometheus-operator",
+		Identity:      identity,
+		LeaseDuration: time.Minute,
+		RenewDeadline: 30 * time.Second,
+		RetryPeriod:   10 * time.Millisecond,
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return le
+}
+
+func TestRunReleasesLeadershipWhenRunFails(t *testing.T) {
+	ls := &lockServer{}
+	srv := httptest.NewServer(ls)
+	defer srv.Close()
+
+	le := newTestElector(t, srv.URL, "a")
+	runErr := errors.New("operator failed")
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- le.Run(make(chan struct{}), func(stopc <-chan struct{}) error {
+			time.Sleep(50 * time.Millisecond)
+			return runErr
+		})
+	}()
+
+	select {
+	case err := <-errc:
+		if err != runErr {
+			t.Fatalf("expected error of run, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader kept renewing after run failed")
+	}
+	if h := ls.holder(t); h != "" {
+		t.Fatalf("expected lock to be released, held by %q", h)
+	}
+
+	// Another replica takes over without waiting for the lease to expire.
+	if !newTestElector(t, srv.URL, "b").tryAcquireOrRenew() {
+		t.Fatal("expected released lock to be acquired")
+	}
+	if h := ls.holder(t); h != "b" {
+		t.Fatalf("expected lock to be held by b, held by %q", h)
+	}
+}
+
+func TestRunStopsRunWhenStopped(t *testing.T) {
+	ls := &lockServer{}
+	srv := httptest.NewServer(ls)
+	defer srv.Close()
+
+	le := newTestElector(t, srv.URL, "a")
+	stopc := make(chan struct{})
+	started := make(chan struct{})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- le.Run(stopc, func(leaderc <-chan struct{}) error {
+			close(started)
+			<-leaderc
+			return nil
+		})
+	}()
+
+	<-started
+	close(stopc)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run was not stopped")
+	}
+	if h := ls.holder(t); h != "" {
+		t.Fatalf("expected lock to be released, held by %q", h)
+	}
+}
+
+func TestObserveCountsTransitions(t *testing.T) {
+	le := newTestElector(t, "http://localhost", "a")
+	for _, holder := range []string{"b", "b", "", "c", "a"} {
+		le.observe(LeaderElectionRecord{HolderIdentity: holder})
+	}
+	m := &dto.Metric{}
+	if err := le.transitions.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	// b releasing the lock and a taking over from c are transitions, the
+	// first record seen and c taking over the released lock are not.
+	if v := m.GetCounter().GetValue(); v != 2 {
+		t.Fatalf("expected 2 transitions, got %v", v)
+	}
+}
+
+func TestLockHeldByOtherReplica(t *testing.T) {
+	srv := httptest.NewServer(&lockServer{})
+	defer srv.Close()
+
+	if !newTestElector(t, srv.URL, "a").tryAcquireOrRenew() {
+		t.Fatal("expected lock to be acquired")
+	}
+	if newTestElector(t, srv.URL, "b").tryAcquireOrRenew() {
+		t.Fatal("expected lock held by another replica not to be acquired")
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listwatch
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/tools/cache"
+)
+
+// resourceVersionSeparator joins the resource versions of the individual
+// namespaces into the resource version of a combined list.
+const resourceVersionSeparator = "/"
+
+// ListerWatcherFunc returns a ListerWatcher for a single namespace.
+type ListerWatcherFunc func(namespace string) cache.ListerWatcher
+
+// IsAllNamespaces checks whether the given namespaces select all namespaces
+// of the cluster.
+func IsAllNamespaces(namespaces []string) bool {
+	if len(namespaces) == 0 {
+		return true
+	}
+	for _, ns := range namespaces {
+		if ns == api.NamespaceAll {
+			return true
+		}
+	}
+	return false
+}
+
+// MultiNamespaceListerWatcher returns a ListerWatcher restricted to the
+// allowed namespaces, excluding all objects from denied namespaces.
+//
+// If allowed selects all namespaces, a single cluster wide ListerWatcher is
+// used. Otherwise one ListerWatcher per namespace is created and their
+// results are merged, so that only namespaced permissions are required.
+func MultiNamespaceListerWatcher(allowed, denied []string, f ListerWatcherFunc) cache.ListerWatcher {
+	var lw cache.ListerWatcher
+	if IsAllNamespaces(allowed) {
+		lw = f(api.NamespaceAll)
+	} else {
+		mlw := &multiListerWatcher{}
+		for _, ns := range dedup(allowed) {
+			mlw.namespaces = append(mlw.namespaces, ns)
+			mlw.lws = append(mlw.lws, f(ns))
+		}
+		lw = mlw
+	}
+
+	if len(denied) == 0 {
+		return lw
+	}
+	return newDenyListerWatcher(lw, denied)
+}
+
+func dedup(namespaces []string) []string {
+	seen := map[string]struct{}{}
+	res := []string{}
+	for _, ns := range namespaces {
+		if _, ok := seen[ns]; ok {
+			continue
+		}
+		seen[ns] = struct{}{}
+		res = append(res, ns)
+	}
+	return res
+}
+
+// multiListerWatcher lists and watches several namespaces as if they were a
+// single resource.
+type multiListerWatcher struct {
+	namespaces []string
+	lws        []cache.ListerWatcher
+
+	mtx sync.Mutex
+	// last is the most recently started watch. It resolves the resource
+	// version the reflector resumes from once it terminates.
+	last *multiWatch
+}
+
+// List lists all namespaces and merges the items into the list returned for
+// the first namespace. The resource versions of all lists are joined, so that
+// Watch can resume every namespace from the point its list was taken.
+func (m *multiListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	rvs := m.splitResourceVersion(options.ResourceVersion)
+
+	var (
+		res   runtime.Object
+		items []runtime.Object
+	)
+	for i, lw := range m.lws {
+		opts := options
+		opts.ResourceVersion = rvs[i]
+
+		l, err := lw.List(opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing namespace %q failed", m.namespaces[i])
+		}
+		objs, err := meta.ExtractList(l)
+		if err != nil {
+			return nil, errors.Wrapf(err, "extracting list of namespace %q failed", m.namespaces[i])
+		}
+		lm, err := meta.ListAccessor(l)
+		if err != nil {
+			return nil, errors.Wrapf(err, "accessing list metadata of namespace %q failed", m.namespaces[i])
+		}
+
+		items = append(items, objs...)
+		rvs[i] = lm.GetResourceVersion()
+		if res == nil {
+			res = l
+		}
+	}
+
+	if err := meta.SetList(res, items); err != nil {
+		return nil, errors.Wrap(err, "merging lists failed")
+	}
+	lm, err := meta.ListAccessor(res)
+	if err != nil {
+		return nil, err
+	}
+	lm.SetResourceVersion(strings.Join(rvs, resourceVersionSeparator))
+
+	return res, nil
+}
+
+// Watch watches all namespaces and merges their events into a single watch.
+func (m *multiListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	rvs := m.splitResourceVersion(options.ResourceVersion)
+
+	mw := &multiWatch{
+		result: make(chan watch.Event),
+		stopc:  make(chan struct{}),
+		rvs:    rvs,
+	}
+	for i, lw := range m.lws {
+		opts := options
+		opts.ResourceVersion = rvs[i]
+
+		w, err := lw.Watch(opts)
+		if err != nil {
+			mw.Stop()
+			return nil, errors.Wrapf(err, "watching namespace %q failed", m.namespaces[i])
+		}
+		mw.watches = append(mw.watches, w)
+	}
+
+	for i, w := range mw.watches {
+		mw.wg.Add(1)
+		go mw.forward(i, w)
+	}
+	go func() {
+		mw.wg.Wait()
+		close(mw.result)
+	}()
+
+	m.mtx.Lock()
+	m.last = mw
+	m.mtx.Unlock()
+
+	return mw, nil
+}
+
+// splitResourceVersion returns the resource version for every namespace.
+//
+// When a watch terminates, the reflector resumes from the resource version
+// of the last object it received. Events of the namespaces are forwarded
+// independently, so events of other namespaces with lower resource versions
+// may not have been received yet. The resource versions of the last watch
+// are therefore used per namespace if it delivered that object last. Any
+// other resource version, which was not produced by List, is used for all
+// namespaces.
+func (m *multiListerWatcher) splitResourceVersion(rv string) []string {
+	rvs := strings.Split(rv, resourceVersionSeparator)
+	if len(rvs) == len(m.lws) {
+		return rvs
+	}
+
+	m.mtx.Lock()
+	last := m.last
+	m.mtx.Unlock()
+	if last != nil {
+		if rvs, ok := last.resourceVersions(rv); ok {
+			return rvs
+		}
+	}
+
+	rvs = make([]string, len(m.lws))
+	for i := range rvs {
+		rvs[i] = rv
+	}
+	return rvs
+}
+
+// multiWatch merges the events of several watches. It terminates once any of
+// the underlying watches terminates so that the reflector restarts all of them.
+type multiWatch struct {
+	watches []watch.Interface
+	result  chan watch.Event
+	wg      sync.WaitGroup
+
+	// mtx serializes the delivery of events, so that rvs and lastRV reflect
+	// the events received by the consumer.
+	mtx sync.Mutex
+	// rvs holds the resource version of the last delivered event of each
+	// namespace.
+	rvs []string
+	// lastRV is the resource version of the last delivered event.
+	lastRV string
+
+	stopOnce sync.Once
+	stopc    chan struct{}
+}
+
+func (mw *multiWatch) ResultChan() <-chan watch.Event {
+	return mw.result
+}
+
+func (mw *multiWatch) Stop() {
+	mw.stopOnce.Do(func() {
+		close(mw.stopc)
+		for _, w := range mw.watches {
+			w.Stop()
+		}
+	})
+}
+
+// resourceVersions returns the resource versions of all namespaces if rv is
+// the resource version of the last delivered event.
+func (mw *multiWatch) resourceVersions(rv string) ([]string, bool) {
+	mw.mtx.Lock()
+	defer mw.mtx.Unlock()
+
+	if rv == "" || rv != mw.lastRV {
+		return nil, false
+	}
+	return append([]string(nil), mw.rvs...), true
+}
+
+func (mw *multiWatch) forward(i int, w watch.Interface) {
+	defer mw.wg.Done()
+	defer mw.Stop()
+
+	for {
+		select {
+		case <-mw.stopc:
+			return
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			if !mw.deliver(i, ev) {
+				return
+			}
+		}
+	}
+}
+
+// deliver passes the event of the i-th namespace on and records its resource
+// version. It returns false if the watch was stopped.
+func (mw *multiWatch) deliver(i int, ev watch.Event) bool {
+	mw.mtx.Lock()
+	defer mw.mtx.Unlock()
+
+	select {
+	case mw.result <- ev:
+	case <-mw.stopc:
+		return false
+	}
+	if ev.Type == watch.Error {
+		return true
+	}
+	if o, err := meta.Accessor(ev.Object); err == nil {
+		mw.rvs[i] = o.GetResourceVersion()
+		mw.lastRV = mw.rvs[i]
+	}
+	return true
+}
+
+// denyListerWatcher drops all objects of the denied namespaces from the lists
+// and watches of the wrapped ListerWatcher.
+type denyListerWatcher struct {
+	lw     cache.ListerWatcher
+	denied map[string]struct{}
+}
+
+func newDenyListerWatcher(lw cache.ListerWatcher, denied []string) *denyListerWatcher {
+	d := &denyListerWatcher{
+		lw:     lw,
+		denied: map[string]struct{}{},
+	}
+	for _, ns := range denied {
+		d.denied[ns] = struct{}{}
+	}
+	return d
+}
+
+func (d *denyListerWatcher) isDenied(obj runtime.Object) bool {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	_, ok := d.denied[o.GetNamespace()]
+	return ok
+}
+
+func (d *denyListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	l, err := d.lw.List(options)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := meta.ExtractList(l)
+	if err != nil {
+		return nil, errors.Wrap(err, "extracting list failed")
+	}
+
+	allowed := make([]runtime.Object, 0, len(objs))
+	for _, obj := range objs {
+		if !d.isDenied(obj) {
+			allowed = append(allowed, obj)
+		}
+	}
+	if err := meta.SetList(l, allowed); err != nil {
+		return nil, errors.Wrap(err, "filtering list failed")
+	}
+	return l, nil
+}
+
+func (d *denyListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := d.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		// Errors carry a Status object, which must always be passed on.
+		if in.Type == watch.Error {
+			return in, true
+		}
+		return in, !d.isDenied(in.Object)
+	}), nil
+}
+
+// WithLabelSelector returns a ListerWatcher that only lists and watches the
+// objects of lw matching the label selector. An empty selector matches all
+// objects.
+func WithLabelSelector(lw cache.ListerWatcher, selector string) cache.ListerWatcher {
+	if selector == "" {
+		return lw
+	}
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return lw.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return lw.Watch(options)
+		},
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listwatch
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var configMaps = map[string][]string{
+	"default":     {"a", "b"},
+	"monitoring":  {"c"},
+	"kube-system": {"d"},
+}
+
+func fakeListerWatcher(requested *[]string) ListerWatcherFunc {
+	return func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				*requested = append(*requested, namespace)
+
+				l := &v1.ConfigMapList{ListMeta: metav1.ListMeta{ResourceVersion: "rv-" + namespace}}
+				for ns, names := range configMaps {
+					if namespace != api.NamespaceAll && namespace != ns {
+						continue
+					}
+					for _, name := range names {
+						l.Items = append(l.Items, v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}})
+					}
+				}
+				return l, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return watch.NewFake(), nil
+			},
+		}
+	}
+}
+
+func listNames(t *testing.T, lw cache.ListerWatcher) ([]string, string) {
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := obj.(*v1.ConfigMapList)
+
+	names := []string{}
+	for _, cm := range l.Items {
+		names = append(names, cm.Name)
+	}
+	sort.Strings(names)
+	return names, l.ResourceVersion
+}
+
+func TestMultiNamespaceListerWatcherAllNamespaces(t *testing.T) {
+	requested := []string{}
+	lw := MultiNamespaceListerWatcher(nil, nil, fakeListerWatcher(&requested))
+
+	names, _ := listNames(t, lw)
+	if !reflect.DeepEqual(names, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("unexpected items %v", names)
+	}
+	if !reflect.DeepEqual(requested, []string{api.NamespaceAll}) {
+		t.Fatalf("expected a single cluster wide list, got %v", requested)
+	}
+}
+
+func TestMultiNamespaceListerWatcherAllowed(t *testing.T) {
+	requested := []string{}
+	lw := MultiNamespaceListerWatcher([]string{"default", "monitoring", "default"}, nil, fakeListerWatcher(&requested))
+
+	names, rv := listNames(t, lw)
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected items %v", names)
+	}
+	if !reflect.DeepEqual(requested, []string{"default", "monitoring"}) {
+		t.Fatalf("expected one list per namespace, got %v", requested)
+	}
+	if rv != "rv-default/rv-monitoring" {
+		t.Fatalf("unexpected resource version %q", rv)
+	}
+}
+
+func TestMultiNamespaceListerWatcherDenied(t *testing.T) {
+	requested := []string{}
+	lw := MultiNamespaceListerWatcher(nil, []string{"kube-system"}, fakeListerWatcher(&requested))
+
+	names, _ := listNames(t, lw)
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected items %v", names)
+	}
+}
+
+func TestMultiNamespaceListerWatcherWatch(t *testing.T) {
+	watches := map[string]*watch.FakeWatcher{}
+	lw := MultiNamespaceListerWatcher([]string{"default", "kube-system"}, []string{"kube-system"}, func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				w := watch.NewFake()
+				watches[namespace] = w
+				return w, nil
+			},
+		}
+	})
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	go func() {
+		watches["kube-system"].Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "d"}})
+		watches["default"].Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}})
+	}()
+
+	ev := <-w.ResultChan()
+	if cm := ev.Object.(*v1.ConfigMap); cm.Namespace != "default" || cm.Name != "a" {
+		t.Fatalf("unexpected event for %s/%s", cm.Namespace, cm.Name)
+	}
+}
+
+func TestMultiNamespaceListerWatcherWatchRestart(t *testing.T) {
+	watches := map[string]*watch.FakeWatcher{}
+	resumed := map[string]string{}
+	lw := MultiNamespaceListerWatcher([]string{"default", "monitoring"}, nil, func(namespace string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				w := watch.NewFake()
+				watches[namespace] = w
+				resumed[namespace] = options.ResourceVersion
+				return w, nil
+			},
+		}
+	})
+
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: "10/20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resumed, map[string]string{"default": "10", "monitoring": "20"}) {
+		t.Fatalf("unexpected resource versions %v", resumed)
+	}
+
+	go watches["monitoring"].Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "c", ResourceVersion: "25"}})
+	ev := <-w.ResultChan()
+	w.Stop()
+
+	// The reflector resumes from the resource version of the last object.
+	// Events of the default namespace between 10 and 25 must not be skipped.
+	rv := ev.Object.(*v1.ConfigMap).ResourceVersion
+	w, err = lw.Watch(metav1.ListOptions{ResourceVersion: rv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if !reflect.DeepEqual(resumed, map[string]string{"default": "10", "monitoring": "25"}) {
+		t.Fatalf("unexpected resource versions %v", resumed)
+	}
+}
+
+func TestWithLabelSelector(t *testing.T) {
+	var selectors []string
+	lw := WithLabelSelector(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			selectors = append(selectors, options.LabelSelector)
+			return &v1.ConfigMapList{}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			selectors = append(selectors, options.LabelSelector)
+			return watch.NewFake(), nil
+		},
+	}, "app=prometheus")
+
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lw.Watch(metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selectors, []string{"app=prometheus", "app=prometheus"}) {
+		t.Fatalf("unexpected label selectors %v", selectors)
+	}
+}
@@ -59,3 +59,34 @@ func (c *prometheusCollector) collectPrometheus(ch chan<- prometheus.Metric, p *
 	}
 	ch <- prometheus.MustNewConstMetric(descPrometheusSpecReplicas, prometheus.GaugeValue, replicas, p.Namespace, p.Name)
 }
+
+type informerCacheCollector struct {
+	desc   *prometheus.Desc
+	stores map[string]cache.Store
+}
+
+// NewInformerCacheCollector returns a collector exposing the number of
+// objects held by the informer caches of a controller, keyed by resource.
+func NewInformerCacheCollector(controller string, stores map[string]cache.Store) *informerCacheCollector {
+	return &informerCacheCollector{
+		desc: prometheus.NewDesc(
+			"prometheus_operator_informer_cache_objects",
+			"Number of objects in the informer cache of a resource.",
+			[]string{"resource"},
+			prometheus.Labels{"controller": controller},
+		),
+		stores: stores,
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *informerCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *informerCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for resource, s := range c.stores {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(len(s.ListKeys())), resource)
+	}
+}
@@ -17,13 +17,14 @@ package prometheus
 import (
 	"bytes"
 	"fmt"
-	"reflect"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/coreos/prometheus-operator/pkg/analytics"
 	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
 	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+	"github.com/coreos/prometheus-operator/pkg/listwatch"
 
 	"github.com/coreos/prometheus-operator/third_party/workqueue"
 	"github.com/go-kit/kit/log"
@@ -34,7 +35,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/api"
 	"k8s.io/client-go/pkg/api/v1"
@@ -50,6 +53,12 @@ const (
 	configFilename    = "prometheus.yaml"
 
 	resyncPeriod = 5 * time.Minute
+
+	// secretRefIndex indexes objects by the keys of the Secrets they reference.
+	secretRefIndex = "secretRef"
+
+	// prometheusPodSelector selects the pods of all Prometheus servers.
+	prometheusPodSelector = "app=prometheus"
 )
 
 // Operator manages lify cycle of Prometheus deployments and
@@ -64,8 +73,21 @@ type Operator struct {
 	cmapInf cache.SharedIndexInformer
 	secrInf cache.SharedIndexInformer
 	ssetInf cache.SharedIndexInformer
+	podInf  cache.SharedIndexInformer
+	snapInf cache.SharedIndexInformer
 
 	queue workqueue.RateLimitingInterface
+	// snapQueue holds the keys of PrometheusSnapshots, which are synced
+	// independently of the Prometheus objects.
+	snapQueue workqueue.RateLimitingInterface
+
+	// ssetGroupVersion is the StatefulSet API used for rolling updates. If
+	// empty, pods are updated by deleting them.
+	ssetGroupVersion string
+
+	enqueues        *prometheus.CounterVec
+	skippedEnqueues *prometheus.CounterVec
+	storageUsage    *storageUsageCollector
 
 	host                   string
 	kubeletObjectName      string
@@ -84,6 +106,14 @@ type Config struct {
 	PrometheusConfigReloader     string
 	AlertmanagerDefaultBaseImage string
 	PrometheusDefaultBaseImage   string
+	Namespaces                   []string
+	DenyNamespaces               []string
+	ManageTPRs                   bool
+	PrometheusSelector           string
+	AlertmanagerSelector         string
+	OperatorInstance             string
+	Workers                      int
+	LabeledConfigObjectsOnly     bool
 }
 
 type BasicAuthCredentials struct {
@@ -121,24 +151,49 @@ func New(conf Config, logger log.Logger) (*Operator, error) {
 		kubeletSyncEnabled = true
 	}
 
+	if _, err := labels.Parse(conf.PrometheusSelector); err != nil {
+		return nil, errors.Wrap(err, "parsing Prometheus instance selector failed")
+	}
+
 	c := &Operator{
 		kclient:                client,
 		mclient:                mclient,
 		logger:                 logger,
 		queue:                  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "prometheus"),
+		snapQueue:              workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "prometheussnapshot"),
 		host:                   cfg.Host,
 		kubeletObjectName:      kubeletObjectName,
 		kubeletObjectNamespace: kubeletObjectNamespace,
 		kubeletSyncEnabled:     kubeletSyncEnabled,
 		config:                 conf,
+		enqueues: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_operator_prometheus_enqueues_total",
+			Help: "Number of times a Prometheus object was enqueued because of a change to an object it depends on.",
+		}, []string{"trigger"}),
+		skippedEnqueues: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_operator_prometheus_enqueues_skipped_total",
+			Help: "Number of times a Prometheus object was not enqueued because a changed object in its namespace does not affect it.",
+		}, []string{"trigger"}),
+		storageUsage: newStorageUsageCollector(),
 	}
 
 	c.promInf = cache.NewSharedIndexInformer(
-		&cache.ListWatch{
-			ListFunc:  mclient.Prometheuses(api.NamespaceAll).List,
-			WatchFunc: mclient.Prometheuses(api.NamespaceAll).Watch,
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					options.LabelSelector = conf.PrometheusSelector
+					return mclient.Prometheuses(ns).List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					options.LabelSelector = conf.PrometheusSelector
+					return mclient.Prometheuses(ns).Watch(options)
+				},
+			}
+		}),
+		&v1alpha1.Prometheus{}, resyncPeriod, cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+			secretRefIndex:       prometheusSecretRefs,
 		},
-		&v1alpha1.Prometheus{}, resyncPeriod, cache.Indexers{},
 	)
 	c.promInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.handleAddPrometheus,
@@ -147,11 +202,15 @@ func New(conf Config, logger log.Logger) (*Operator, error) {
 	})
 
 	c.smonInf = cache.NewSharedIndexInformer(
-		&cache.ListWatch{
-			ListFunc:  mclient.ServiceMonitors(api.NamespaceAll).List,
-			WatchFunc: mclient.ServiceMonitors(api.NamespaceAll).Watch,
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc:  mclient.ServiceMonitors(ns).List,
+				WatchFunc: mclient.ServiceMonitors(ns).Watch,
+			}
+		}),
+		&v1alpha1.ServiceMonitor{}, resyncPeriod, cache.Indexers{
+			secretRefIndex: serviceMonitorSecretRefs,
 		},
-		&v1alpha1.ServiceMonitor{}, resyncPeriod, cache.Indexers{},
 	)
 	c.smonInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.handleSmonAdd,
@@ -160,7 +219,12 @@ func New(conf Config, logger log.Logger) (*Operator, error) {
 	})
 
 	c.cmapInf = cache.NewSharedIndexInformer(
-		cache.NewListWatchFromClient(c.kclient.Core().RESTClient(), "configmaps", api.NamespaceAll, nil),
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return listwatch.WithLabelSelector(
+				cache.NewListWatchFromClient(c.kclient.Core().RESTClient(), "configmaps", ns, nil),
+				c.configObjectsSelector(),
+			)
+		}),
 		&v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
 	)
 	c.cmapInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -169,7 +233,12 @@ func New(conf Config, logger log.Logger) (*Operator, error) {
 		UpdateFunc: c.handleConfigMapUpdate,
 	})
 	c.secrInf = cache.NewSharedIndexInformer(
-		cache.NewListWatchFromClient(c.kclient.Core().RESTClient(), "secrets", api.NamespaceAll, nil),
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return listwatch.WithLabelSelector(
+				cache.NewListWatchFromClient(c.kclient.Core().RESTClient(), "secrets", ns, nil),
+				c.configObjectsSelector(),
+			)
+		}),
 		&v1.Secret{}, resyncPeriod, cache.Indexers{},
 	)
 	c.secrInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -179,7 +248,9 @@ func New(conf Config, logger log.Logger) (*Operator, error) {
 	})
 
 	c.ssetInf = cache.NewSharedIndexInformer(
-		cache.NewListWatchFromClient(c.kclient.Apps().RESTClient(), "statefulsets", api.NamespaceAll, nil),
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return cache.NewListWatchFromClient(c.kclient.Apps().RESTClient(), "statefulsets", ns, nil)
+		}),
 		&v1beta1.StatefulSet{}, resyncPeriod, cache.Indexers{},
 	)
 	c.ssetInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -188,16 +259,75 @@ func New(conf Config, logger log.Logger) (*Operator, error) {
 		UpdateFunc: c.handleUpdateStatefulSet,
 	})
 
+	// Pods are only needed to compute the status of Prometheus objects, so
+	// only those created for Prometheus servers are cached.
+	c.podInf = cache.NewSharedIndexInformer(
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return listwatch.WithLabelSelector(
+				cache.NewListWatchFromClient(c.kclient.Core().RESTClient(), "pods", ns, nil),
+				prometheusPodSelector,
+			)
+		}),
+		&v1.Pod{}, resyncPeriod, cache.Indexers{},
+	)
+
+	c.snapInf = cache.NewSharedIndexInformer(
+		c.listWatch(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc:  mclient.PrometheusSnapshots(ns).List,
+				WatchFunc: mclient.PrometheusSnapshots(ns).Watch,
+			}
+		}),
+		&v1alpha1.PrometheusSnapshot{}, resyncPeriod, cache.Indexers{
+			snapshotPrometheusIndex: snapshotPrometheus,
+		},
+	)
+	c.snapInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handleSnapshotAdd,
+		DeleteFunc: c.handleSnapshotDelete,
+		UpdateFunc: c.handleSnapshotUpdate,
+	})
+
 	return c, nil
 }
 
+// configObjectsSelector returns the label selector for the ConfigMaps and
+// Secrets cached by the operator.
+func (c *Operator) configObjectsSelector() string {
+	if c.config.LabeledConfigObjectsOnly {
+		return WatchedLabel
+	}
+	return ""
+}
+
+// listWatch restricts the ListerWatchers returned by f to the namespaces the
+// operator is configured to manage.
+func (c *Operator) listWatch(f listwatch.ListerWatcherFunc) cache.ListerWatcher {
+	return listwatch.MultiNamespaceListerWatcher(c.config.Namespaces, c.config.DenyNamespaces, f)
+}
+
 func (c *Operator) RegisterMetrics(r prometheus.Registerer) {
-	r.MustRegister(NewPrometheusCollector(c.promInf.GetStore()))
+	r.MustRegister(
+		NewPrometheusCollector(c.promInf.GetStore()),
+		NewInformerCacheCollector("prometheus", map[string]cache.Store{
+			"prometheuses":        c.promInf.GetStore(),
+			"servicemonitors":     c.smonInf.GetStore(),
+			"configmaps":          c.cmapInf.GetStore(),
+			"secrets":             c.secrInf.GetStore(),
+			"statefulsets":        c.ssetInf.GetStore(),
+			"pods":                c.podInf.GetStore(),
+			"prometheussnapshots": c.snapInf.GetStore(),
+		}),
+		c.enqueues,
+		c.skippedEnqueues,
+		c.storageUsage,
+	)
 }
 
 // Run the controller.
 func (c *Operator) Run(stopc <-chan struct{}) error {
 	defer c.queue.ShutDown()
+	defer c.snapQueue.ShutDown()
 
 	errChan := make(chan error)
 	go func() {
@@ -208,10 +338,22 @@ func (c *Operator) Run(stopc <-chan struct{}) error {
 		}
 		c.logger.Log("msg", "connection established", "cluster-version", v)
 
-		if err := c.createTPRs(); err != nil {
-			errChan <- errors.Wrap(err, "creating TPRs failed")
+		gv, err := k8sutil.RollingUpdateGroupVersion(c.kclient.Discovery())
+		if err != nil {
+			errChan <- errors.Wrap(err, "discovering StatefulSet API failed")
 			return
 		}
+		if gv != "" {
+			c.logger.Log("msg", "using StatefulSet rolling updates", "api-version", gv)
+		}
+		c.ssetGroupVersion = gv
+
+		if c.config.ManageTPRs {
+			if err := c.createTPRs(); err != nil {
+				errChan <- errors.Wrap(err, "creating TPRs failed")
+				return
+			}
+		}
 		errChan <- nil
 	}()
 
@@ -225,17 +367,33 @@ func (c *Operator) Run(stopc <-chan struct{}) error {
 		return nil
 	}
 
-	go c.worker()
+	workers := c.config.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
 	go c.promInf.Run(stopc)
 	go c.smonInf.Run(stopc)
 	go c.cmapInf.Run(stopc)
 	go c.secrInf.Run(stopc)
 	go c.ssetInf.Run(stopc)
+	go c.podInf.Run(stopc)
+	go c.snapInf.Run(stopc)
+
+	// StatefulSets, Secrets and Pods are read from the caches while syncing,
+	// so objects missing from an unsynced cache would be taken as not found.
+	if !cache.WaitForCacheSync(stopc, c.promInf.HasSynced, c.smonInf.HasSynced, c.cmapInf.HasSynced, c.secrInf.HasSynced, c.ssetInf.HasSynced, c.podInf.HasSynced, c.snapInf.HasSynced) {
+		return nil
+	}
+	for i := 0; i < workers; i++ {
+		go c.worker()
+	}
+	go c.snapshotWorker()
 
 	if c.kubeletSyncEnabled {
 		go c.reconcileNodeEndpoints(stopc)
 	}
+	go c.collectStorageUsage(stopc)
 
 	<-stopc
 	return nil
@@ -408,66 +566,194 @@ func (c *Operator) syncNodeEndpoints() error {
 }
 
 func (c *Operator) handleSmonAdd(obj interface{}) {
-	o, ok := c.getObject(obj)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForServiceMonitor(obj)
 }
 
 func (c *Operator) handleSmonUpdate(old, cur interface{}) {
-	o, ok := c.getObject(cur)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForServiceMonitor(old, cur)
 }
 
 func (c *Operator) handleSmonDelete(obj interface{}) {
-	o, ok := c.getObject(obj)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForServiceMonitor(obj)
 }
 
 func (c *Operator) handleSecretDelete(obj interface{}) {
-	o, ok := c.getObject(obj)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForSecret(obj)
 }
 
 func (c *Operator) handleSecretUpdate(old, cur interface{}) {
-	o, ok := c.getObject(cur)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForSecret(cur)
 }
 
 func (c *Operator) handleSecretAdd(obj interface{}) {
-	o, ok := c.getObject(obj)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForSecret(obj)
 }
 
 func (c *Operator) handleConfigMapAdd(obj interface{}) {
-	o, ok := c.getObject(obj)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
-	}
+	c.enqueueForConfigMap(obj)
 }
 
 func (c *Operator) handleConfigMapDelete(obj interface{}) {
+	c.enqueueForConfigMap(obj)
+}
+
+func (c *Operator) handleConfigMapUpdate(old, cur interface{}) {
+	c.enqueueForConfigMap(old, cur)
+}
+
+// enqueueForServiceMonitor enqueues all Prometheus objects selecting any of the
+// given versions of a ServiceMonitor.
+func (c *Operator) enqueueForServiceMonitor(objs ...interface{}) {
+	metas := c.getObjects(objs)
+	if len(metas) == 0 {
+		return
+	}
+	c.enqueueForNamespaceMatching("servicemonitor", metas[0].GetNamespace(), func(p *v1alpha1.Prometheus) bool {
+		return selectsAny(p.Spec.ServiceMonitorSelector, metas)
+	})
+}
+
+// enqueueForConfigMap enqueues all Prometheus objects selecting any of the
+// given versions of a ConfigMap as rule file.
+func (c *Operator) enqueueForConfigMap(objs ...interface{}) {
+	metas := c.getObjects(objs)
+	if len(metas) == 0 {
+		return
+	}
+	c.enqueueForNamespaceMatching("configmap", metas[0].GetNamespace(), func(p *v1alpha1.Prometheus) bool {
+		return selectsAny(p.Spec.RuleSelector, metas)
+	})
+}
+
+// enqueueForSecret enqueues all Prometheus objects referencing the Secret,
+// either directly or through one of their selected ServiceMonitors.
+func (c *Operator) enqueueForSecret(obj interface{}) {
 	o, ok := c.getObject(obj)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
+	if !ok {
+		return
+	}
+	key := o.GetNamespace() + "/" + o.GetName()
+
+	referencing := map[string]struct{}{}
+	proms, err := c.promInf.GetIndexer().ByIndex(secretRefIndex, key)
+	if err != nil {
+		c.logger.Log("msg", "looking up Prometheus objects referencing secret failed", "secret", key, "err", err)
+	}
+	for _, p := range proms {
+		if k, ok := c.keyFunc(p); ok {
+			referencing[k] = struct{}{}
+		}
 	}
+
+	smons, err := c.smonInf.GetIndexer().ByIndex(secretRefIndex, key)
+	if err != nil {
+		c.logger.Log("msg", "looking up ServiceMonitors referencing secret failed", "secret", key, "err", err)
+	}
+	var smonMetas []metav1.Object
+	for _, sm := range smons {
+		smonMetas = append(smonMetas, sm.(*v1alpha1.ServiceMonitor))
+	}
+
+	c.enqueueForNamespaceMatching("secret", o.GetNamespace(), func(p *v1alpha1.Prometheus) bool {
+		if k, ok := c.keyFunc(p); ok {
+			if _, ok := referencing[k]; ok {
+				return true
+			}
+		}
+		return selectsAny(p.Spec.ServiceMonitorSelector, smonMetas)
+	})
 }
 
-func (c *Operator) handleConfigMapUpdate(old, cur interface{}) {
-	o, ok := c.getObject(cur)
-	if ok {
-		c.enqueueForNamespace(o.GetNamespace())
+// enqueueForNamespaceMatching enqueues all Prometheus objects of the given
+// namespace for which match returns true.
+func (c *Operator) enqueueForNamespaceMatching(trigger, ns string, match func(p *v1alpha1.Prometheus) bool) {
+	objs, err := c.promInf.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		c.logger.Log("msg", "listing Prometheus objects of namespace failed", "namespace", ns, "err", err)
+		return
 	}
+
+	for _, obj := range objs {
+		p := obj.(*v1alpha1.Prometheus)
+		if !match(p) {
+			c.skippedEnqueues.WithLabelValues(trigger).Inc()
+			continue
+		}
+		c.enqueues.WithLabelValues(trigger).Inc()
+		c.enqueue(p)
+	}
+}
+
+// selectsAny checks whether the label selector matches any of the objects.
+// Invalid selectors match every object, so that the resulting error is
+// surfaced by the sync.
+func selectsAny(ls *metav1.LabelSelector, objs []metav1.Object) bool {
+	selector, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return true
+	}
+	for _, o := range objs {
+		if selector.Matches(labels.Set(o.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
+
+// prometheusSecretRefs indexes Prometheus objects by the Secrets they
+// reference directly.
+func prometheusSecretRefs(obj interface{}) ([]string, error) {
+	p, ok := obj.(*v1alpha1.Prometheus)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object of type %T", obj)
+	}
+
+	refs := []string{configSecretName(p.Name)}
+	refs = append(refs, p.Spec.Secrets...)
+	for _, r := range p.Spec.RemoteRead {
+		refs = append(refs, basicAuthSecretRefs(r.BasicAuth)...)
+	}
+	for _, r := range p.Spec.RemoteWrite {
+		refs = append(refs, basicAuthSecretRefs(r.BasicAuth)...)
+	}
+	return namespacedKeys(p.Namespace, refs), nil
+}
+
+// serviceMonitorSecretRefs indexes ServiceMonitors by the Secrets their
+// endpoints reference.
+func serviceMonitorSecretRefs(obj interface{}) ([]string, error) {
+	sm, ok := obj.(*v1alpha1.ServiceMonitor)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object of type %T", obj)
+	}
+
+	var refs []string
+	for _, ep := range sm.Spec.Endpoints {
+		refs = append(refs, basicAuthSecretRefs(ep.BasicAuth)...)
+	}
+	return namespacedKeys(sm.Namespace, refs), nil
+}
+
+func basicAuthSecretRefs(ba *v1alpha1.BasicAuth) []string {
+	if ba == nil {
+		return nil
+	}
+	return []string{ba.Username.Name, ba.Password.Name}
+}
+
+// namespacedKeys returns the deduplicated keys of the named objects in the
+// namespace.
+func namespacedKeys(ns string, names []string) []string {
+	seen := map[string]struct{}{}
+	keys := []string{}
+	for _, n := range names {
+		if _, ok := seen[n]; ok || n == "" {
+			continue
+		}
+		seen[n] = struct{}{}
+		keys = append(keys, ns+"/"+n)
+	}
+	return keys
 }
 
 func (c *Operator) getObject(obj interface{}) (metav1.Object, bool) {
@@ -484,6 +770,17 @@ func (c *Operator) getObject(obj interface{}) (metav1.Object, bool) {
 	return o, true
 }
 
+// getObjects returns the metadata of all objects that can be accessed.
+func (c *Operator) getObjects(objs []interface{}) []metav1.Object {
+	var res []metav1.Object
+	for _, obj := range objs {
+		if o, ok := c.getObject(obj); ok {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
 // enqueue adds a key to the queue. If obj is a key already it gets added directly.
 // Otherwise, the key is extracted via keyFunc.
 func (c *Operator) enqueue(obj interface{}) {
@@ -502,16 +799,6 @@ func (c *Operator) enqueue(obj interface{}) {
 	c.queue.Add(key)
 }
 
-// enqueueForNamespace enqueues all Prometheus object keys that belong to the given namespace.
-func (c *Operator) enqueueForNamespace(ns string) {
-	cache.ListAll(c.promInf.GetStore(), labels.Everything(), func(obj interface{}) {
-		p := obj.(*v1alpha1.Prometheus)
-		if p.Namespace == ns {
-			c.enqueue(p)
-		}
-	})
-}
-
 // worker runs a worker thread that just dequeues items, processes them, and marks them done.
 // It enforces that the syncHandler is never invoked concurrently with the same key.
 func (c *Operator) worker() {
@@ -544,6 +831,12 @@ func (c *Operator) prometheusForStatefulSet(sset interface{}) *v1alpha1.Promethe
 		return nil
 	}
 
+	// StatefulSets managed by another operator instance are ignored, even if
+	// this instance knows a Prometheus object of the same name.
+	if o, ok := c.getObject(sset); !ok || !IsOperatorInstance(o, c.config.OperatorInstance) {
+		return nil
+	}
+
 	promKey := statefulSetKeyToPrometheusKey(key)
 	p, exists, err := c.promInf.GetStore().GetByKey(promKey)
 	if err != nil {
@@ -617,6 +910,7 @@ func (c *Operator) sync(key string) error {
 		// Let's rely on the index key matching that of the created configmap and StatefulSet for now.
 		// This does not work if we delete Prometheus resources as the
 		// controller is not running – that could be solved via garbage collection later.
+		c.storageUsage.delete(key)
 		return c.destroyPrometheus(key)
 	}
 
@@ -627,6 +921,30 @@ func (c *Operator) sync(key string) error {
 
 	c.logger.Log("msg", "sync prometheus", "key", key)
 
+	version := p.Spec.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+	vp, verr := profileForVersion(version)
+	if verr == nil {
+		// Tuning fields the version has no flags for are reported like an
+		// unsupported version, as only a change of either resolves them.
+		_, verr = vp.tuningArgs(*p, version)
+	}
+	if err := c.updateVersionCondition(p, verr); err != nil {
+		return errors.Wrap(err, "updating version condition failed")
+	}
+	if verr != nil {
+		c.logger.Log("msg", "skipping prometheus with unsupported version or fields", "key", key, "err", verr)
+		return nil
+	}
+
+	// The admin API is enabled while snapshots of the Prometheus are
+	// scheduled, as they are taken through it.
+	if vp.snapshotPath != "" && c.isSnapshotted(p) {
+		p = withAdminAPI(p)
+	}
+
 	ruleFileConfigMaps, err := c.ruleFileConfigMaps(p)
 	if err != nil {
 		return errors.Wrap(err, "retrieving rule file configmaps failed")
@@ -646,8 +964,14 @@ func (c *Operator) sync(key string) error {
 	if err != nil {
 		return errors.Wrap(err, "generating empty config secret failed")
 	}
-	if _, err := c.kclient.Core().Secrets(p.Namespace).Create(s); err != nil && !apierrors.IsAlreadyExists(err) {
-		return errors.Wrap(err, "creating empty config file failed")
+	_, exists, err = c.secrInf.GetIndexer().GetByKey(p.Namespace + "/" + s.Name)
+	if err != nil {
+		return errors.Wrap(err, "retrieving config secret from cache failed")
+	}
+	if !exists {
+		if _, err := c.kclient.Core().Secrets(p.Namespace).Create(s); err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "creating empty config file failed")
+		}
 	}
 
 	// Create governing service if it doesn't exist.
@@ -656,7 +980,15 @@ func (c *Operator) sync(key string) error {
 		return errors.Wrap(err, "synchronizing governing service failed")
 	}
 
-	ssetClient := c.kclient.Apps().StatefulSets(p.Namespace)
+	if err := c.syncPodDisruptionBudget(p); err != nil {
+		return errors.Wrap(err, "synchronizing pod disruption budget failed")
+	}
+
+	if err := c.syncService(p); err != nil {
+		return errors.Wrap(err, "synchronizing service failed")
+	}
+
+	ssetClient := k8sutil.StatefulSets(c.kclient, p.Namespace, c.ssetGroupVersion)
 	// Ensure we have a StatefulSet running Prometheus deployed.
 	obj, exists, err = c.ssetInf.GetIndexer().GetByKey(prometheusKeyToStatefulSetKey(key))
 	if err != nil {
@@ -673,12 +1005,32 @@ func (c *Operator) sync(key string) error {
 		}
 		return nil
 	}
+	if o := obj.(*v1beta1.StatefulSet); !IsOperatorInstance(o, c.config.OperatorInstance) {
+		return fmt.Errorf("statefulset is managed by operator instance %q", o.Labels[OperatorInstanceLabel])
+	}
 	sset, err := makeStatefulSet(*p, obj.(*v1beta1.StatefulSet), &c.config, ruleFileConfigMaps)
 	if err != nil {
 		return errors.Wrap(err, "updating statefulset failed")
 	}
-	if _, err := ssetClient.Update(sset); err != nil {
-		return errors.Wrap(err, "updating statefulset failed")
+	if c.ssetGroupVersion != "" {
+		k8sutil.HoldPodTemplateChange(obj.(*v1beta1.StatefulSet), sset)
+	}
+	// Increased storage requests are applied to the existing persistent
+	// volume claims, as the volume claim templates can't be updated.
+	claimTemplates := sset.Spec.VolumeClaimTemplates
+	sset.Spec.VolumeClaimTemplates = k8sutil.KeepExpandedClaimTemplates(obj.(*v1beta1.StatefulSet).Spec.VolumeClaimTemplates, claimTemplates)
+	deleting, err := k8sutil.UpdateStatefulSet(ssetClient, obj.(*v1beta1.StatefulSet), sset, k8sutil.RecreateStatefulSetAllowed(p))
+	if err != nil {
+		return err
+	}
+	if deleting {
+		// The StatefulSet is created again once its deletion is observed.
+		c.logger.Log("msg", "recreating statefulset", "key", key)
+		return nil
+	}
+
+	if err := c.syncClaims(p, sset, claimTemplates); err != nil {
+		return errors.Wrap(err, "syncing persistent volume claims failed")
 	}
 
 	err = c.syncVersion(key, p)
@@ -689,6 +1041,161 @@ func (c *Operator) sync(key string) error {
 	return nil
 }
 
+// syncClaims expands the persistent volume claims of the StatefulSet to the
+// storage requests of the volume claim templates and records the progress in
+// the VolumesExpanded condition.
+func (c *Operator) syncClaims(p *v1alpha1.Prometheus, sset *v1beta1.StatefulSet, templates []v1.PersistentVolumeClaim) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	expandErr := k8sutil.ExpandClaims(c.kclient, sset, templates)
+	if expandErr != nil {
+		c.logger.Log("msg", "expanding persistent volume claims failed", "key", p.Namespace+"/"+p.Name, "err", expandErr)
+	}
+	pending, err := k8sutil.ClaimExpansions(c.kclient.CoreV1().PersistentVolumeClaims(p.Namespace), sset)
+	if err != nil {
+		return err
+	}
+	return c.updateVolumesCondition(p, k8sutil.VolumesExpandedCondition(pending, expandErr))
+}
+
+// collectStorageUsage periodically records the disk usage of the pods of all
+// Prometheus objects, so that neither syncs nor API requests wait for the
+// pods' metrics endpoints.
+func (c *Operator) collectStorageUsage(stopc <-chan struct{}) {
+	ticker := time.NewTicker(storageUsageInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopc:
+			return
+		case <-ticker.C:
+			for _, obj := range c.promInf.GetStore().List() {
+				key, ok := c.keyFunc(obj)
+				if !ok {
+					continue
+				}
+				c.updateStorageUsage(key, obj.(*v1alpha1.Prometheus))
+			}
+		}
+	}
+}
+
+// StorageUsage returns the last recorded disk usage of the pods of the
+// Prometheus object.
+func (c *Operator) StorageUsage(namespace, name string) []v1alpha1.StorageUsage {
+	return c.storageUsage.get(namespace + "/" + name)
+}
+
+// updateStorageUsage records the disk usage of the Prometheus pods and warns
+// about volumes that are almost full.
+func (c *Operator) updateStorageUsage(key string, p *v1alpha1.Prometheus) {
+	pods, err := c.podsForPrometheus(p)
+	if err != nil {
+		c.logger.Log("msg", "retrieving pods for storage usage failed", "key", key, "err", err)
+		return
+	}
+
+	usage := StorageUsage(p, pods)
+	for _, u := range usage {
+		if u.CapacityBytes > 0 && float64(u.UsedBytes) > storageWarningRatio*float64(u.CapacityBytes) {
+			c.logger.Log("msg", "storage of prometheus pod almost full", "key", key, "pod", u.Pod, "used_bytes", u.UsedBytes, "capacity_bytes", u.CapacityBytes)
+		}
+	}
+	c.storageUsage.set(key, p, usage)
+}
+
+// updateVersionCondition records on the Prometheus object whether its version
+// is supported. The condition is only recorded once a version was rejected,
+// as the operator does not maintain the status of Prometheus objects
+// otherwise.
+func (c *Operator) updateVersionCondition(p *v1alpha1.Prometheus, verr error) error {
+	if verr == nil && (p.Status == nil || !k8sutil.HasCondition(p.Status.Conditions, v1alpha1.ConditionVersionSupported)) {
+		return nil
+	}
+	return c.updateCondition(p, k8sutil.VersionSupportedCondition(verr))
+}
+
+// updateVolumesCondition records on the Prometheus object the progress of
+// expanding its persistent volume claims. The condition is only recorded once
+// claims were pending or failed to expand.
+func (c *Operator) updateVolumesCondition(p *v1alpha1.Prometheus, cond v1alpha1.Condition) error {
+	if cond.Status == v1.ConditionTrue && (p.Status == nil || !k8sutil.HasCondition(p.Status.Conditions, v1alpha1.ConditionVolumesExpanded)) {
+		return nil
+	}
+	return c.updateCondition(p, cond)
+}
+
+// updateCondition sets the condition in the status of the Prometheus object.
+// The remaining status, including the pending volume expansions, is computed
+// when a condition changes.
+func (c *Operator) updateCondition(p *v1alpha1.Prometheus, cond v1alpha1.Condition) error {
+	var conds []v1alpha1.Condition
+	if p.Status != nil {
+		conds = p.Status.Conditions
+	}
+	if _, changed := k8sutil.SetCondition(conds, cond); !changed {
+		return nil
+	}
+
+	cur, err := c.mclient.Prometheuses(p.Namespace).Get(p.Name)
+	if err != nil {
+		return err
+	}
+	status, _, err := PrometheusStatus(c.kclient, cur)
+	if err != nil {
+		status = &v1alpha1.PrometheusStatus{Paused: cur.Spec.Paused}
+	}
+	conds = nil
+	if cur.Status != nil {
+		conds = cur.Status.Conditions
+	}
+	status.Conditions, _ = k8sutil.SetCondition(conds, cond)
+	cur.Status = status
+
+	_, err = c.mclient.Prometheuses(p.Namespace).Update(cur)
+	return err
+}
+
+// syncService creates, updates or deletes the Service and Ingress of the
+// Prometheus object.
+func (c *Operator) syncService(p *v1alpha1.Prometheus) error {
+	name := prefixedName(p.Name)
+	sclient := c.kclient.CoreV1().Services(p.Namespace)
+	iclient := c.kclient.ExtensionsV1beta1().Ingresses(p.Namespace)
+
+	if p.Spec.Service == nil && p.Spec.Ingress == nil {
+		if err := k8sutil.DeleteIngress(iclient, name, c.isOperatorInstance); err != nil {
+			return err
+		}
+		return k8sutil.DeleteService(sclient, name, c.isOperatorInstance)
+	}
+
+	if err := k8sutil.CreateOrUpdateService(sclient, makeService(p, c.config)); err != nil {
+		return err
+	}
+	if p.Spec.Ingress == nil {
+		return k8sutil.DeleteIngress(iclient, name, c.isOperatorInstance)
+	}
+	ing, err := makeIngress(p, c.config)
+	if err != nil {
+		return err
+	}
+	return k8sutil.CreateOrUpdateIngress(iclient, ing)
+}
+
+// syncPodDisruptionBudget creates, updates or deletes the PodDisruptionBudget
+// of the Prometheus object.
+func (c *Operator) syncPodDisruptionBudget(p *v1alpha1.Prometheus) error {
+	pclient := c.kclient.PolicyV1beta1().PodDisruptionBudgets(p.Namespace)
+
+	pdb, err := makePodDisruptionBudget(p, c.config)
+	if err != nil {
+		return err
+	}
+	return k8sutil.SyncPodDisruptionBudget(pclient, ListOptions(p.Name), pdb)
+}
+
 func (c *Operator) ruleFileConfigMaps(p *v1alpha1.Prometheus) ([]*v1.ConfigMap, error) {
 	res := []*v1.ConfigMap{}
 
@@ -722,7 +1229,12 @@ func ListOptions(name string) metav1.ListOptions {
 //
 // TODO(fabxc): remove this once the StatefulSet controller learns how to do rolling updates.
 func (c *Operator) syncVersion(key string, p *v1alpha1.Prometheus) error {
-	status, oldPods, err := PrometheusStatus(c.kclient, p)
+	status, oldPods, err := c.prometheusStatus(p)
+	if err == errStatefulSetNotCached {
+		// The StatefulSet was just created and is not ready yet. Its add
+		// event enqueues the Prometheus object again.
+		return nil
+	}
 	if err != nil {
 		return errors.Wrap(err, "retrieving Prometheus status failed")
 	}
@@ -742,17 +1254,52 @@ func (c *Operator) syncVersion(key string, p *v1alpha1.Prometheus) error {
 	if len(oldPods) == 0 {
 		return nil
 	}
-	if status.UnavailableReplicas > 0 {
-		return fmt.Errorf("waiting for %d unavailable pods to become ready", status.UnavailableReplicas)
-	}
 
-	// TODO(fabxc): delete oldest pod first.
-	if err := c.kclient.Core().Pods(p.Namespace).Delete(oldPods[0].Name, nil); err != nil {
+	pods, err := c.podsForPrometheus(p)
+	if err != nil {
 		return err
 	}
+	webRoutePrefix := "/"
+	if p.Spec.RoutePrefix != "" {
+		webRoutePrefix = p.Spec.RoutePrefix
+	}
+	u := k8sutil.NewRollingUpdate(p.Spec.UpdateStrategy, 9090, path.Clean(webRoutePrefix+"/api/v1/query")+"?query=up")
+	if c.ssetGroupVersion != "" {
+		// The StatefulSet controller updates the pods from the highest
+		// ordinal down to the partition.
+		u.Strategy.Order = v1alpha1.UpdateOrderOrdinal
+	}
+	next, retryAfter, err := u.Next(int(expectedReplicas), pods, oldPods)
+	if err != nil {
+		return err
+	}
+	if len(next) == 0 {
+		c.queue.AddAfter(key, retryAfter)
+		return nil
+	}
+
+	if c.ssetGroupVersion != "" {
+		obj, exists, err := c.ssetInf.GetIndexer().GetByKey(prometheusKeyToStatefulSetKey(key))
+		if err != nil {
+			return errors.Wrap(err, "retrieving statefulset failed")
+		}
+		if !exists {
+			return fmt.Errorf("statefulset not found")
+		}
+		ssetClient := k8sutil.StatefulSets(c.kclient, p.Namespace, c.ssetGroupVersion)
+		if err := k8sutil.LowerPartition(ssetClient, obj.(*v1beta1.StatefulSet), next); err != nil {
+			return err
+		}
+	} else {
+		for _, pod := range next {
+			if err := c.kclient.Core().Pods(p.Namespace).Delete(pod.Name, nil); err != nil {
+				return err
+			}
+		}
+	}
 	// If there are further pods that need updating, we enqueue ourselves again.
-	if len(oldPods) > 1 {
-		return fmt.Errorf("%d out-of-date pods remaining", len(oldPods)-1)
+	if len(oldPods) > len(next) {
+		return fmt.Errorf("%d out-of-date pods remaining", len(oldPods)-len(next))
 	}
 	return nil
 }
@@ -761,8 +1308,6 @@ func (c *Operator) syncVersion(key string, p *v1alpha1.Prometheus) error {
 // to its specified resource object. It return the status and a list of pods that
 // are not updated.
 func PrometheusStatus(kclient kubernetes.Interface, p *v1alpha1.Prometheus) (*v1alpha1.PrometheusStatus, []v1.Pod, error) {
-	res := &v1alpha1.PrometheusStatus{Paused: p.Spec.Paused}
-
 	pods, err := kclient.Core().Pods(p.Namespace).List(ListOptions(p.Name))
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "retrieving pods of failed")
@@ -772,18 +1317,66 @@ func PrometheusStatus(kclient kubernetes.Interface, p *v1alpha1.Prometheus) (*v1
 		return nil, nil, errors.Wrap(err, "retrieving stateful set failed")
 	}
 
-	res.Replicas = int32(len(pods.Items))
+	res, oldPods, err := prometheusStatus(p, pods.Items, sset)
+	if err != nil {
+		return nil, nil, err
+	}
+	res.VolumeExpansions, err = k8sutil.ClaimExpansions(kclient.CoreV1().PersistentVolumeClaims(p.Namespace), sset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, oldPods, nil
+}
+
+// errStatefulSetNotCached is returned when the StatefulSet of a Prometheus
+// object has not been observed by the informer yet, e.g. right after creating it.
+var errStatefulSetNotCached = fmt.Errorf("stateful set not in cache yet")
+
+// prometheusStatus is like PrometheusStatus but retrieves the pods and the
+// StatefulSet from the informer caches.
+func (c *Operator) prometheusStatus(p *v1alpha1.Prometheus) (*v1alpha1.PrometheusStatus, []v1.Pod, error) {
+	obj, exists, err := c.ssetInf.GetIndexer().GetByKey(p.Namespace + "/" + statefulSetNameFromPrometheusName(p.Name))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "retrieving stateful set failed")
+	}
+	if !exists {
+		return nil, nil, errStatefulSetNotCached
+	}
+	pods, err := c.podsForPrometheus(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return prometheusStatus(p, pods, obj.(*v1beta1.StatefulSet))
+}
+
+// podsForPrometheus returns the cached pods of the Prometheus object.
+func (c *Operator) podsForPrometheus(p *v1alpha1.Prometheus) ([]v1.Pod, error) {
+	selector, err := labels.Parse(ListOptions(p.Name).LabelSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing pod selector failed")
+	}
+	var pods []v1.Pod
+	cache.ListAllByNamespace(c.podInf.GetIndexer(), p.Namespace, selector, func(obj interface{}) {
+		pods = append(pods, *obj.(*v1.Pod))
+	})
+	return pods, nil
+}
+
+func prometheusStatus(p *v1alpha1.Prometheus, pods []v1.Pod, sset *v1beta1.StatefulSet) (*v1alpha1.PrometheusStatus, []v1.Pod, error) {
+	res := &v1alpha1.PrometheusStatus{Paused: p.Spec.Paused}
+	res.Replicas = int32(len(pods))
+	res.RecreatePending = k8sutil.IsRecreatePending(sset)
 
 	var oldPods []v1.Pod
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		ready, err := k8sutil.PodRunningAndReady(pod)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "cannot determine pod ready state")
 		}
 		if ready {
 			res.AvailableReplicas++
-			// TODO(fabxc): detect other fields of the pod template that are mutable.
-			if needsUpdate(&pod, sset.Spec.Template) {
+			if k8sutil.PodNeedsUpdate(&pod, sset.Spec.Template) {
 				oldPods = append(oldPods, pod)
 			} else {
 				res.UpdatedReplicas++
@@ -796,22 +1389,15 @@ func PrometheusStatus(kclient kubernetes.Interface, p *v1alpha1.Prometheus) (*v1
 	return res, oldPods, nil
 }
 
-// needsUpdate checks whether the given pod conforms with the pod template spec
-// for various attributes that are influenced by the Prometheus TPR settings.
-func needsUpdate(pod *v1.Pod, tmpl v1.PodTemplateSpec) bool {
-	c1 := pod.Spec.Containers[0]
-	c2 := tmpl.Spec.Containers[0]
-
-	if c1.Image != c2.Image {
-		return true
-	}
-	if !reflect.DeepEqual(c1.Args, c2.Args) {
-		return true
-	}
-
-	return false
+// isOperatorInstance checks whether the object is managed by this operator
+// instance.
+func (c *Operator) isOperatorInstance(o metav1.Object) bool {
+	return IsOperatorInstance(o, c.config.OperatorInstance)
 }
 
+// destroyPrometheus deletes the objects created for a deleted Prometheus. The
+// objects are kept if they are managed by another operator instance, or the
+// Prometheus still exists.
 func (c *Operator) destroyPrometheus(key string) error {
 	ssetKey := prometheusKeyToStatefulSetKey(key)
 	obj, exists, err := c.ssetInf.GetStore().GetByKey(ssetKey)
@@ -822,6 +1408,18 @@ func (c *Operator) destroyPrometheus(key string) error {
 		return nil
 	}
 	sset := obj.(*v1beta1.StatefulSet)
+	if !c.isOperatorInstance(sset) {
+		c.logger.Log("msg", "skipping destruction, statefulset is managed by another operator instance", "key", key, "instance", sset.Labels[OperatorInstanceLabel])
+		return nil
+	}
+	// The Prometheus is only gone from the cache if it was handed over to
+	// another operator instance, or no longer matches the selector.
+	if _, err := c.mclient.Prometheuses(sset.Namespace).Get(prometheusNameFromStatefulSetName(sset.Name)); err == nil {
+		c.logger.Log("msg", "skipping destruction, Prometheus still exists", "key", key)
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Prometheus failed")
+	}
 	*sset.Spec.Replicas = 0
 
 	// Update the replica count to 0 and wait for all pods to be deleted.
@@ -851,6 +1449,24 @@ func (c *Operator) destroyPrometheus(key string) error {
 		return errors.Wrap(err, "deleting statefulset failed")
 	}
 
+	if k8sutil.DeleteClaimsOnDestroy(sset) {
+		if err := k8sutil.DeleteClaims(c.kclient.CoreV1().PersistentVolumeClaims(sset.Namespace), sset); err != nil {
+			return err
+		}
+	}
+
+	pclient := c.kclient.PolicyV1beta1().PodDisruptionBudgets(sset.Namespace)
+	if err := k8sutil.DeletePodDisruptionBudgets(pclient, ListOptions(prometheusNameFromStatefulSetName(sset.Name)), c.isOperatorInstance); err != nil {
+		return err
+	}
+
+	if err := k8sutil.DeleteIngress(c.kclient.ExtensionsV1beta1().Ingresses(sset.Namespace), sset.Name, c.isOperatorInstance); err != nil {
+		return err
+	}
+	if err := k8sutil.DeleteService(c.kclient.CoreV1().Services(sset.Namespace), sset.Name, c.isOperatorInstance); err != nil {
+		return err
+	}
+
 	// Delete the auto-generate configuration.
 	// TODO(fabxc): add an ownerRef at creation so we don't delete Secrets
 	// manually created for Prometheus servers with no ServiceMonitor selectors.
@@ -875,65 +1491,29 @@ func (c *Operator) destroyPrometheus(key string) error {
 }
 
 func (c *Operator) loadBasicAuthSecrets(
+	ns string,
 	mons map[string]*v1alpha1.ServiceMonitor,
 	remoteReads []v1alpha1.RemoteReadSpec,
 	remoteWrites []v1alpha1.RemoteWriteSpec,
-	s *v1.SecretList,
 ) (map[string]BasicAuthCredentials, error) {
 
 	secrets := map[string]BasicAuthCredentials{}
 
 	for _, mon := range mons {
-
 		for i, ep := range mon.Spec.Endpoints {
-
 			if ep.BasicAuth != nil {
-
-				var username string
-				var password string
-
-				for _, secret := range s.Items {
-
-					if secret.Name == ep.BasicAuth.Username.Name {
-
-						if u, ok := secret.Data[ep.BasicAuth.Username.Key]; ok {
-							username = string(u)
-						} else {
-							return nil, fmt.Errorf("Secret password of servicemonitor %s not found.", mon.Name)
-						}
-
-					}
-
-					if secret.Name == ep.BasicAuth.Password.Name {
-
-						if p, ok := secret.Data[ep.BasicAuth.Password.Key]; ok {
-							password = string(p)
-						} else {
-							return nil, fmt.Errorf("Secret username of servicemonitor %s not found.",
-								mon.Name)
-						}
-
-					}
+				credentials, err := c.loadBasicAuthSecret(mon.Namespace, ep.BasicAuth)
+				if err != nil {
+					return nil, fmt.Errorf("could not generate basicAuth for servicemonitor %s. %s", mon.Name, err)
 				}
-
-				if username == "" && password == "" {
-					return nil, fmt.Errorf("Could not generate basicAuth for servicemonitor %s. Username and password are empty.",
-						mon.Name)
-				} else {
-					secrets[fmt.Sprintf("%s/%s/%d", mon.Namespace, mon.Name, i)] =
-						BasicAuthCredentials{
-							username: username,
-							password: password,
-						}
-				}
-
+				secrets[fmt.Sprintf("%s/%s/%d", mon.Namespace, mon.Name, i)] = credentials
 			}
 		}
 	}
 
 	for i, remote := range remoteReads {
 		if remote.BasicAuth != nil {
-			credentials, err := loadBasicAuthSecret(remote.BasicAuth, s)
+			credentials, err := c.loadBasicAuthSecret(ns, remote.BasicAuth)
 			if err != nil {
 				return nil, fmt.Errorf("could not generate basicAuth for remote_read config %d. %s", i, err)
 			}
@@ -943,7 +1523,7 @@ func (c *Operator) loadBasicAuthSecrets(
 
 	for i, remote := range remoteWrites {
 		if remote.BasicAuth != nil {
-			credentials, err := loadBasicAuthSecret(remote.BasicAuth, s)
+			credentials, err := c.loadBasicAuthSecret(ns, remote.BasicAuth)
 			if err != nil {
 				return nil, fmt.Errorf("could not generate basicAuth for remote_write config %d. %s", i, err)
 			}
@@ -955,42 +1535,39 @@ func (c *Operator) loadBasicAuthSecrets(
 
 }
 
-func loadBasicAuthSecret(basicAuth *v1alpha1.BasicAuth, s *v1.SecretList) (BasicAuthCredentials, error) {
-	var username string
-	var password string
-
-	for _, secret := range s.Items {
-
-		if secret.Name == basicAuth.Username.Name {
-
-			if u, ok := secret.Data[basicAuth.Username.Key]; ok {
-				username = string(u)
-			} else {
-				return BasicAuthCredentials{}, fmt.Errorf("secret username key %q in secret %q not found", basicAuth.Username.Key, secret.Name)
-			}
-
-		}
-
-		if secret.Name == basicAuth.Password.Name {
-
-			if p, ok := secret.Data[basicAuth.Password.Key]; ok {
-				password = string(p)
-			} else {
-				return BasicAuthCredentials{}, fmt.Errorf("secret password key %q in secret %q not found", basicAuth.Password.Key, secret.Name)
-			}
-
-		}
-		if username != "" && password != "" {
-			break
-		}
+// loadBasicAuthSecret reads the credentials referenced by basicAuth from the
+// Secret cache. Only the referenced Secrets are looked up.
+func (c *Operator) loadBasicAuthSecret(ns string, basicAuth *v1alpha1.BasicAuth) (BasicAuthCredentials, error) {
+	username, err := c.loadSecretKey(ns, basicAuth.Username)
+	if err != nil {
+		return BasicAuthCredentials{}, errors.Wrap(err, "loading username failed")
+	}
+	password, err := c.loadSecretKey(ns, basicAuth.Password)
+	if err != nil {
+		return BasicAuthCredentials{}, errors.Wrap(err, "loading password failed")
 	}
 
 	if username == "" && password == "" {
-		return BasicAuthCredentials{}, fmt.Errorf("basic auth username and password secret not found")
+		return BasicAuthCredentials{}, fmt.Errorf("basic auth username and password are empty")
 	}
 
 	return BasicAuthCredentials{username: username, password: password}, nil
+}
 
+func (c *Operator) loadSecretKey(ns string, sel v1.SecretKeySelector) (string, error) {
+	obj, exists, err := c.secrInf.GetIndexer().GetByKey(ns + "/" + sel.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "retrieving secret %q failed", sel.Name)
+	}
+	if !exists {
+		return "", fmt.Errorf("secret %q not found", sel.Name)
+	}
+
+	v, ok := obj.(*v1.Secret).Data[sel.Key]
+	if !ok {
+		return "", fmt.Errorf("secret key %q in secret %q not found", sel.Key, sel.Name)
+	}
+	return string(v), nil
 }
 
 func (c *Operator) createConfig(p *v1alpha1.Prometheus, ruleFileConfigMaps []*v1.ConfigMap) error {
@@ -1001,14 +1578,7 @@ func (c *Operator) createConfig(p *v1alpha1.Prometheus, ruleFileConfigMaps []*v1
 
 	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
 
-	listSecrets, err := sClient.List(metav1.ListOptions{})
-
-	if err != nil {
-		return err
-	}
-
-	basicAuthSecrets, err := c.loadBasicAuthSecrets(smons, p.Spec.RemoteRead, p.Spec.RemoteWrite, listSecrets)
-
+	basicAuthSecrets, err := c.loadBasicAuthSecrets(p.Namespace, smons, p.Spec.RemoteRead, p.Spec.RemoteWrite)
 	if err != nil {
 		return err
 	}
@@ -1028,12 +1598,21 @@ func (c *Operator) createConfig(p *v1alpha1.Prometheus, ruleFileConfigMaps []*v1
 	}
 	s.Data[configFilename] = []byte(conf)
 
-	curSecret, err := sClient.Get(s.Name, metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
+	obj, exists, err := c.secrInf.GetIndexer().GetByKey(p.Namespace + "/" + s.Name)
+	if err != nil {
+		return errors.Wrap(err, "retrieving config secret from cache failed")
+	}
+	if !exists {
 		c.logger.Log("msg", "creating configuration")
 		_, err = sClient.Create(s)
+		if apierrors.IsAlreadyExists(err) {
+			// The secret is not cached yet, or it lacks the label required
+			// for caching as it was created by an earlier operator version.
+			_, err = sClient.Update(s)
+		}
 		return err
 	}
+	curSecret := obj.(*v1.Secret)
 
 	generatedConf := s.Data[configFilename]
 	generatedConfigMaps := s.Data[configMapsFilename]
@@ -1096,6 +1675,15 @@ func (c *Operator) createTPRs() error {
 			},
 			Description: "Managed Prometheus server",
 		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: tprPrometheusSnapshot,
+			},
+			Versions: []extensionsobj.APIVersion{
+				{Name: v1alpha1.TPRVersion},
+			},
+			Description: "Scheduled snapshots of a Prometheus server",
+		},
 	}
 	tprClient := c.kclient.Extensions().ThirdPartyResources()
 
@@ -1111,5 +1699,9 @@ func (c *Operator) createTPRs() error {
 	if err != nil {
 		return err
 	}
+	err = k8sutil.WaitForTPRReady(c.kclient.CoreV1().RESTClient(), v1alpha1.TPRGroup, v1alpha1.TPRVersion, v1alpha1.TPRPrometheusSnapshotName)
+	if err != nil {
+		return err
+	}
 	return k8sutil.WaitForTPRReady(c.kclient.CoreV1().RESTClient(), v1alpha1.TPRGroup, v1alpha1.TPRVersion, v1alpha1.TPRServiceMonitorName)
 }
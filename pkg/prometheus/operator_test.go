@@ -0,0 +1,167 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/go-kit/kit/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func basicAuth(username, password string) *v1alpha1.BasicAuth {
+	return &v1alpha1.BasicAuth{
+		Username: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: username}, Key: "username"},
+		Password: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: password}, Key: "password"},
+	}
+}
+
+func TestPrometheusSecretRefs(t *testing.T) {
+	p := &v1alpha1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "test"},
+		Spec: v1alpha1.PrometheusSpec{
+			Secrets:     []string{"tls", "prometheus-test"},
+			RemoteRead:  []v1alpha1.RemoteReadSpec{{BasicAuth: basicAuth("remote", "remote")}},
+			RemoteWrite: []v1alpha1.RemoteWriteSpec{{}},
+		},
+	}
+
+	refs, err := prometheusSecretRefs(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ns/prometheus-test", "ns/tls", "ns/remote"}
+	if !reflect.DeepEqual(expected, refs) {
+		t.Fatalf("expected secret refs %v, got %v", expected, refs)
+	}
+}
+
+func TestServiceMonitorSecretRefs(t *testing.T) {
+	sm := &v1alpha1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "test"},
+		Spec: v1alpha1.ServiceMonitorSpec{
+			Endpoints: []v1alpha1.Endpoint{
+				{Port: "web"},
+				{Port: "metrics", BasicAuth: basicAuth("user", "pass")},
+			},
+		},
+	}
+
+	refs, err := serviceMonitorSecretRefs(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ns/user", "ns/pass"}
+	if !reflect.DeepEqual(expected, refs) {
+		t.Fatalf("expected secret refs %v, got %v", expected, refs)
+	}
+}
+
+func TestSelectsAny(t *testing.T) {
+	objs := []metav1.Object{
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"role": "rulefile"}}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"role": "other"}}},
+	}
+
+	if !selectsAny(&metav1.LabelSelector{MatchLabels: map[string]string{"role": "rulefile"}}, objs) {
+		t.Fatal("expected selector to match")
+	}
+	if selectsAny(&metav1.LabelSelector{MatchLabels: map[string]string{"role": "none"}}, objs) {
+		t.Fatal("expected selector not to match")
+	}
+	if selectsAny(nil, objs) {
+		t.Fatal("expected nil selector not to match")
+	}
+}
+
+func TestDestroyPrometheusKeepsObjects(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		instance string
+		exists   bool
+	}{
+		{name: "other operator instance", instance: "stable"},
+		{name: "prometheus still exists", instance: "canary", exists: true},
+	} {
+		var (
+			mtx      sync.Mutex
+			requests []string
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			mtx.Unlock()
+			if r.Method == "GET" && c.exists {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"apiVersion":"monitoring.coreos.com/v1alpha1","kind":"Prometheus","metadata":{"namespace":"ns","name":"main"}}`))
+				return
+			}
+			http.NotFound(w, r)
+		}))
+
+		cfg := &rest.Config{Host: srv.URL}
+		kclient, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mclient, err := v1alpha1.NewForConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replicas := int32(1)
+		sset := &v1beta1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "prometheus-main",
+				Labels:    map[string]string{OperatorInstanceLabel: c.instance},
+			},
+			Spec: v1beta1.StatefulSetSpec{Replicas: &replicas},
+		}
+		o := &Operator{
+			kclient: kclient,
+			mclient: mclient,
+			logger:  log.NewNopLogger(),
+			ssetInf: cache.NewSharedIndexInformer(nil, &v1beta1.StatefulSet{}, 0, cache.Indexers{}),
+			config:  Config{OperatorInstance: "canary"},
+		}
+		if err := o.ssetInf.GetStore().Add(sset); err != nil {
+			t.Fatal(err)
+		}
+
+		err = o.destroyPrometheus("ns/main")
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		for _, r := range requests {
+			if r != "GET /apis/monitoring.coreos.com/v1alpha1/namespaces/ns/prometheuses/main" {
+				t.Errorf("%s: unexpected request %s", c.name, r)
+			}
+		}
+		if *sset.Spec.Replicas != 1 {
+			t.Errorf("%s: statefulset scaled down", c.name)
+		}
+	}
+}
@@ -20,8 +20,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/blang/semver"
-	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -56,9 +54,9 @@ func generateConfig(p *v1alpha1.Prometheus, mons map[string]*v1alpha1.ServiceMon
 		versionStr = DefaultVersion
 	}
 
-	version, err := semver.Parse(strings.TrimLeft(versionStr, "v"))
+	vp, err := profileForVersion(versionStr)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse version")
+		return nil, err
 	}
 
 	cfg := yaml.MapSlice{}
@@ -101,12 +99,12 @@ func generateConfig(p *v1alpha1.Prometheus, mons map[string]*v1alpha1.ServiceMon
 	var scrapeConfigs []yaml.MapSlice
 	for _, identifier := range identifiers {
 		for i, ep := range mons[identifier].Spec.Endpoints {
-			scrapeConfigs = append(scrapeConfigs, generateServiceMonitorConfig(version, mons[identifier], ep, i, basicAuthSecrets))
+			scrapeConfigs = append(scrapeConfigs, generateServiceMonitorConfig(vp, mons[identifier], ep, i, basicAuthSecrets))
 		}
 	}
 	var alertmanagerConfigs []yaml.MapSlice
 	for _, am := range p.Spec.Alerting.Alertmanagers {
-		alertmanagerConfigs = append(alertmanagerConfigs, generateAlertmanagerConfig(version, am))
+		alertmanagerConfigs = append(alertmanagerConfigs, generateAlertmanagerConfig(vp, am))
 	}
 
 	cfg = append(cfg, yaml.MapItem{
@@ -125,17 +123,17 @@ func generateConfig(p *v1alpha1.Prometheus, mons map[string]*v1alpha1.ServiceMon
 	})
 
 	if len(p.Spec.RemoteWrite) > 0 {
-		cfg = append(cfg, generateRemoteWriteConfig(version, p.Spec.RemoteWrite, basicAuthSecrets))
+		cfg = append(cfg, generateRemoteWriteConfig(p.Spec.RemoteWrite, basicAuthSecrets))
 	}
 
 	if len(p.Spec.RemoteRead) > 0 {
-		cfg = append(cfg, generateRemoteReadConfig(version, p.Spec.RemoteRead, basicAuthSecrets))
+		cfg = append(cfg, generateRemoteReadConfig(p.Spec.RemoteRead, basicAuthSecrets))
 	}
 
 	return yaml.Marshal(cfg)
 }
 
-func generateServiceMonitorConfig(version semver.Version, m *v1alpha1.ServiceMonitor, ep v1alpha1.Endpoint, i int, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapSlice {
+func generateServiceMonitorConfig(vp *versionProfile, m *v1alpha1.ServiceMonitor, ep v1alpha1.Endpoint, i int, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapSlice {
 	cfg := yaml.MapSlice{
 		{
 			Key:   "job_name",
@@ -147,15 +145,10 @@ func generateServiceMonitorConfig(version semver.Version, m *v1alpha1.ServiceMon
 		},
 	}
 
-	switch version.Major {
-	case 1:
-		if version.Minor < 7 {
-			cfg = append(cfg, k8sSDAllNamespaces())
-		} else {
-			cfg = append(cfg, k8sSDFromServiceMonitor(m))
-		}
-	case 2:
+	if vp.namespacedKubernetesSD {
 		cfg = append(cfg, k8sSDFromServiceMonitor(m))
+	} else {
+		cfg = append(cfg, k8sSDAllNamespaces())
 	}
 
 	if ep.Interval != "" {
@@ -237,7 +230,7 @@ func generateServiceMonitorConfig(version semver.Version, m *v1alpha1.ServiceMon
 		}
 	}
 
-	if version.Major == 1 && version.Minor < 7 {
+	if !vp.namespacedKubernetesSD {
 		// Filter targets based on the namespace selection configuration.
 		// By default we only discover services within the namespace of the
 		// ServiceMonitor.
@@ -393,7 +386,7 @@ func k8sSDAllNamespaces() yaml.MapItem {
 	}
 }
 
-func generateAlertmanagerConfig(version semver.Version, am v1alpha1.AlertmanagerEndpoints) yaml.MapSlice {
+func generateAlertmanagerConfig(vp *versionProfile, am v1alpha1.AlertmanagerEndpoints) yaml.MapSlice {
 	if am.Scheme == "" {
 		am.Scheme = "http"
 	}
@@ -407,15 +400,10 @@ func generateAlertmanagerConfig(version semver.Version, am v1alpha1.Alertmanager
 		{Key: "scheme", Value: am.Scheme},
 	}
 
-	switch version.Major {
-	case 1:
-		if version.Minor < 7 {
-			cfg = append(cfg, k8sSDAllNamespaces())
-		} else {
-			cfg = append(cfg, k8sSDWithNamespaces([]string{am.Namespace}))
-		}
-	case 2:
+	if vp.namespacedKubernetesSD {
 		cfg = append(cfg, k8sSDWithNamespaces([]string{am.Namespace}))
+	} else {
+		cfg = append(cfg, k8sSDAllNamespaces())
 	}
 
 	var relabelings []yaml.MapSlice
@@ -440,7 +428,7 @@ func generateAlertmanagerConfig(version semver.Version, am v1alpha1.Alertmanager
 		})
 	}
 
-	if version.Major == 1 && version.Minor < 7 {
+	if !vp.namespacedKubernetesSD {
 		relabelings = append(relabelings, yaml.MapSlice{
 			{Key: "action", Value: "keep"},
 			{Key: "source_labels", Value: []string{"__meta_kubernetes_namespace"}},
@@ -453,7 +441,7 @@ func generateAlertmanagerConfig(version semver.Version, am v1alpha1.Alertmanager
 	return cfg
 }
 
-func generateRemoteReadConfig(version semver.Version, specs []v1alpha1.RemoteReadSpec, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapItem {
+func generateRemoteReadConfig(specs []v1alpha1.RemoteReadSpec, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapItem {
 	cfgs := []yaml.MapSlice{}
 
 	for i, spec := range specs {
@@ -522,7 +510,7 @@ func addTLStoYaml(cfg yaml.MapSlice, tls *v1alpha1.TLSConfig) yaml.MapSlice {
 	return cfg
 }
 
-func generateRemoteWriteConfig(version semver.Version, specs []v1alpha1.RemoteWriteSpec, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapItem {
+func generateRemoteWriteConfig(specs []v1alpha1.RemoteWriteSpec, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapItem {
 
 	cfgs := []yaml.MapSlice{}
 
@@ -0,0 +1,175 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed cron schedule. Each field is a bit set of the values
+// it matches.
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Whether the day of the month or the day of the week is restricted. If
+	// both are, a day matching either one matches, as in cron.
+	domRestricted, dowRestricted bool
+}
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type scheduleField struct {
+	name     string
+	min, max int
+}
+
+var scheduleFields = []scheduleField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// parseSchedule parses a schedule in the five field cron format, with lists,
+// ranges and steps, or one of the descriptors like @daily.
+func parseSchedule(s string) (*schedule, error) {
+	if d, ok := scheduleDescriptors[strings.TrimSpace(s)]; ok {
+		s = d
+	}
+	parts := strings.Fields(s)
+	if len(parts) != len(scheduleFields) {
+		return nil, fmt.Errorf("schedule %q must have %d fields", s, len(scheduleFields))
+	}
+
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		b, err := parseScheduleField(part, scheduleFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s", s, err)
+		}
+		bits[i] = b
+	}
+	// Sunday may be given as 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &schedule{
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+func parseScheduleField(s string, f scheduleField) (uint64, error) {
+	var bits uint64
+	for _, term := range strings.Split(s, ",") {
+		rng, step := term, 1
+		if i := strings.Index(term, "/"); i >= 0 {
+			n, err := strconv.Atoi(term[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, term)
+			}
+			rng, step = term[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(rng[:i]); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, term)
+			}
+			if hi, err = strconv.Atoi(rng[i+1:]); err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, term)
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, term)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s %q out of range %d-%d", f.name, term, f.min, f.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func matches(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func (s *schedule) matchesDay(t time.Time) bool {
+	dom, dow := matches(s.dom, t.Day()), matches(s.dow, int(t.Weekday()))
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// next returns the first time matching the schedule after t, in UTC. The
+// zero time is returned if the schedule matches no time within five years,
+// e.g. for the 31st of February.
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+
+	for t.Before(end) {
+		if !matches(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !matches(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !matches(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
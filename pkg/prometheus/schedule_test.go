@@ -0,0 +1,64 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2017, 8, 1, 12, 30, 0, 0, time.UTC) // Tuesday
+
+	for _, c := range []struct {
+		schedule string
+		expected time.Time
+	}{
+		{schedule: "* * * * *", expected: time.Date(2017, 8, 1, 12, 31, 0, 0, time.UTC)},
+		{schedule: "0 */6 * * *", expected: time.Date(2017, 8, 1, 18, 0, 0, 0, time.UTC)},
+		{schedule: "15,45 * * * *", expected: time.Date(2017, 8, 1, 12, 45, 0, 0, time.UTC)},
+		{schedule: "0 3 * * 1-5", expected: time.Date(2017, 8, 2, 3, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 * * 7", expected: time.Date(2017, 8, 6, 0, 0, 0, 0, time.UTC)},
+		{schedule: "@daily", expected: time.Date(2017, 8, 2, 0, 0, 0, 0, time.UTC)},
+		{schedule: "@monthly", expected: time.Date(2017, 9, 1, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week match either one if both are set.
+		{schedule: "0 0 15 * 5", expected: time.Date(2017, 8, 4, 0, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 31 2 *", expected: time.Time{}},
+	} {
+		s, err := parseSchedule(c.schedule)
+		if err != nil {
+			t.Fatalf("schedule %q: %s", c.schedule, err)
+		}
+		if next := s.next(from); !next.Equal(c.expected) {
+			t.Fatalf("schedule %q: expected %s, got %s", c.schedule, c.expected, next)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 5-2 * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+	} {
+		if _, err := parseSchedule(s); err == nil {
+			t.Fatalf("expected error for schedule %q", s)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	batchclient "k8s.io/client-go/kubernetes/typed/batch/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	tprPrometheusSnapshot = "prometheus-snapshot." + v1alpha1.TPRGroup
+
+	// snapshotPrometheusIndex indexes PrometheusSnapshots by the key of the
+	// Prometheus they reference.
+	snapshotPrometheusIndex = "prometheus"
+
+	// snapshotTimeout bounds a snapshot request. Snapshots include the head
+	// block, which is written to disk first.
+	snapshotTimeout = time.Minute
+	// pruneCheckInterval is the interval in which the prune Jobs of a
+	// PrometheusSnapshot are checked while they run.
+	pruneCheckInterval = 15 * time.Second
+	// pruneRetryDelay is the time a failed prune Job is kept before it is
+	// replaced by a new attempt.
+	pruneRetryDelay = time.Hour
+
+	// snapshotLabel is set on the prune Jobs of a PrometheusSnapshot to the
+	// name of the PrometheusSnapshot.
+	snapshotLabel = "prometheus-snapshot"
+	// pruneSnapshotsAnnotation lists the snapshot directories a prune Job
+	// deletes.
+	pruneSnapshotsAnnotation = "prometheus-operator-prune-snapshots"
+	// snapshotsDir is the directory the prune Jobs mount the data volume at.
+	snapshotsDir = "/prometheus/snapshots"
+)
+
+// snapshotNameRE matches the names of the snapshot directories created by
+// Prometheus. Other names recorded in the status are not deleted.
+var snapshotNameRE = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z-]*$`)
+
+func snapshotPrometheus(obj interface{}) ([]string, error) {
+	s := obj.(*v1alpha1.PrometheusSnapshot)
+	return []string{s.Namespace + "/" + s.Spec.Prometheus}, nil
+}
+
+func (c *Operator) handleSnapshotAdd(obj interface{}) {
+	s := obj.(*v1alpha1.PrometheusSnapshot)
+	c.logger.Log("msg", "PrometheusSnapshot added", "key", s.Namespace+"/"+s.Name)
+	c.enqueueSnapshot(s)
+	c.enqueue(s.Namespace + "/" + s.Spec.Prometheus)
+}
+
+func (c *Operator) handleSnapshotUpdate(old, cur interface{}) {
+	o, s := old.(*v1alpha1.PrometheusSnapshot), cur.(*v1alpha1.PrometheusSnapshot)
+	c.enqueueSnapshot(s)
+	if o.Spec.Prometheus != s.Spec.Prometheus {
+		c.enqueue(o.Namespace + "/" + o.Spec.Prometheus)
+		c.enqueue(s.Namespace + "/" + s.Spec.Prometheus)
+	}
+}
+
+func (c *Operator) handleSnapshotDelete(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	s, ok := obj.(*v1alpha1.PrometheusSnapshot)
+	if !ok {
+		return
+	}
+	c.logger.Log("msg", "PrometheusSnapshot deleted", "key", s.Namespace+"/"+s.Name)
+	c.enqueueSnapshot(s)
+	c.enqueue(s.Namespace + "/" + s.Spec.Prometheus)
+}
+
+func (c *Operator) enqueueSnapshot(s *v1alpha1.PrometheusSnapshot) {
+	if key, ok := c.keyFunc(s); ok {
+		c.snapQueue.Add(key)
+	}
+}
+
+// isSnapshotted checks whether snapshots of the Prometheus are scheduled.
+func (c *Operator) isSnapshotted(p *v1alpha1.Prometheus) bool {
+	snaps, err := c.snapInf.GetIndexer().ByIndex(snapshotPrometheusIndex, p.Namespace+"/"+p.Name)
+	if err != nil {
+		c.logger.Log("msg", "looking up PrometheusSnapshots failed", "err", err)
+		return false
+	}
+	return len(snaps) > 0
+}
+
+// withAdminAPI returns a copy of the Prometheus with the admin API enabled.
+func withAdminAPI(p *v1alpha1.Prometheus) *v1alpha1.Prometheus {
+	res := *p
+	web := v1alpha1.WebSpec{}
+	if p.Spec.Web != nil {
+		web = *p.Spec.Web
+	}
+	web.EnableAdminAPI = true
+	res.Spec.Web = &web
+	return &res
+}
+
+func (c *Operator) snapshotWorker() {
+	for c.processNextSnapshot() {
+	}
+}
+
+func (c *Operator) processNextSnapshot() bool {
+	key, quit := c.snapQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.snapQueue.Done(key)
+
+	err := c.syncSnapshot(key.(string))
+	if err == nil {
+		c.snapQueue.Forget(key)
+		return true
+	}
+
+	utilruntime.HandleError(errors.Wrap(err, fmt.Sprintf("Sync %q failed", key)))
+	c.snapQueue.AddRateLimited(key)
+
+	return true
+}
+
+// syncSnapshot takes the snapshots of a PrometheusSnapshot that are due and
+// prunes the snapshots exceeding its retention. The PrometheusSnapshot is
+// enqueued again for its next scheduled time.
+func (c *Operator) syncSnapshot(key string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	obj, exists, err := c.snapInf.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return deletePruneJobs(c.kclient.BatchV1().Jobs(ns), name)
+	}
+	s := obj.(*v1alpha1.PrometheusSnapshot)
+
+	sched, err := parseSchedule(s.Spec.Schedule)
+	if err != nil {
+		c.logger.Log("msg", "skipping PrometheusSnapshot with invalid schedule", "key", key, "err", err)
+		return nil
+	}
+
+	pobj, exists, err := c.promInf.GetIndexer().GetByKey(ns + "/" + s.Spec.Prometheus)
+	if err != nil {
+		return errors.Wrap(err, "retrieving prometheus from cache failed")
+	}
+	if !exists {
+		return fmt.Errorf("prometheus %s not found", s.Spec.Prometheus)
+	}
+	p := pobj.(*v1alpha1.Prometheus)
+
+	version := p.Spec.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+	vp, err := profileForVersion(version)
+	if err != nil {
+		c.logger.Log("msg", "skipping PrometheusSnapshot of unsupported prometheus", "key", key, "err", err)
+		return nil
+	}
+	if vp.snapshotPath == "" {
+		c.logger.Log("msg", "skipping PrometheusSnapshot, prometheus version does not support snapshots", "key", key, "version", version)
+		return nil
+	}
+	if p.Spec.Storage == nil {
+		c.logger.Log("msg", "skipping PrometheusSnapshot, prometheus has no persistent storage", "key", key)
+		return nil
+	}
+
+	pods, err := c.podsForPrometheus(p)
+	if err != nil {
+		return err
+	}
+
+	status := v1alpha1.PrometheusSnapshotStatus{}
+	if s.Status != nil {
+		status = *s.Status
+		status.Snapshots = append([]v1alpha1.TSDBSnapshot(nil), s.Status.Snapshots...)
+	}
+	changed := false
+
+	now := time.Now()
+	last := s.CreationTimestamp.Time
+	if status.LastScheduleTime != nil {
+		last = status.LastScheduleTime.Time
+	}
+	var snapErr error
+	if next := sched.next(last); !next.IsZero() && !now.Before(next) {
+		var taken []v1alpha1.TSDBSnapshot
+		taken, snapErr = takeSnapshots(&http.Client{Timeout: snapshotTimeout}, p, vp, pods, now)
+		for _, t := range taken {
+			c.logger.Log("msg", "snapshot taken", "key", key, "pod", t.Pod, "snapshot", t.Name)
+		}
+		status.Snapshots = append(status.Snapshots, taken...)
+		t := metav1.NewTime(now)
+		status.LastScheduleTime = &t
+		changed = true
+	}
+
+	pruneAfter, pruned, err := c.pruneSnapshots(s, p, pods, &status, now)
+	if err != nil {
+		return errors.Wrap(err, "pruning snapshots failed")
+	}
+
+	if changed || pruned {
+		cur, err := c.mclient.PrometheusSnapshots(ns).Get(name)
+		if err != nil {
+			return errors.Wrap(err, "retrieving PrometheusSnapshot failed")
+		}
+		cur.Status = &status
+		if _, err := c.mclient.PrometheusSnapshots(ns).Update(cur); err != nil {
+			return errors.Wrap(err, "updating PrometheusSnapshot status failed")
+		}
+	}
+
+	if next := sched.next(now); !next.IsZero() {
+		d := next.Sub(now)
+		if pruneAfter > 0 && d > pruneAfter {
+			d = pruneAfter
+		}
+		c.snapQueue.AddAfter(key, d)
+	}
+
+	if snapErr != nil {
+		return errors.Wrap(snapErr, "taking snapshots failed")
+	}
+	return nil
+}
+
+// takeSnapshots takes a snapshot on each available Pod. Snapshots of all Pods
+// are attempted, the last error is returned.
+func takeSnapshots(client *http.Client, p *v1alpha1.Prometheus, vp *versionProfile, pods []v1.Pod, now time.Time) ([]v1alpha1.TSDBSnapshot, error) {
+	var (
+		res     []v1alpha1.TSDBSnapshot
+		lastErr error
+	)
+	for _, pod := range pods {
+		if ready, _ := k8sutil.PodRunningAndReady(pod); !ready || pod.Status.PodIP == "" {
+			lastErr = fmt.Errorf("pod %s is not available", pod.Name)
+			continue
+		}
+		name, err := takeSnapshot(client, podURL(p, pod, vp.snapshotPath))
+		if err != nil {
+			lastErr = errors.Wrapf(err, "snapshot of pod %s failed", pod.Name)
+			continue
+		}
+		res = append(res, v1alpha1.TSDBSnapshot{
+			Pod:  pod.Name,
+			Name: name,
+			Time: metav1.NewTime(now),
+		})
+	}
+	return res, lastErr
+}
+
+// takeSnapshot calls the snapshot endpoint of the admin API at the URL and
+// returns the name of the snapshot directory. The endpoint of Prometheus 2.0
+// returns the name at the top level, later versions wrap it in the data of
+// the v1 API response.
+func takeSnapshot(client *http.Client, u string) (string, error) {
+	resp, err := client.Post(u, "application/json", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("POST %s returned status %d", u, resp.StatusCode)
+	}
+
+	var r struct {
+		Name string `json:"name"`
+		Data struct {
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", errors.Wrap(err, "decoding snapshot response failed")
+	}
+	name := r.Name
+	if name == "" {
+		name = r.Data.Name
+	}
+	if name == "" {
+		return "", fmt.Errorf("snapshot response contains no name")
+	}
+	return name, nil
+}
+
+// pruneSnapshots deletes the snapshots of each Pod exceeding the retention
+// with a Job mounting the volume of the Pod, as Prometheus offers no API to
+// delete snapshots. Snapshots deleted by finished Jobs are removed from the
+// status. Failed Jobs are recorded in the SnapshotsPruned condition and kept
+// for pruneRetryDelay before they are attempted again. It returns the time
+// after which the Jobs should be checked again, zero if none are pending,
+// and whether the status changed.
+func (c *Operator) pruneSnapshots(s *v1alpha1.PrometheusSnapshot, p *v1alpha1.Prometheus, pods []v1.Pod, status *v1alpha1.PrometheusSnapshotStatus, now time.Time) (time.Duration, bool, error) {
+	jclient := c.kclient.BatchV1().Jobs(s.Namespace)
+	var (
+		checkAfter time.Duration
+		changed    bool
+	)
+	check := func(d time.Duration) {
+		if checkAfter == 0 || d < checkAfter {
+			checkAfter = d
+		}
+	}
+	setCondition := func(err error) {
+		var ok bool
+		status.Conditions, ok = k8sutil.SetCondition(status.Conditions, k8sutil.SnapshotsPrunedCondition(err))
+		changed = changed || ok
+	}
+
+	for _, pod := range pods {
+		jobName := pruneJobName(s.Name, pod.Name)
+		job, err := jclient.Get(jobName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return 0, false, errors.Wrap(err, "retrieving prune job failed")
+		}
+		if err == nil {
+			switch {
+			case job.Status.Succeeded > 0:
+				names := strings.Split(job.Annotations[pruneSnapshotsAnnotation], ",")
+				status.Snapshots = withoutSnapshots(status.Snapshots, pod.Name, names)
+				changed = true
+				setCondition(nil)
+			case job.Status.Failed > 0:
+				c.logger.Log("msg", "pruning snapshots failed", "pod", pod.Name, "job", jobName)
+				setCondition(fmt.Errorf("prune job %s of pod %s failed", jobName, pod.Name))
+				if d := jobFailureTime(job).Add(pruneRetryDelay).Sub(now); d > 0 {
+					check(d)
+					continue
+				}
+			default:
+				check(pruneCheckInterval)
+				continue
+			}
+			// The next Job is created once the finished one is deleted.
+			if err := deleteJob(jclient, jobName); err != nil {
+				return 0, false, err
+			}
+			check(pruneCheckInterval)
+			continue
+		}
+
+		names := snapshotsToPrune(status.Snapshots, pod.Name, int(s.Spec.Retention))
+		if len(names) == 0 {
+			continue
+		}
+		if _, err := jclient.Create(makePruneJob(s, p, pod, jobName, names)); err != nil && !apierrors.IsAlreadyExists(err) {
+			return 0, false, errors.Wrap(err, "creating prune job failed")
+		}
+		check(pruneCheckInterval)
+	}
+	return checkAfter, changed, nil
+}
+
+// jobFailureTime returns the time the Job was marked as failed, or the time
+// it was created if it has no failed condition.
+func jobFailureTime(job *batchv1.Job) time.Time {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == v1.ConditionTrue {
+			return c.LastTransitionTime.Time
+		}
+	}
+	return job.CreationTimestamp.Time
+}
+
+func pruneJobName(snapshot, pod string) string {
+	ordinal := pod[strings.LastIndex(pod, "-")+1:]
+	return fmt.Sprintf("%s-prune-%s", snapshot, ordinal)
+}
+
+// snapshotsToPrune returns the names of the oldest snapshots of the Pod
+// exceeding the retention. Snapshots are recorded oldest first.
+func snapshotsToPrune(snapshots []v1alpha1.TSDBSnapshot, pod string, retention int) []string {
+	if retention <= 0 {
+		return nil
+	}
+	var names []string
+	for _, s := range snapshots {
+		if s.Pod == pod && snapshotNameRE.MatchString(s.Name) {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) <= retention {
+		return nil
+	}
+	return names[:len(names)-retention]
+}
+
+// withoutSnapshots returns the snapshots without the named ones of the Pod.
+func withoutSnapshots(snapshots []v1alpha1.TSDBSnapshot, pod string, names []string) []v1alpha1.TSDBSnapshot {
+	deleted := map[string]bool{}
+	for _, n := range names {
+		deleted[n] = true
+	}
+	var res []v1alpha1.TSDBSnapshot
+	for _, s := range snapshots {
+		if s.Pod == pod && deleted[s.Name] {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// makePruneJob returns a Job deleting the snapshot directories from the volume
+// of the Pod. It runs on the node of the Pod, so that the volume can be
+// mounted alongside the Pod, and uses the Prometheus image for its shell
+// utilities.
+func makePruneJob(s *v1alpha1.PrometheusSnapshot, p *v1alpha1.Prometheus, pod v1.Pod, name string, snapshots []string) *batchv1.Job {
+	image := ""
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "prometheus" {
+			image = c.Image
+		}
+	}
+	command := []string{"rm", "-rf"}
+	for _, n := range snapshots {
+		command = append(command, path.Join(snapshotsDir, n))
+	}
+	labels := map[string]string{snapshotLabel: s.Name}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: map[string]string{pruneSnapshotsAnnotation: strings.Join(snapshots, ",")},
+		},
+		Spec: batchv1.JobSpec{
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					NodeName:         pod.Spec.NodeName,
+					RestartPolicy:    v1.RestartPolicyNever,
+					SecurityContext:  p.Spec.SecurityContext,
+					ImagePullSecrets: p.Spec.ImagePullSecrets,
+					Containers: []v1.Container{
+						{
+							Name:    "prune",
+							Image:   image,
+							Command: command,
+							VolumeMounts: []v1.VolumeMount{
+								{
+									Name:      "data",
+									MountPath: path.Dir(snapshotsDir),
+									SubPath:   subPathForStorage(p.Spec.Storage),
+								},
+							},
+						},
+					},
+					Volumes: []v1.Volume{
+						{
+							Name: "data",
+							VolumeSource: v1.VolumeSource{
+								PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+									ClaimName: volumeName(p.Name) + "-" + pod.Name,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func deleteJob(jclient batchclient.JobInterface, name string) error {
+	orphan := false
+	if err := jclient.Delete(name, &metav1.DeleteOptions{OrphanDependents: &orphan}); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "deleting job %s failed", name)
+	}
+	return nil
+}
+
+// deletePruneJobs deletes the prune Jobs of a deleted PrometheusSnapshot.
+func deletePruneJobs(jclient batchclient.JobInterface, snapshot string) error {
+	jobs, err := jclient.List(metav1.ListOptions{LabelSelector: snapshotLabel + "=" + snapshot})
+	if err != nil {
+		return errors.Wrap(err, "listing prune jobs failed")
+	}
+	for _, j := range jobs.Items {
+		if err := deleteJob(jclient, j.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
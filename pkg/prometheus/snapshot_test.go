@@ -0,0 +1,99 @@
+// Copyright 2017 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	batchv1 "k8s.io/client-go/pkg/apis/batch/v1"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	for _, body := range []string{
+		`{"name":"20171019T120000Z-6ef5c58a14c6c5c6"}`,
+		`{"status":"success","data":{"name":"20171019T120000Z-6ef5c58a14c6c5c6"}}`,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			fmt.Fprint(w, body)
+		}))
+
+		name, err := takeSnapshot(http.DefaultClient, srv.URL+snapshotPath)
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != "20171019T120000Z-6ef5c58a14c6c5c6" {
+			t.Fatalf("unexpected snapshot name %q for response %s", name, body)
+		}
+	}
+}
+
+func TestSnapshotsToPrune(t *testing.T) {
+	snapshots := []v1alpha1.TSDBSnapshot{
+		{Pod: "prometheus-main-0", Name: "a"},
+		{Pod: "prometheus-main-1", Name: "b"},
+		{Pod: "prometheus-main-0", Name: "c"},
+		{Pod: "prometheus-main-0", Name: "../d"},
+		{Pod: "prometheus-main-0", Name: "e"},
+	}
+
+	if names := snapshotsToPrune(snapshots, "prometheus-main-0", 1); !reflect.DeepEqual(names, []string{"a", "c"}) {
+		t.Fatalf("unexpected snapshots to prune %v", names)
+	}
+	if names := snapshotsToPrune(snapshots, "prometheus-main-1", 1); len(names) != 0 {
+		t.Fatalf("expected no snapshots to prune, got %v", names)
+	}
+	if names := snapshotsToPrune(snapshots, "prometheus-main-0", 0); len(names) != 0 {
+		t.Fatalf("expected all snapshots to be kept without retention, got %v", names)
+	}
+
+	res := withoutSnapshots(snapshots, "prometheus-main-0", []string{"a", "b", "c"})
+	if len(res) != 3 || res[0].Name != "b" || res[1].Name != "../d" || res[2].Name != "e" {
+		t.Fatalf("unexpected remaining snapshots %v", res)
+	}
+}
+
+func TestJobFailureTime(t *testing.T) {
+	created := time.Date(2017, 10, 19, 12, 0, 0, 0, time.UTC)
+	failed := created.Add(time.Minute)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+	}
+	if got := jobFailureTime(job); !got.Equal(created) {
+		t.Fatalf("expected creation time %s without failed condition, got %s", created, got)
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{
+		Type:               batchv1.JobFailed,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(failed),
+	}}
+	if got := jobFailureTime(job); !got.Equal(failed) {
+		t.Fatalf("expected failure time %s, got %s", failed, got)
+	}
+}
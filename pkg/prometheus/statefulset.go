@@ -20,17 +20,19 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"reflect"
 	"sort"
-	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	policy "k8s.io/client-go/pkg/apis/policy/v1beta1"
 
-	"github.com/blang/semver"
 	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -43,30 +45,23 @@ const (
 	configMapsFilename = "configmaps.json"
 )
 
+// WatchedLabel marks ConfigMaps and Secrets to be cached by the operator if
+// it only caches labeled objects.
+const WatchedLabel = "monitoring.coreos.com/watched"
+
+// OperatorInstanceLabel is set on generated objects to the name of the
+// operator instance managing them, if configured.
+const OperatorInstanceLabel = "prometheus-operator-instance"
+
 var (
 	minReplicas                 int32 = 1
 	managedByOperatorLabel            = "managed-by"
 	managedByOperatorLabelValue       = "prometheus-operator"
 	managedByOperatorLabels           = map[string]string{
 		managedByOperatorLabel: managedByOperatorLabelValue,
+		WatchedLabel:           "true",
 	}
 	probeTimeoutSeconds int32 = 3
-
-	CompatibilityMatrix = []string{
-		"v1.4.0",
-		"v1.4.1",
-		"v1.5.0",
-		"v1.5.1",
-		"v1.5.2",
-		"v1.5.3",
-		"v1.6.0",
-		"v1.6.1",
-		"v1.6.2",
-		"v1.6.3",
-		"v1.7.0",
-		"v1.7.1",
-		"v2.0.0-alpha.3",
-	}
 )
 
 func makeStatefulSet(p v1alpha1.Prometheus, old *v1beta1.StatefulSet, config *Config, ruleConfigMaps []*v1.ConfigMap) (*v1beta1.StatefulSet, error) {
@@ -102,7 +97,7 @@ func makeStatefulSet(p v1alpha1.Prometheus, old *v1beta1.StatefulSet, config *Co
 	statefulset := &v1beta1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        prefixedName(p.Name),
-			Labels:      p.ObjectMeta.Labels,
+			Labels:      OperatorInstanceLabels(p.ObjectMeta.Labels, config.OperatorInstance),
 			Annotations: p.ObjectMeta.Annotations,
 		},
 		Spec: *spec,
@@ -128,15 +123,40 @@ func makeStatefulSet(p v1alpha1.Prometheus, old *v1beta1.StatefulSet, config *Co
 		statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, pvcTemplate)
 	}
 
+	tmpl := &statefulset.Spec.Template
+	tmpl.Spec.Containers[0].VolumeMounts = append(tmpl.Spec.Containers[0].VolumeMounts, p.Spec.VolumeMounts...)
+	if err := k8sutil.CustomizePodTemplate(tmpl, p.Spec.PodMetadata, p.Spec.SecurityContext, p.Spec.InitContainers, p.Spec.Containers, p.Spec.Volumes); err != nil {
+		return nil, errors.Wrap(err, "customize pod template")
+	}
+
 	if old != nil {
 		statefulset.Annotations = old.Annotations
 
-		// mounted volumes are not reconciled as StatefulSets do not allow
-		// modification of the PodTemplate.
-		// TODO(brancz): remove this once StatefulSets allow modification of the
-		// PodTemplate.
-		statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = old.Spec.Template.Spec.Containers[0].VolumeMounts
-		statefulset.Spec.Template.Spec.Volumes = old.Spec.Template.Spec.Volumes
+		// Mounted volumes can't be modified in place on older Kubernetes
+		// versions. Unless the StatefulSet may be recreated, they are not
+		// reconciled and the StatefulSet is marked to report the pending change.
+		if !k8sutil.RecreateStatefulSetAllowed(&p) {
+			oldMounts := old.Spec.Template.Spec.Containers[0].VolumeMounts
+			oldVolumes := old.Spec.Template.Spec.Volumes
+			changed := !reflect.DeepEqual(statefulset.Spec.Template.Spec.Containers[0].VolumeMounts, oldMounts) ||
+				!reflect.DeepEqual(statefulset.Spec.Template.Spec.Volumes, oldVolumes)
+
+			statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = oldMounts
+			statefulset.Spec.Template.Spec.Volumes = oldVolumes
+			k8sutil.SetRecreatePending(statefulset, changed)
+		} else {
+			k8sutil.SetRecreatePending(statefulset, false)
+		}
+	}
+
+	var pvcRetentionPolicy v1alpha1.PVCRetentionPolicy
+	if storageSpec != nil {
+		pvcRetentionPolicy = storageSpec.PVCRetentionPolicy
+	}
+	k8sutil.SetPVCRetentionPolicy(statefulset, pvcRetentionPolicy)
+
+	if err := k8sutil.SetPodTemplateHash(statefulset); err != nil {
+		return nil, err
 	}
 	return statefulset, nil
 }
@@ -231,6 +251,43 @@ func makeConfigSecret(name string, configMaps []*v1.ConfigMap) (*v1.Secret, erro
 	}, nil
 }
 
+func makePodDisruptionBudget(p *v1alpha1.Prometheus, config Config) (*policy.PodDisruptionBudget, error) {
+	replicas := minReplicas
+	if p.Spec.Replicas != nil {
+		replicas = *p.Spec.Replicas
+	}
+	pdb, err := k8sutil.MakePodDisruptionBudget(prefixedName(p.Name), map[string]string{
+		"app":        "prometheus",
+		"prometheus": p.Name,
+	}, replicas, p.Spec.PodDisruptionBudget)
+	if pdb == nil || err != nil {
+		return nil, err
+	}
+	pdb.Labels = OperatorInstanceLabels(pdb.Labels, config.OperatorInstance)
+	return pdb, nil
+}
+
+func makeService(p *v1alpha1.Prometheus, config Config) *v1.Service {
+	svc := k8sutil.MakeService(prefixedName(p.Name), map[string]string{
+		"app":        "prometheus",
+		"prometheus": p.Name,
+	}, 9090, p.Spec.Service)
+	svc.Labels = OperatorInstanceLabels(svc.Labels, config.OperatorInstance)
+	return svc
+}
+
+func makeIngress(p *v1alpha1.Prometheus, config Config) (*extensions.Ingress, error) {
+	ing, err := k8sutil.MakeIngress(prefixedName(p.Name), map[string]string{
+		"app":        "prometheus",
+		"prometheus": p.Name,
+	}, p.Spec.ExternalURL, p.Spec.RoutePrefix, p.Spec.Ingress)
+	if err != nil {
+		return nil, err
+	}
+	ing.Labels = OperatorInstanceLabels(ing.Labels, config.OperatorInstance)
+	return ing, nil
+}
+
 func makeStatefulSetService(p *v1alpha1.Prometheus) *v1.Service {
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -261,83 +318,39 @@ func makeStatefulSetSpec(p v1alpha1.Prometheus, c *Config, ruleConfigMaps []*v1.
 	// Allow up to 10 minutes for clean termination.
 	terminationGracePeriod := int64(600)
 
-	versionStr := strings.TrimLeft(p.Spec.Version, "v")
-
-	version, err := semver.Parse(versionStr)
+	vp, err := profileForVersion(p.Spec.Version)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse version")
-	}
-
-	var promArgs []string
-
-	switch version.Major {
-	case 1:
-		promArgs = append(promArgs,
-			"-storage.local.retention="+p.Spec.Retention,
-			"-storage.local.num-fingerprint-mutexes=4096",
-			"-storage.local.path=/var/prometheus/data",
-			"-storage.local.chunk-encoding-version=2",
-			"-config.file=/etc/prometheus/config/prometheus.yaml",
-		)
-		// We attempt to specify decent storage tuning flags based on how much the
-		// requested memory can fit. The user has to specify an appropriate buffering
-		// in memory limits to catch increased memory usage during query bursts.
-		// More info: https://prometheus.io/docs/operating/storage/.
-		reqMem := p.Spec.Resources.Requests[v1.ResourceMemory]
-
-		if version.Minor < 6 {
-			// 1024 byte is the fixed chunk size. With increasing number of chunks actually
-			// in memory, overhead owed to their management, higher ingestion buffers, etc.
-			// increases.
-			// We are conservative for now an assume this to be 80% as the Kubernetes environment
-			// generally has a very high time series churn.
-			memChunks := reqMem.Value() / 1024 / 5
-
-			promArgs = append(promArgs,
-				"-storage.local.memory-chunks="+fmt.Sprintf("%d", memChunks),
-				"-storage.local.max-chunks-to-persist="+fmt.Sprintf("%d", memChunks/2),
-			)
-		} else {
-			// Leave 1/3 head room for other overhead.
-			promArgs = append(promArgs,
-				"-storage.local.target-heap-size="+fmt.Sprintf("%d", reqMem.Value()/3*2),
-			)
-		}
-	case 2:
-
-		// Prometheus 2.0 is in alpha and is highly experimental, and therefore
-		// flags and other things may change for the final release of 2.0. This
-		// section is also regarded as experimental until a Prometheus 2.0 stable
-		// has been released. These flags will be updated to work with every new
-		// 2.0 release until a stable release. These flags are taregeted at version
-		// v2.0.0-alpha.3, there is no guarantee that these flags will continue to
-		// work for any further version, this feature is experimental and developed
-		// on a best effort basis.
-
-		promArgs = append(promArgs,
-			"-config.file=/etc/prometheus/config/prometheus.yaml",
-			"-storage.tsdb.path=/var/prometheus/data",
-			"-storage.tsdb.retention="+p.Spec.Retention,
-		)
-	default:
-		return nil, errors.Errorf("unsupported Prometheus major version %s", version)
+		return nil, err
 	}
 
+	promArgs := []string{"config.file=/etc/prometheus/config/prometheus.yaml"}
+	promArgs = append(promArgs, vp.storageFlags(p)...)
+
 	if p.Spec.ExternalURL != "" {
-		promArgs = append(promArgs, "-web.external-url="+p.Spec.ExternalURL)
+		promArgs = append(promArgs, "web.external-url="+p.Spec.ExternalURL)
 	}
 
 	webRoutePrefix := "/"
 	if p.Spec.RoutePrefix != "" {
 		webRoutePrefix = p.Spec.RoutePrefix
 	}
-	promArgs = append(promArgs, "-web.route-prefix="+webRoutePrefix)
+	promArgs = append(promArgs, "web.route-prefix="+webRoutePrefix)
 
-	if version.Major == 2 {
-		for i, a := range promArgs {
-			promArgs[i] = "-" + a
-		}
+	if vp.enableLifecycle {
+		promArgs = append(promArgs, "web.enable-lifecycle")
+	}
+
+	tuningArgs, err := vp.tuningArgs(p, p.Spec.Version)
+	if err != nil {
+		return nil, err
+	}
+	promArgs = append(promArgs, tuningArgs...)
+
+	extraArgs, err := additionalArgs(promArgs, p.Spec.AdditionalArgs)
+	if err != nil {
+		return nil, err
 	}
+	promArgs = vp.flags(append(promArgs, extraArgs...)...)
 
 	localReloadURL := &url.URL{
 		Scheme: "http",
@@ -414,15 +427,27 @@ func makeStatefulSetSpec(p v1alpha1.Prometheus, c *Config, ruleConfigMaps []*v1.
 		"-rule-volume-dir=/etc/prometheus/rules",
 	}
 
-	probeHandler := v1.Handler{
+	livenessProbeHandler := v1.Handler{
 		HTTPGet: &v1.HTTPGetAction{
-			Path: path.Clean(webRoutePrefix + "/status"),
+			Path: path.Clean(webRoutePrefix + vp.livenessPath),
+			Port: intstr.FromString("web"),
+		},
+	}
+	readinessProbeHandler := v1.Handler{
+		HTTPGet: &v1.HTTPGetAction{
+			Path: path.Clean(webRoutePrefix + vp.readinessPath),
 			Port: intstr.FromString("web"),
 		},
 	}
 	return &v1beta1.StatefulSetSpec{
 		ServiceName: governingServiceName,
 		Replicas:    p.Spec.Replicas,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app":        "prometheus",
+				"prometheus": p.Name,
+			},
+		},
 		Template: v1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
 				Labels: map[string]string{
@@ -445,7 +470,7 @@ func makeStatefulSetSpec(p v1alpha1.Prometheus, c *Config, ruleConfigMaps []*v1.
 						Args:         promArgs,
 						VolumeMounts: promVolumeMounts,
 						LivenessProbe: &v1.Probe{
-							Handler: probeHandler,
+							Handler: livenessProbeHandler,
 							// For larger servers, restoring a checkpoint on startup may take quite a bit of time.
 							// Wait up to 5 minutes.
 							InitialDelaySeconds: 300,
@@ -454,7 +479,7 @@ func makeStatefulSetSpec(p v1alpha1.Prometheus, c *Config, ruleConfigMaps []*v1.
 							FailureThreshold:    10,
 						},
 						ReadinessProbe: &v1.Probe{
-							Handler:          probeHandler,
+							Handler:          readinessProbeHandler,
 							TimeoutSeconds:   probeTimeoutSeconds,
 							PeriodSeconds:    5,
 							FailureThreshold: 6,
@@ -484,6 +509,30 @@ func makeStatefulSetSpec(p v1alpha1.Prometheus, c *Config, ruleConfigMaps []*v1.
 	}, nil
 }
 
+// OperatorInstanceLabels returns a copy of labels with the operator instance
+// label set. The labels are returned as is if no instance is configured.
+func OperatorInstanceLabels(labels map[string]string, instance string) map[string]string {
+	if instance == "" {
+		return labels
+	}
+
+	res := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		res[k] = v
+	}
+	res[OperatorInstanceLabel] = instance
+	return res
+}
+
+// IsOperatorInstance checks whether the object is managed by the given
+// operator instance. Objects without the instance label are adopted by any
+// instance, so that objects created before an instance name was configured
+// keep being managed.
+func IsOperatorInstance(o metav1.Object, instance string) bool {
+	owner := o.GetLabels()[OperatorInstanceLabel]
+	return owner == "" || owner == instance
+}
+
 func configSecretName(name string) string {
 	return prefixedName(name)
 }
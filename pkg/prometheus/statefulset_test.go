@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/pkg/api/v1"
@@ -48,11 +49,38 @@ func TestStatefulSetLabelingAndAnnotations(t *testing.T) {
 
 	require.NoError(t, err)
 
-	if !reflect.DeepEqual(labels, sset.Labels) || !reflect.DeepEqual(annotations, sset.Annotations) {
+	expectedAnnotations := map[string]string{
+		"testannotation":                  "testannotationvalue",
+		k8sutil.PodTemplateHashAnnotation: sset.Spec.Template.Annotations[k8sutil.PodTemplateHashAnnotation],
+	}
+	if !reflect.DeepEqual(labels, sset.Labels) || !reflect.DeepEqual(expectedAnnotations, sset.Annotations) {
 		t.Fatal("Labels or Annotations are not properly being propagated to the StatefulSet")
 	}
 }
 
+func TestStatefulSetPodTemplateHash(t *testing.T) {
+	p := v1alpha1.Prometheus{}
+	sset, err := makeStatefulSet(p, nil, defaultTestConfig, []*v1.ConfigMap{})
+	require.NoError(t, err)
+
+	hash := sset.Spec.Template.Annotations[k8sutil.PodTemplateHashAnnotation]
+	if hash == "" || sset.Annotations[k8sutil.PodTemplateHashAnnotation] != hash {
+		t.Fatal("pod template hash is not set on the StatefulSet and its pod template")
+	}
+
+	p.Spec.Tolerations = []v1.Toleration{{Key: "a-taint-key", Operator: v1.TolerationOpExists}}
+	updated, err := makeStatefulSet(p, sset, defaultTestConfig, []*v1.ConfigMap{})
+	require.NoError(t, err)
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: sset.Spec.Template.Annotations}}
+	if k8sutil.PodNeedsUpdate(pod, sset.Spec.Template) {
+		t.Fatal("pod created from the current pod template must not need an update")
+	}
+	if !k8sutil.PodNeedsUpdate(pod, updated.Spec.Template) {
+		t.Fatal("pod created from an outdated pod template must need an update")
+	}
+}
+
 func TestStatefulSetTolerations(t *testing.T) {
 	tolerations := []v1.Toleration{
 		v1.Toleration{
@@ -340,3 +368,67 @@ func makeConfigMap() *v1.ConfigMap {
 
 	return res
 }
+
+func TestStatefulSetOperatorInstanceLabel(t *testing.T) {
+	labels := map[string]string{
+		"testlabel": "testlabelvalue",
+	}
+
+	sset, err := makeStatefulSet(v1alpha1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+	}, nil, &Config{OperatorInstance: "canary"}, []*v1.ConfigMap{})
+
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		"testlabel":           "testlabelvalue",
+		OperatorInstanceLabel: "canary",
+	}
+	if !reflect.DeepEqual(expected, sset.Labels) {
+		t.Fatalf("expected labels %v, got %v", expected, sset.Labels)
+	}
+	if _, ok := labels[OperatorInstanceLabel]; ok {
+		t.Fatal("operator instance label must not be added to the Prometheus object")
+	}
+	if !IsOperatorInstance(sset, "canary") || IsOperatorInstance(sset, "") {
+		t.Fatal("StatefulSet not attributed to the operator instance creating it")
+	}
+
+	// StatefulSets created before an instance name was configured are
+	// adopted by the event handlers and the sync alike.
+	sset, err = makeStatefulSet(v1alpha1.Prometheus{}, nil, defaultTestConfig, []*v1.ConfigMap{})
+	require.NoError(t, err)
+	if !IsOperatorInstance(sset, "canary") {
+		t.Fatal("StatefulSet without instance label not adopted")
+	}
+}
+
+func TestStatefulSetVolumeChangeRecreatePending(t *testing.T) {
+	p := v1alpha1.Prometheus{}
+	old, err := makeStatefulSet(p, nil, defaultTestConfig, []*v1.ConfigMap{})
+	require.NoError(t, err)
+
+	p.Spec.Secrets = []string{"etcd-certs"}
+	sset, err := makeStatefulSet(p, old, defaultTestConfig, []*v1.ConfigMap{})
+	require.NoError(t, err)
+
+	if !reflect.DeepEqual(sset.Spec.Template.Spec.Volumes, old.Spec.Template.Spec.Volumes) {
+		t.Fatal("volumes must not be changed unless recreating the StatefulSet is allowed")
+	}
+	if !k8sutil.IsRecreatePending(sset) {
+		t.Fatal("expected StatefulSet to be marked for recreation")
+	}
+
+	p.Annotations = map[string]string{k8sutil.RecreateStatefulSetAnnotation: "true"}
+	sset, err = makeStatefulSet(p, sset, defaultTestConfig, []*v1.ConfigMap{})
+	require.NoError(t, err)
+
+	if len(sset.Spec.Template.Spec.Volumes) != len(old.Spec.Template.Spec.Volumes)+1 {
+		t.Fatal("expected secret volume to be added")
+	}
+	if k8sutil.IsRecreatePending(sset) {
+		t.Fatal("expected recreation marker to be removed")
+	}
+}
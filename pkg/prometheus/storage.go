@@ -0,0 +1,206 @@
+// Copyright 2016 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const storageUsageTimeout = 3 * time.Second
+
+// storageUsageInterval is the interval at which the disk usage of the
+// Prometheus Pods is collected.
+const storageUsageInterval = time.Minute
+
+// storageWarningRatio is the share of the volume capacity above which a
+// warning about the disk usage of a Pod is logged.
+const storageWarningRatio = 0.9
+
+var (
+	descStorageUsedBytes = prometheus.NewDesc(
+		"prometheus_operator_prometheus_storage_used_bytes",
+		"Bytes used by the time series database of a Prometheus Pod.",
+		[]string{"namespace", "prometheus", "pod"}, nil,
+	)
+	descStorageCapacityBytes = prometheus.NewDesc(
+		"prometheus_operator_prometheus_storage_capacity_bytes",
+		"Bytes requested for the volume of a Prometheus Pod.",
+		[]string{"namespace", "prometheus", "pod"}, nil,
+	)
+)
+
+// StorageUsage returns the disk usage of the time series databases of the
+// available pods of the Prometheus. Pods whose usage can't be retrieved are
+// skipped, as are versions not reporting it.
+func StorageUsage(p *v1alpha1.Prometheus, pods []v1.Pod) []v1alpha1.StorageUsage {
+	return storageUsage(&http.Client{Timeout: storageUsageTimeout}, p, pods)
+}
+
+func storageUsage(client *http.Client, p *v1alpha1.Prometheus, pods []v1.Pod) []v1alpha1.StorageUsage {
+	version := p.Spec.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+	vp, err := profileForVersion(version)
+	if err != nil || len(vp.storageSizeMetrics) == 0 {
+		return nil
+	}
+
+	var capacity int64
+	if s := p.Spec.Storage; s != nil {
+		if q, ok := s.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+			capacity = q.Value()
+		}
+	}
+
+	var res []v1alpha1.StorageUsage
+	for _, pod := range pods {
+		if ready, _ := k8sutil.PodRunningAndReady(pod); !ready || pod.Status.PodIP == "" {
+			continue
+		}
+		used, err := usedBytes(client, podURL(p, pod, "/metrics"), vp.storageSizeMetrics)
+		if err != nil {
+			continue
+		}
+		res = append(res, v1alpha1.StorageUsage{
+			Pod:           pod.Name,
+			UsedBytes:     used,
+			CapacityBytes: capacity,
+		})
+	}
+	return res
+}
+
+// podURL returns the URL of the path relative to the route prefix of the
+// Prometheus on the Pod.
+func podURL(p *v1alpha1.Prometheus, pod v1.Pod, urlPath string) string {
+	webRoutePrefix := "/"
+	if p.Spec.RoutePrefix != "" {
+		webRoutePrefix = p.Spec.RoutePrefix
+	}
+	return "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(9090)) + path.Clean(webRoutePrefix+urlPath)
+}
+
+// usedBytes sums up the values of the given metrics exposed at the URL.
+func usedBytes(client *http.Client, u string, metrics []string) (int64, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s returned status %d", u, resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		sum   float64
+		found bool
+	)
+	for _, name := range metrics {
+		mf, ok := families[name]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			if g := m.GetGauge(); g != nil {
+				sum += g.GetValue()
+				found = true
+			} else if u := m.GetUntyped(); u != nil {
+				sum += u.GetValue()
+				found = true
+			}
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no storage size metrics exposed")
+	}
+	return int64(sum), nil
+}
+
+// storageUsageCollector caches and exposes the last observed disk usage of
+// the Prometheus Pods.
+type storageUsageCollector struct {
+	mtx   sync.Mutex
+	usage map[string]storageUsageEntry
+}
+
+type storageUsageEntry struct {
+	namespace, name string
+	usage           []v1alpha1.StorageUsage
+}
+
+func newStorageUsageCollector() *storageUsageCollector {
+	return &storageUsageCollector{usage: map[string]storageUsageEntry{}}
+}
+
+// set records the disk usage of the Prometheus with the given key.
+func (c *storageUsageCollector) set(key string, p *v1alpha1.Prometheus, usage []v1alpha1.StorageUsage) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.usage[key] = storageUsageEntry{namespace: p.Namespace, name: p.Name, usage: usage}
+}
+
+// get returns the disk usage of the Prometheus with the given key.
+func (c *storageUsageCollector) get(key string) []v1alpha1.StorageUsage {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.usage[key].usage
+}
+
+// delete removes the disk usage of the Prometheus with the given key.
+func (c *storageUsageCollector) delete(key string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.usage, key)
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *storageUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descStorageUsedBytes
+	ch <- descStorageCapacityBytes
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *storageUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, e := range c.usage {
+		for _, u := range e.usage {
+			ch <- prometheus.MustNewConstMetric(descStorageUsedBytes, prometheus.GaugeValue, float64(u.UsedBytes), e.namespace, e.name, u.Pod)
+			if u.CapacityBytes > 0 {
+				ch <- prometheus.MustNewConstMetric(descStorageCapacityBytes, prometheus.GaugeValue, float64(u.CapacityBytes), e.namespace, e.name, u.Pod)
+			}
+		}
+	}
+}
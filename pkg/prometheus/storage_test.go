@@ -0,0 +1,46 @@
+// Copyright 2016 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsedBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE prometheus_tsdb_storage_blocks_bytes gauge")
+		fmt.Fprintln(w, "prometheus_tsdb_storage_blocks_bytes 3000")
+		fmt.Fprintln(w, "# TYPE prometheus_tsdb_wal_storage_size_bytes gauge")
+		fmt.Fprintln(w, "prometheus_tsdb_wal_storage_size_bytes 500")
+		fmt.Fprintln(w, "# TYPE prometheus_tsdb_head_series gauge")
+		fmt.Fprintln(w, "prometheus_tsdb_head_series 100")
+	}))
+	defer srv.Close()
+
+	used, err := usedBytes(http.DefaultClient, srv.URL+"/metrics", tsdbStorageSizeMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 3500 {
+		t.Fatalf("expected 3500 used bytes, got %d", used)
+	}
+
+	if _, err := usedBytes(http.DefaultClient, srv.URL+"/metrics", []string{"prometheus_local_storage_persistence_urgency_score"}); err == nil {
+		t.Fatal("expected error if no storage size metric is exposed")
+	}
+}
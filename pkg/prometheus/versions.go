@@ -0,0 +1,464 @@
+// Copyright 2016 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// VersionRange is the range of versions from Min up to, but excluding, Max.
+// An empty bound leaves the range open on that side. Pre-releases come before
+// their release, so the range up to v2.0.0 contains v2.0.0-alpha.3.
+type VersionRange struct {
+	Min string
+	Max string
+}
+
+// Contains checks whether the version is within the range.
+func (r VersionRange) Contains(v semver.Version) bool {
+	if r.Min != "" && v.LT(mustParseVersion(r.Min)) {
+		return false
+	}
+	if r.Max != "" && v.GTE(mustParseVersion(r.Max)) {
+		return false
+	}
+	return true
+}
+
+func (r VersionRange) String() string {
+	switch {
+	case r.Min == "":
+		return "<" + r.Max
+	case r.Max == "":
+		return ">=" + r.Min
+	}
+	return fmt.Sprintf(">=%s <%s", r.Min, r.Max)
+}
+
+// ParseVersion parses a version with an optional "v" prefix.
+func ParseVersion(s string) (semver.Version, error) {
+	v, err := semver.Parse(strings.TrimLeft(s, "v"))
+	if err != nil {
+		return semver.Version{}, errors.Wrap(err, "parse version")
+	}
+	return v, nil
+}
+
+func mustParseVersion(s string) semver.Version {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// versionProfile describes how the Prometheus releases of a version range
+// are run and configured.
+type versionProfile struct {
+	versions VersionRange
+	// Releases of the range tested with the operator. They make up the
+	// CompatibilityMatrix.
+	releases []string
+	// Prefix of the command line flags.
+	flagPrefix string
+	// storageFlags returns the storage flags of the Prometheus, without the
+	// flag prefix.
+	storageFlags func(p v1alpha1.Prometheus) []string
+	// Paths of the liveness and readiness probes, relative to the route
+	// prefix.
+	livenessPath  string
+	readinessPath string
+	// Whether Kubernetes service discovery can be restricted to namespaces
+	// with the namespaces key of kubernetes_sd_configs.
+	namespacedKubernetesSD bool
+	// Whether the lifecycle endpoints, which the config reloader uses, must
+	// be enabled with a flag.
+	enableLifecycle bool
+	// Flags of the tuning fields of the spec supported by the versions, keyed
+	// by the path of the field.
+	tuningFlags map[string]string
+	// Metrics of Prometheus summing up to the disk usage of its time series
+	// database. Names differing between versions may all be listed.
+	storageSizeMetrics []string
+	// Path of the admin API endpoint creating a snapshot of the time series
+	// database, relative to the route prefix. Empty if snapshots are not
+	// supported.
+	snapshotPath string
+}
+
+var (
+	v1TuningFlags = map[string]string{
+		"query.timeout":        "query.timeout",
+		"query.maxConcurrency": "query.max-concurrency",
+		"query.lookbackDelta":  "query.staleness-delta",
+		"web.maxConnections":   "web.max-connections",
+		"web.readTimeout":      "web.read-timeout",
+	}
+	v2TuningFlags = map[string]string{
+		"query.timeout":         "query.timeout",
+		"query.maxConcurrency":  "query.max-concurrency",
+		"web.maxConnections":    "web.max-connections",
+		"web.readTimeout":       "web.read-timeout",
+		"tsdb.minBlockDuration": "storage.tsdb.min-block-duration",
+		"tsdb.maxBlockDuration": "storage.tsdb.max-block-duration",
+	}
+	v20TuningFlags = withFlags(v2TuningFlags, map[string]string{
+		"web.enableAdminAPI": "web.enable-admin-api",
+	})
+	v25TuningFlags = withFlags(v20TuningFlags, map[string]string{
+		"query.lookbackDelta": "query.lookback-delta",
+		"query.maxSamples":    "query.max-samples",
+	})
+	v27TuningFlags = withFlags(v25TuningFlags, map[string]string{
+		"retentionSize": "storage.tsdb.retention.size",
+	})
+	v211TuningFlags = withFlags(v27TuningFlags, map[string]string{
+		"tsdb.walCompression": "storage.tsdb.wal-compression",
+	})
+)
+
+// snapshotPath is the path of the snapshot endpoint of the admin API, which
+// moved to the v1 API with Prometheus 2.1.
+const snapshotPath = "/api/v1/admin/tsdb/snapshot"
+
+// tsdbStorageSizeMetrics are the metrics of the size of the persisted blocks
+// and the write-ahead log. The blocks metric was renamed in later releases.
+var tsdbStorageSizeMetrics = []string{
+	"prometheus_tsdb_storage_blocks_bytes_total",
+	"prometheus_tsdb_storage_blocks_bytes",
+	"prometheus_tsdb_wal_storage_size_bytes",
+}
+
+func withFlags(base, flags map[string]string) map[string]string {
+	res := make(map[string]string, len(base)+len(flags))
+	for k, v := range base {
+		res[k] = v
+	}
+	for k, v := range flags {
+		res[k] = v
+	}
+	return res
+}
+
+// versionProfiles are the profiles of all supported Prometheus versions,
+// ordered by version.
+var versionProfiles = []versionProfile{
+	{
+		versions:      VersionRange{Min: "v1.4.0", Max: "v1.6.0"},
+		releases:      []string{"v1.4.0", "v1.4.1", "v1.5.0", "v1.5.1", "v1.5.2", "v1.5.3"},
+		flagPrefix:    "-",
+		storageFlags:  localStorageFlags(false),
+		livenessPath:  "/status",
+		readinessPath: "/status",
+		tuningFlags:   v1TuningFlags,
+	},
+	{
+		versions:      VersionRange{Min: "v1.6.0", Max: "v1.7.0"},
+		releases:      []string{"v1.6.0", "v1.6.1", "v1.6.2", "v1.6.3"},
+		flagPrefix:    "-",
+		storageFlags:  localStorageFlags(true),
+		livenessPath:  "/status",
+		readinessPath: "/status",
+		tuningFlags:   v1TuningFlags,
+	},
+	{
+		versions:               VersionRange{Min: "v1.7.0", Max: "v2.0.0-alpha.0"},
+		releases:               []string{"v1.7.0", "v1.7.1"},
+		flagPrefix:             "-",
+		storageFlags:           localStorageFlags(true),
+		livenessPath:           "/status",
+		readinessPath:          "/status",
+		namespacedKubernetesSD: true,
+		tuningFlags:            v1TuningFlags,
+	},
+	{
+		// Pre-releases of Prometheus 2.0 are experimental and supported on a
+		// best effort basis. The flags are targeted at v2.0.0-alpha.3.
+		versions:               VersionRange{Min: "v2.0.0-alpha.0", Max: "v2.0.0"},
+		releases:               []string{"v2.0.0-alpha.3"},
+		flagPrefix:             "--",
+		storageFlags:           tsdbStorageFlags,
+		storageSizeMetrics:     tsdbStorageSizeMetrics,
+		livenessPath:           "/status",
+		readinessPath:          "/status",
+		namespacedKubernetesSD: true,
+		tuningFlags:            v2TuningFlags,
+	},
+	{
+		versions:               VersionRange{Min: "v2.0.0", Max: "v2.1.0"},
+		flagPrefix:             "--",
+		storageFlags:           tsdbStorageFlags,
+		storageSizeMetrics:     tsdbStorageSizeMetrics,
+		livenessPath:           "/-/healthy",
+		readinessPath:          "/-/ready",
+		namespacedKubernetesSD: true,
+		enableLifecycle:        true,
+		tuningFlags:            v20TuningFlags,
+		snapshotPath:           "/api/v2/admin/tsdb/snapshot",
+	},
+	{
+		versions:               VersionRange{Min: "v2.1.0", Max: "v2.5.0"},
+		flagPrefix:             "--",
+		storageFlags:           tsdbStorageFlags,
+		storageSizeMetrics:     tsdbStorageSizeMetrics,
+		livenessPath:           "/-/healthy",
+		readinessPath:          "/-/ready",
+		namespacedKubernetesSD: true,
+		enableLifecycle:        true,
+		tuningFlags:            v20TuningFlags,
+		snapshotPath:           snapshotPath,
+	},
+	{
+		versions:               VersionRange{Min: "v2.5.0", Max: "v2.7.0"},
+		flagPrefix:             "--",
+		storageFlags:           tsdbStorageFlags,
+		storageSizeMetrics:     tsdbStorageSizeMetrics,
+		livenessPath:           "/-/healthy",
+		readinessPath:          "/-/ready",
+		namespacedKubernetesSD: true,
+		enableLifecycle:        true,
+		tuningFlags:            v25TuningFlags,
+		snapshotPath:           snapshotPath,
+	},
+	{
+		versions:               VersionRange{Min: "v2.7.0", Max: "v2.11.0"},
+		flagPrefix:             "--",
+		storageFlags:           tsdbStorageFlags,
+		storageSizeMetrics:     tsdbStorageSizeMetrics,
+		livenessPath:           "/-/healthy",
+		readinessPath:          "/-/ready",
+		namespacedKubernetesSD: true,
+		enableLifecycle:        true,
+		tuningFlags:            v27TuningFlags,
+		snapshotPath:           snapshotPath,
+	},
+	{
+		versions:               VersionRange{Min: "v2.11.0", Max: "v3.0.0"},
+		flagPrefix:             "--",
+		storageFlags:           tsdbStorageFlags,
+		storageSizeMetrics:     tsdbStorageSizeMetrics,
+		livenessPath:           "/-/healthy",
+		readinessPath:          "/-/ready",
+		namespacedKubernetesSD: true,
+		enableLifecycle:        true,
+		tuningFlags:            v211TuningFlags,
+		snapshotPath:           snapshotPath,
+	},
+}
+
+// CompatibilityMatrix lists the Prometheus releases tested with the operator.
+var CompatibilityMatrix = compatibilityMatrix()
+
+func compatibilityMatrix() []string {
+	res := []string{}
+	for _, p := range versionProfiles {
+		res = append(res, p.releases...)
+	}
+	return res
+}
+
+// UnsupportedVersionError is returned for Prometheus versions without a
+// version profile.
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	ranges := make([]string, 0, len(versionProfiles))
+	for _, p := range versionProfiles {
+		ranges = append(ranges, p.versions.String())
+	}
+	return fmt.Sprintf("unsupported Prometheus version %s, supported versions are %s", e.Version, strings.Join(ranges, ", "))
+}
+
+// UnsupportedFieldError is returned for tuning fields of the spec that the
+// Prometheus version has no flag for.
+type UnsupportedFieldError struct {
+	Field   string
+	Version string
+}
+
+func (e *UnsupportedFieldError) Error() string {
+	return fmt.Sprintf("%s is not supported by Prometheus %s", e.Field, e.Version)
+}
+
+// profileForVersion returns the version profile of the Prometheus version.
+func profileForVersion(s string) (*versionProfile, error) {
+	v, err := ParseVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versionProfiles {
+		if versionProfiles[i].versions.Contains(v) {
+			return &versionProfiles[i], nil
+		}
+	}
+	return nil, &UnsupportedVersionError{Version: s}
+}
+
+// flags returns the flags with the flag prefix of the profile.
+func (vp *versionProfile) flags(flags ...string) []string {
+	res := make([]string, 0, len(flags))
+	for _, f := range flags {
+		res = append(res, vp.flagPrefix+f)
+	}
+	return res
+}
+
+func localStorageFlags(targetHeapSize bool) func(p v1alpha1.Prometheus) []string {
+	return func(p v1alpha1.Prometheus) []string {
+		flags := []string{
+			"storage.local.retention=" + p.Spec.Retention,
+			"storage.local.num-fingerprint-mutexes=4096",
+			"storage.local.path=/var/prometheus/data",
+			"storage.local.chunk-encoding-version=2",
+		}
+		// We attempt to specify decent storage tuning flags based on how much the
+		// requested memory can fit. The user has to specify an appropriate buffering
+		// in memory limits to catch increased memory usage during query bursts.
+		// More info: https://prometheus.io/docs/operating/storage/.
+		reqMem := p.Spec.Resources.Requests[v1.ResourceMemory]
+
+		if !targetHeapSize {
+			// 1024 byte is the fixed chunk size. With increasing number of chunks actually
+			// in memory, overhead owed to their management, higher ingestion buffers, etc.
+			// increases.
+			// We are conservative for now an assume this to be 80% as the Kubernetes environment
+			// generally has a very high time series churn.
+			memChunks := reqMem.Value() / 1024 / 5
+
+			return append(flags,
+				"storage.local.memory-chunks="+fmt.Sprintf("%d", memChunks),
+				"storage.local.max-chunks-to-persist="+fmt.Sprintf("%d", memChunks/2),
+			)
+		}
+		// Leave 1/3 head room for other overhead.
+		return append(flags,
+			"storage.local.target-heap-size="+fmt.Sprintf("%d", reqMem.Value()/3*2),
+		)
+	}
+}
+
+func tsdbStorageFlags(p v1alpha1.Prometheus) []string {
+	return []string{
+		"storage.tsdb.path=/var/prometheus/data",
+		"storage.tsdb.retention=" + p.Spec.Retention,
+	}
+}
+
+// ownedFlags are set by the operator and may not be passed as additional
+// arguments, even if they are not set for the version.
+var ownedFlags = map[string]bool{
+	"config.file":        true,
+	"storage.local.path": true,
+	"storage.tsdb.path":  true,
+	"web.route-prefix":   true,
+}
+
+// tuningArgs returns the flags of the tuning fields set in the spec, without
+// the flag prefix. The version is the one the profile was picked for, which
+// is reported for unsupported fields.
+func (vp *versionProfile) tuningArgs(p v1alpha1.Prometheus, version string) ([]string, error) {
+	type fieldValue struct {
+		field, value string
+	}
+	var fields []fieldValue
+	set := func(field, value string) {
+		fields = append(fields, fieldValue{field: field, value: value})
+	}
+
+	if p.Spec.RetentionSize != "" {
+		set("retentionSize", p.Spec.RetentionSize)
+	}
+	if q := p.Spec.Query; q != nil {
+		if q.Timeout != "" {
+			set("query.timeout", q.Timeout)
+		}
+		if q.MaxConcurrency != nil {
+			set("query.maxConcurrency", fmt.Sprintf("%d", *q.MaxConcurrency))
+		}
+		if q.LookbackDelta != "" {
+			set("query.lookbackDelta", q.LookbackDelta)
+		}
+		if q.MaxSamples != nil {
+			set("query.maxSamples", fmt.Sprintf("%d", *q.MaxSamples))
+		}
+	}
+	if w := p.Spec.Web; w != nil {
+		if w.EnableAdminAPI {
+			set("web.enableAdminAPI", "")
+		}
+		if w.MaxConnections != nil {
+			set("web.maxConnections", fmt.Sprintf("%d", *w.MaxConnections))
+		}
+		if w.ReadTimeout != "" {
+			set("web.readTimeout", w.ReadTimeout)
+		}
+	}
+	if t := p.Spec.TSDB; t != nil {
+		if t.MinBlockDuration != "" {
+			set("tsdb.minBlockDuration", t.MinBlockDuration)
+		}
+		if t.MaxBlockDuration != "" {
+			set("tsdb.maxBlockDuration", t.MaxBlockDuration)
+		}
+		if t.WALCompression {
+			set("tsdb.walCompression", "")
+		}
+	}
+
+	args := make([]string, 0, len(fields))
+	for _, f := range fields {
+		name, ok := vp.tuningFlags[f.field]
+		if !ok {
+			return nil, &UnsupportedFieldError{Field: f.field, Version: version}
+		}
+		args = append(args, argument(name, f.value))
+	}
+	return args, nil
+}
+
+// additionalArgs returns the additional arguments of the spec, without the
+// flag prefix. Arguments overriding one of the given flags or an owned flag
+// are rejected.
+func additionalArgs(args []string, additional []v1alpha1.Argument) ([]string, error) {
+	set := map[string]bool{}
+	for _, a := range args {
+		set[strings.SplitN(a, "=", 2)[0]] = true
+	}
+
+	res := make([]string, 0, len(additional))
+	for _, a := range additional {
+		name := strings.TrimLeft(a.Name, "-")
+		if ownedFlags[name] || set[name] {
+			return nil, fmt.Errorf("additional argument %s overrides a flag set by the operator", name)
+		}
+		res = append(res, argument(name, a.Value))
+	}
+	return res, nil
+}
+
+func argument(name, value string) string {
+	if value == "" {
+		return name
+	}
+	return name + "=" + value
+}
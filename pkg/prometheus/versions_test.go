@@ -0,0 +1,173 @@
+// Copyright 2016 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProfileForVersion(t *testing.T) {
+	for _, c := range []struct {
+		version     string
+		min         string
+		unsupported bool
+	}{
+		{version: "v1.4.0", min: "v1.4.0"},
+		{version: "v1.5.3", min: "v1.4.0"},
+		{version: "v1.7.1", min: "v1.7.0"},
+		{version: "v2.0.0-alpha.3", min: "v2.0.0-alpha.0"},
+		{version: "v2.0.0-beta.2", min: "v2.0.0-alpha.0"},
+		{version: "v2.0.0", min: "v2.0.0"},
+		{version: "v1.3.1", unsupported: true},
+		{version: "v3.0.0", unsupported: true},
+	} {
+		vp, err := profileForVersion(c.version)
+		if c.unsupported {
+			if _, ok := err.(*UnsupportedVersionError); !ok {
+				t.Fatalf("%s: expected unsupported version error, got %v", c.version, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", c.version, err)
+		}
+		if vp.versions.Min != c.min {
+			t.Fatalf("%s: expected profile starting at %s, got %s", c.version, c.min, vp.versions)
+		}
+	}
+}
+
+func TestCompatibilityMatrixVersionsSupported(t *testing.T) {
+	for _, v := range CompatibilityMatrix {
+		if _, err := profileForVersion(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStatefulSetVersionProfile(t *testing.T) {
+	sset, err := makeStatefulSet(v1alpha1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: v1alpha1.PrometheusSpec{
+			Version:     "v2.0.0",
+			RoutePrefix: "/prometheus",
+		},
+	}, nil, defaultTestConfig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := sset.Spec.Template.Spec.Containers[0]
+	expected := []string{
+		"--config.file=/etc/prometheus/config/prometheus.yaml",
+		"--storage.tsdb.path=/var/prometheus/data",
+		"--storage.tsdb.retention=24h",
+		"--web.route-prefix=/prometheus",
+		"--web.enable-lifecycle",
+	}
+	if !reflect.DeepEqual(c.Args, expected) {
+		t.Fatalf("expected args %v, got %v", expected, c.Args)
+	}
+	if p := c.LivenessProbe.HTTPGet.Path; p != "/prometheus/-/healthy" {
+		t.Fatalf("unexpected liveness probe path %s", p)
+	}
+	if p := c.ReadinessProbe.HTTPGet.Path; p != "/prometheus/-/ready" {
+		t.Fatalf("unexpected readiness probe path %s", p)
+	}
+
+	_, err = makeStatefulSet(v1alpha1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       v1alpha1.PrometheusSpec{Version: "v0.18.0"},
+	}, nil, defaultTestConfig, nil)
+	if _, ok := errors.Cause(err).(*UnsupportedVersionError); !ok {
+		t.Fatalf("expected unsupported version error, got %v", err)
+	}
+}
+
+func TestStatefulSetTuningArgs(t *testing.T) {
+	maxConcurrency := int32(10)
+	spec := v1alpha1.PrometheusSpec{
+		Query: &v1alpha1.QuerySpec{
+			Timeout:        "1m",
+			MaxConcurrency: &maxConcurrency,
+			LookbackDelta:  "3m",
+		},
+		Web: &v1alpha1.WebSpec{EnableAdminAPI: true},
+		TSDB: &v1alpha1.TSDBSpec{
+			WALCompression: true,
+		},
+		AdditionalArgs: []v1alpha1.Argument{
+			{Name: "storage.remote.flush-deadline", Value: "2m"},
+		},
+	}
+
+	for _, c := range []struct {
+		version  string
+		expected []string
+		err      bool
+	}{
+		{
+			version: "v2.11.0",
+			expected: []string{
+				"--query.timeout=1m",
+				"--query.max-concurrency=10",
+				"--query.lookback-delta=3m",
+				"--web.enable-admin-api",
+				"--storage.tsdb.wal-compression",
+				"--storage.remote.flush-deadline=2m",
+			},
+		},
+		{version: "v2.10.0", err: true},
+		{version: "v1.7.1", err: true},
+	} {
+		// The spec leaves the version to the default, the error reports
+		// the version the profile was picked for.
+		vp, err := profileForVersion(c.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, err := vp.tuningArgs(v1alpha1.Prometheus{Spec: spec}, c.version)
+		if c.err {
+			if ferr, ok := err.(*UnsupportedFieldError); !ok || ferr.Version != c.version {
+				t.Fatalf("%s: expected error for unsupported fields, got %v", c.version, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", c.version, err)
+		}
+		extra, err := additionalArgs(args, spec.AdditionalArgs)
+		if err != nil {
+			t.Fatalf("%s: %s", c.version, err)
+		}
+		if args = vp.flags(append(args, extra...)...); !reflect.DeepEqual(args, c.expected) {
+			t.Fatalf("%s: expected args %v, got %v", c.version, c.expected, args)
+		}
+	}
+}
+
+func TestAdditionalArgsOwnedFlags(t *testing.T) {
+	args := []string{"config.file=/etc/prometheus/config/prometheus.yaml", "query.timeout=1m"}
+	for _, name := range []string{"config.file", "--storage.tsdb.path", "web.route-prefix", "query.timeout"} {
+		if _, err := additionalArgs(args, []v1alpha1.Argument{{Name: name, Value: "x"}}); err == nil {
+			t.Fatalf("expected %s to be rejected", name)
+		}
+	}
+}
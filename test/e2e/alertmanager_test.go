@@ -21,12 +21,15 @@ import (
 	"testing"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/apis/extensions/v1beta1"
 
+	"github.com/coreos/prometheus-operator/pkg/client/monitoring/v1alpha1"
+	"github.com/coreos/prometheus-operator/pkg/k8sutil"
 	testFramework "github.com/coreos/prometheus-operator/test/e2e/framework"
 )
 
@@ -99,6 +102,69 @@ func TestAlertmanagerVersionMigration(t *testing.T) {
 	}
 }
 
+func TestAlertmanagerSilencesSurvivePodRestart(t *testing.T) {
+	t.Parallel()
+
+	ctx := framework.NewTestCtx(t)
+	defer ctx.Cleanup(t)
+	ns := ctx.CreateNamespace(t, framework.KubeClient)
+	ctx.SetupPrometheusRBAC(t, ns, framework.KubeClient)
+
+	name := "test"
+	podName := "alertmanager-" + name + "-0"
+
+	am := framework.MakeBasicAlertmanager(name, 1)
+	am.Spec.Storage = &v1alpha1.StorageSpec{
+		VolumeClaimTemplate: v1.PersistentVolumeClaim{
+			Spec: v1.PersistentVolumeClaimSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceStorage: resource.MustParse("100Mi"),
+					},
+				},
+			},
+		},
+	}
+	if err := framework.CreateAlertmanagerAndWaitUntilReady(ns, am); err != nil {
+		t.Fatal(err)
+	}
+
+	silID, err := framework.CreateSilence(ns, podName)
+	if err != nil {
+		t.Fatalf("creating silence failed: %v", err)
+	}
+
+	// Silences are written to the data volume periodically and on shutdown.
+	if err := framework.KubeClient.CoreV1().Pods(ns).Delete(podName, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err = framework.Poll(5*time.Minute, 5*time.Second, func() (bool, error) {
+		pod, err := framework.KubeClient.CoreV1().Pods(ns).Get(podName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		if ready, _ := k8sutil.PodRunningAndReady(*pod); !ready {
+			return false, nil
+		}
+		silences, err := framework.GetSilences(ns, podName)
+		if err != nil {
+			return false, nil
+		}
+		if len(silences) != 1 {
+			return false, fmt.Errorf("expected 1 silence after pod restart, got %d", len(silences))
+		}
+		if silences[0].ID != silID {
+			return false, fmt.Errorf("expected silence %s after pod restart, got %s", silID, silences[0].ID)
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestExposingAlertmanagerWithKubernetesAPI(t *testing.T) {
 	t.Parallel()
 
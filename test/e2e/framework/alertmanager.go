@@ -229,6 +229,46 @@ func (f *Framework) WaitForSpecificAlertmanagerConfig(ns, amName string, expecte
 	})
 }
 
+// CreateSilence creates a silence through the API of the Alertmanager pod
+// and returns its ID.
+func (f *Framework) CreateSilence(ns, n string) (string, error) {
+	sil := `{"id":"","createdBy":"Max Mustermann","comment":"1234","startsAt":"2030-04-09T09:16:15.114Z","endsAt":"2031-04-09T11:16:15.114Z","matchers":[{"name":"test","value":"123","isRegex":false}]}`
+	if _, err := ProxyPostPod(f.KubeClient, ns, n, "9093", "/api/v1/silences", sil).DoRaw(); err != nil {
+		return "", err
+	}
+
+	silences, err := f.GetSilences(ns, n)
+	if err != nil {
+		return "", err
+	}
+	if len(silences) != 1 {
+		return "", errors.Errorf("expected 1 silence, got %d", len(silences))
+	}
+	return silences[0].ID, nil
+}
+
+// GetSilences returns the silences known to the Alertmanager pod.
+func (f *Framework) GetSilences(ns, n string) ([]silence, error) {
+	var res silencesResponse
+	resp, err := ProxyGetPod(f.KubeClient, ns, n, "9093", "/api/v1/silences").DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp, &res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+type silencesResponse struct {
+	Data []silence `json:"data"`
+}
+
+type silence struct {
+	ID        string `json:"id"`
+	CreatedBy string `json:"createdBy"`
+}
+
 type alertmanagerStatus struct {
 	Data alertmanagerStatusData `json:"data"`
 }